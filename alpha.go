@@ -0,0 +1,126 @@
+package voronoi
+
+import "math"
+
+// AlphaPolygon is a polygon of an alpha shape.
+type AlphaPolygon struct {
+	Outer []Point   // outer boundary, ordered counter-clockwise (with Y pointing up)
+	Holes [][]Point // boundaries of holes, ordered clockwise
+}
+
+// AlphaShape returns the alpha shape of the sites: the union of the Delaunay
+// triangles dual to the voronoi vertices, whose distance to the sites - the
+// radius of the circle event at the vertex - is smaller than alpha. The
+// triangles are read from the cells of the diagram, as described for
+// GabrielGraph. The shape is a concave hull of the sites, which tightens as
+// alpha gets smaller and approaches the convex hull as alpha grows, though
+// thin triangles along the hull with a radius larger than the extent of the
+// sites are never part of it. It may consist of several polygons with holes.
+// Polygons, which touch at a single site, are returned separately. Sites at
+// the same location as an earlier site are left out.
+func (v *Voronoi) AlphaShape(alpha float64) []AlphaPolygon {
+	d := v.dual()
+	points := d.points
+
+	// Directed edges of the kept triangles, with the triangle on their left.
+	left := make(map[[2]int]int)
+	for i, tri := range d.triangles {
+		if dist(d.centers[i], points[tri[0]]) >= alpha {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			left[[2]int{tri[k], tri[(k+1)%3]}] = i
+		}
+	}
+
+	outgoing := make(map[int][]int)
+	for e := range left {
+		if _, ok := left[[2]int{e[1], e[0]}]; !ok {
+			outgoing[e[0]] = append(outgoing[e[0]], e[1])
+		}
+	}
+
+	var outers, holes [][]Point
+	var holeTriangles []int
+	used := make(map[[2]int]bool)
+	for _, tri := range d.triangles {
+		for k := 0; k < 3; k++ {
+			start := [2]int{tri[k], tri[(k+1)%3]}
+			if used[start] || !containsInt(outgoing[start[0]], start[1]) {
+				continue
+			}
+			ring := traceBoundary(points, outgoing, used, start)
+			if signedArea(ring) > 0 {
+				outers = append(outers, ring)
+			} else {
+				holes = append(holes, ring)
+				holeTriangles = append(holeTriangles, left[start])
+			}
+		}
+	}
+
+	shape := make([]AlphaPolygon, len(outers))
+	for i, outer := range outers {
+		shape[i].Outer = outer
+	}
+	for i, hole := range holes {
+		// The triangle on the inner side of a hole lies in the polygon around it.
+		tri := d.triangles[holeTriangles[i]]
+		a, b, c := points[tri[0]], points[tri[1]], points[tri[2]]
+		inside := Point{(a.X + b.X + c.X) / 3, (a.Y + b.Y + c.Y) / 3}
+		best := -1
+		for j, outer := range outers {
+			if pointInPolygon(inside, outer) && (best < 0 || signedArea(outer) < signedArea(outers[best])) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			shape[best].Holes = append(shape[best].Holes, hole)
+		}
+	}
+	return shape
+}
+
+// traceBoundary follows the boundary edges of a set of triangles from the
+// given edge, until it returns to its start, and marks the edges as used.
+// At a site, where several boundary edges meet, it takes the edge turning
+// most to the right, so that the traced polygon never crosses itself.
+func traceBoundary(points []Point, outgoing map[int][]int, used map[[2]int]bool, start [2]int) []Point {
+	var ring []Point
+	edge := start
+	for !used[edge] {
+		used[edge] = true
+		ring = append(ring, points[edge[0]])
+		from, at := points[edge[0]], points[edge[1]]
+		back := math.Atan2(from.Y-at.Y, from.X-at.X)
+		next, best := -1, math.Inf(1)
+		for _, to := range outgoing[edge[1]] {
+			if used[[2]int{edge[1], to}] && [2]int{edge[1], to} != start {
+				continue
+			}
+			// Clockwise angle from the edge back to the previous site.
+			turn := back - math.Atan2(points[to].Y-at.Y, points[to].X-at.X)
+			for turn <= 0 {
+				turn += 2 * math.Pi
+			}
+			if turn < best {
+				next, best = to, turn
+			}
+		}
+		if next < 0 {
+			break
+		}
+		edge = [2]int{edge[1], next}
+	}
+	return ring
+}
+
+// containsInt reports if the slice contains the value.
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package voronoi
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAlphaShape(t *testing.T) {
+	for seed := int64(1); seed <= 4; seed++ {
+		// Sites on an annulus, and for even seeds inside it too.
+		r := rand.New(rand.NewSource(seed))
+		var sites SiteSlice
+		for i := 0; i < 400; i++ {
+			a, d := r.Float64()*2*math.Pi, 80+r.Float64()*60
+			if seed%2 == 0 && i%2 == 0 {
+				d = r.Float64() * 140
+			}
+			sites = append(sites, Site{X: 150 + int(d*math.Cos(a)), Y: 150 + int(d*math.Sin(a)), ID: int64(i)})
+		}
+		v := &Voronoi{Sites: sites}
+		d := v.dual()
+
+		for _, alpha := range []float64{5, 10, 20, 40, 1e9} {
+			// The shape covers the triangles of the dual with a smaller radius.
+			var want float64
+			for i, tri := range d.triangles {
+				a, b, c := d.points[tri[0]], d.points[tri[1]], d.points[tri[2]]
+				if dist(d.centers[i], a) < alpha {
+					want += cross(a, b, c) / 2
+				}
+			}
+			shape := v.AlphaShape(alpha)
+			var area float64
+			holes := 0
+			for _, polygon := range shape {
+				if signedArea(polygon.Outer) <= 0 {
+					t.Fatalf("seed %d, alpha %v: outer boundary is not counter-clockwise", seed, alpha)
+				}
+				area += signedArea(polygon.Outer)
+				for _, hole := range polygon.Holes {
+					if signedArea(hole) >= 0 {
+						t.Fatalf("seed %d, alpha %v: hole is not clockwise", seed, alpha)
+					}
+					area += signedArea(hole)
+					holes++
+				}
+			}
+			if math.Abs(area-want) > 1e-6 {
+				t.Errorf("seed %d, alpha %v: shape covers %v, want %v", seed, alpha, area, want)
+			}
+			if alpha == 20 && seed%2 == 1 && holes == 0 {
+				t.Errorf("seed %d: shape of an annulus has no hole", seed)
+			}
+			if alpha == 1e9 && (len(shape) != 1 || holes != 0) {
+				t.Errorf("seed %d: shape for a large alpha has %d polygons and %d holes", seed, len(shape), holes)
+			}
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package voronoi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/quasoft/dcel"
+)
+
+// binaryMagic starts each diagram in the binary encoding.
+const binaryMagic = "VRNB"
+
+// BinaryVersion is the version of the binary encoding written by Encoder.
+const BinaryVersion = 1
+
+// Face kinds in the binary encoding.
+const (
+	binaryFaceNone = iota
+	binaryFaceSite
+	binaryFaceSegment
+)
+
+// Encoder writes diagrams in a compact binary encoding, for diagrams too
+// large for JSON. Each diagram starts with a magic number and the version of
+// the encoding, and ends with a CRC-32 checksum of its bytes, so several
+// diagrams can be written to one stream.
+//
+// All numbers are varints. Coordinates and IDs are written as the difference
+// from the previous site, segment or vertex, and references between elements
+// of the DCEL as the difference from the index of the referring element,
+// which keeps them small. Floating point coordinates of the clip polygon are
+// written as little-endian IEEE 754 values.
+type Encoder struct {
+	// Payload returns the bytes stored with each site, typically derived
+	// from Site.Data, which is not encoded otherwise. Optional.
+	Payload func(site *Site) []byte
+
+	w *bufio.Writer
+}
+
+// NewEncoder creates an encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Decoder reads diagrams written by Encoder.
+type Decoder struct {
+	// Payload is called with the bytes stored with each site, and may set
+	// Site.Data from them. Without it, non-empty payloads are stored in
+	// Site.Data as a byte slice.
+	Payload func(site *Site, payload []byte) error
+
+	r *bufio.Reader
+}
+
+// NewDecoder creates a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// binaryWriter writes varints and keeps the checksum of the written bytes
+// and the first error.
+type binaryWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (b *binaryWriter) write(p []byte) {
+	if b.err != nil {
+		return
+	}
+	b.crc.Write(p)
+	_, b.err = b.w.Write(p)
+}
+
+func (b *binaryWriter) uvarint(x uint64) {
+	b.write(b.buf[:binary.PutUvarint(b.buf[:], x)])
+}
+
+func (b *binaryWriter) varint(x int64) {
+	b.write(b.buf[:binary.PutVarint(b.buf[:], x)])
+}
+
+func (b *binaryWriter) bool(x bool) {
+	if x {
+		b.uvarint(1)
+	} else {
+		b.uvarint(0)
+	}
+}
+
+func (b *binaryWriter) float(x float64) {
+	var p [8]byte
+	binary.LittleEndian.PutUint64(p[:], math.Float64bits(x))
+	b.write(p[:])
+}
+
+// ref writes a reference to an element, relative to the index of the
+// referring one. Zero stands for no element.
+func (b *binaryWriter) ref(index, from int) {
+	if index < 0 {
+		b.uvarint(0)
+		return
+	}
+	d := int64(index - from)
+	b.uvarint(uint64(d<<1^d>>63) + 1)
+}
+
+// Encode writes a diagram. The elements are written directly from the
+// diagram and its DCEL, which are only indexed aside.
+func (e *Encoder) Encode(v *Voronoi) error {
+	d := v.DCEL
+	if d == nil {
+		d = dcel.NewDCEL()
+	}
+	index := newDCELIndex(d)
+	b := &binaryWriter{w: e.w, crc: crc32.NewIEEE()}
+
+	b.write([]byte(binaryMagic))
+	b.uvarint(BinaryVersion)
+	for _, x := range []int{v.Bounds.Min.X, v.Bounds.Min.Y, v.Bounds.Max.X, v.Bounds.Max.Y} {
+		b.varint(int64(x))
+	}
+	b.uvarint(uint64(v.Metric))
+	b.bool(v.Farthest)
+	b.uvarint(uint64(v.Wrap))
+	b.uvarint(uint64(v.CurveSamples))
+	b.varint(int64(v.SweepLine))
+
+	if v.Clip == nil {
+		b.uvarint(0)
+	} else {
+		b.uvarint(uint64(len(v.Clip.Holes)) + 1)
+		b.bool(v.Clip.DropOutside)
+		for _, ring := range append([][]Point{v.Clip.Outer}, v.Clip.Holes...) {
+			b.uvarint(uint64(len(ring)))
+			for _, p := range ring {
+				b.float(p.X)
+				b.float(p.Y)
+			}
+		}
+	}
+
+	var x, y int
+	var id int64
+	sites := make(map[*Site]int, len(v.Sites))
+	b.uvarint(uint64(len(v.Sites)))
+	for i := range v.Sites {
+		s := &v.Sites[i]
+		sites[s] = i
+		b.varint(int64(s.X - x))
+		b.varint(int64(s.Y - y))
+		b.varint(s.ID - id)
+		x, y, id = s.X, s.Y, s.ID
+		b.ref(index.face(s.Face), 0)
+		b.bool(s.Outside)
+		var payload []byte
+		if e.Payload != nil {
+			payload = e.Payload(s)
+		}
+		b.uvarint(uint64(len(payload)))
+		b.write(payload)
+	}
+
+	x, y, id = 0, 0, 0
+	segments := make(map[*Segment]int, len(v.Segments))
+	b.uvarint(uint64(len(v.Segments)))
+	for i := range v.Segments {
+		s := &v.Segments[i]
+		segments[s] = i
+		b.varint(int64(s.A.X - x))
+		b.varint(int64(s.A.Y - y))
+		b.varint(int64(s.B.X - s.A.X))
+		b.varint(int64(s.B.Y - s.A.Y))
+		b.varint(s.ID - id)
+		x, y, id = s.A.X, s.A.Y, s.ID
+		b.ref(index.face(s.Face), 0)
+	}
+
+	x, y = 0, 0
+	b.uvarint(uint64(len(index.vertexList)))
+	for _, p := range index.vertexList {
+		b.varint(int64(p.X - x))
+		b.varint(int64(p.Y - y))
+		x, y = p.X, p.Y
+	}
+
+	b.uvarint(uint64(len(index.halfEdgeList)))
+	b.uvarint(uint64(index.listed))
+	target := 0
+	for i, he := range index.halfEdgeList {
+		t := index.vertex(he.Target)
+		b.ref(t, target)
+		if t >= 0 {
+			target = t
+		}
+		b.ref(index.halfEdge(he.Twin), i)
+		b.ref(index.halfEdge(he.Next), i)
+		b.ref(index.halfEdge(he.Prev), i)
+	}
+
+	id = 0
+	b.uvarint(uint64(len(d.Faces)))
+	for i, f := range d.Faces {
+		b.varint(f.ID - id)
+		id = f.ID
+		b.ref(index.halfEdge(f.HalfEdge), 0)
+		kind, k := binaryFaceNone, -1
+		switch data := f.Data.(type) {
+		case *Site:
+			if j, ok := sites[data]; ok {
+				kind, k = binaryFaceSite, j
+			}
+		case *Segment:
+			if j, ok := segments[data]; ok {
+				kind, k = binaryFaceSegment, j
+			}
+		}
+		b.uvarint(uint64(kind))
+		if kind != binaryFaceNone {
+			b.ref(k, i)
+		}
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], b.crc.Sum32())
+	if _, err := e.w.Write(sum[:]); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// binaryReader reads varints and keeps the checksum of the read bytes and
+// the first error.
+type binaryReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+func (b *binaryReader) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		b.crc.Write([]byte{c})
+	}
+	return c, err
+}
+
+func (b *binaryReader) fail(err error) {
+	if b.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		b.err = err
+	}
+}
+
+func (b *binaryReader) read(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	// Lengths of corrupt input may be huge, so the bytes are not allocated
+	// before they are read.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, b.r, int64(n)); err != nil {
+		b.fail(err)
+		return nil
+	}
+	b.crc.Write(buf.Bytes())
+	return buf.Bytes()
+}
+
+func (b *binaryReader) uvarint() uint64 {
+	if b.err != nil {
+		return 0
+	}
+	x, err := binary.ReadUvarint(b)
+	if err != nil {
+		b.fail(err)
+	}
+	return x
+}
+
+func (b *binaryReader) varint() int64 {
+	if b.err != nil {
+		return 0
+	}
+	x, err := binary.ReadVarint(b)
+	if err != nil {
+		b.fail(err)
+	}
+	return x
+}
+
+func (b *binaryReader) int() int {
+	return int(b.varint())
+}
+
+func (b *binaryReader) bool() bool {
+	return b.uvarint() != 0
+}
+
+// count reads the number of elements of a list, which must not exceed the
+// given limit.
+func (b *binaryReader) count(limit int) int {
+	n := b.uvarint()
+	if n > uint64(limit) {
+		b.fail(errors.New("list too long"))
+		return 0
+	}
+	return int(n)
+}
+
+func (b *binaryReader) float() float64 {
+	if p := b.read(8); p != nil {
+		return math.Float64frombits(binary.LittleEndian.Uint64(p))
+	}
+	return 0
+}
+
+// ref reads a reference written by binaryWriter.ref.
+func (b *binaryReader) ref(from int) int {
+	u := b.uvarint()
+	if u == 0 {
+		return -1
+	}
+	u--
+	return from + int(int64(u>>1)^-int64(u&1))
+}
+
+// maxBinaryLength limits the lengths of lists, so that corrupt input does not
+// allocate too much memory.
+const maxBinaryLength = 1 << 30
+
+// Decode reads a diagram and replaces v with it. Returns io.EOF if the
+// stream has no more diagrams.
+//
+// The diagram is not streamed: it is read whole into its flat form, with
+// references between elements as indices, which is only linked into sites
+// and a DCEL once the checksum matches. While it is linked, both forms are
+// held in memory, which takes about twice the memory of the decoded diagram.
+func (d *Decoder) Decode(v *Voronoi) error {
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return io.EOF
+	}
+	b := &binaryReader{r: d.r, crc: crc32.NewIEEE()}
+
+	if magic := b.read(len(binaryMagic)); b.err == nil && string(magic) != binaryMagic {
+		return errors.New("not a binary voronoi diagram")
+	}
+	if version := b.uvarint(); b.err == nil && version != BinaryVersion {
+		return fmt.Errorf("unsupported binary version %d", version)
+	}
+
+	flat := &diagramJSON{}
+	for i := range flat.Bounds {
+		flat.Bounds[i] = b.int()
+	}
+	flat.Metric = Metric(b.uvarint())
+	flat.Farthest = b.bool()
+	flat.Wrap = Wrap(b.uvarint())
+	flat.CurveSamples = int(b.uvarint())
+	flat.SweepLine = b.int()
+
+	if rings := b.count(maxBinaryLength); rings > 0 {
+		flat.Clip = &clipJSON{DropOutside: b.bool()}
+		for r := 0; r < rings && b.err == nil; r++ {
+			var ring [][2]float64
+			n := b.count(maxBinaryLength)
+			for k := 0; k < n && b.err == nil; k++ {
+				ring = append(ring, [2]float64{b.float(), b.float()})
+			}
+			if r == 0 {
+				flat.Clip.Outer = ring
+			} else {
+				flat.Clip.Holes = append(flat.Clip.Holes, ring)
+			}
+		}
+	}
+
+	var x, y int
+	var id int64
+	var payloads [][]byte
+	n := b.count(maxBinaryLength)
+	for i := 0; i < n && b.err == nil; i++ {
+		x += b.int()
+		y += b.int()
+		id += b.varint()
+		s := siteJSON{X: x, Y: y, ID: id}
+		s.Face = b.ref(0)
+		s.Outside = b.bool()
+		payloads = append(payloads, b.read(b.count(maxBinaryLength)))
+		flat.Sites = append(flat.Sites, s)
+	}
+
+	x, y, id = 0, 0, 0
+	n = b.count(maxBinaryLength)
+	for i := 0; i < n && b.err == nil; i++ {
+		x += b.int()
+		y += b.int()
+		s := segmentJSON{A: [2]int{x, y}}
+		s.B = [2]int{x + b.int(), y + b.int()}
+		id += b.varint()
+		s.ID = id
+		s.Face = b.ref(0)
+		flat.Segments = append(flat.Segments, s)
+	}
+
+	x, y = 0, 0
+	n = b.count(maxBinaryLength)
+	for i := 0; i < n && b.err == nil; i++ {
+		x += b.int()
+		y += b.int()
+		flat.Vertices = append(flat.Vertices, [2]int{x, y})
+	}
+
+	n = b.count(maxBinaryLength)
+	listed := b.count(n + 1)
+	target := 0
+	for i := 0; i < n && b.err == nil; i++ {
+		he := halfEdgeJSON{Target: b.ref(target), Unlisted: i >= listed}
+		if he.Target >= 0 {
+			target = he.Target
+		}
+		he.Twin = b.ref(i)
+		he.Next = b.ref(i)
+		he.Prev = b.ref(i)
+		flat.HalfEdges = append(flat.HalfEdges, he)
+	}
+
+	id = 0
+	n = b.count(maxBinaryLength)
+	for i := 0; i < n && b.err == nil; i++ {
+		id += b.varint()
+		f := faceJSON{ID: id, HalfEdge: b.ref(0), Site: -1, Segment: -1}
+		switch kind := b.uvarint(); kind {
+		case binaryFaceSite:
+			f.Site = b.ref(i)
+		case binaryFaceSegment:
+			f.Segment = b.ref(i)
+		case binaryFaceNone:
+		default:
+			b.fail(fmt.Errorf("invalid face kind %d", kind))
+		}
+		flat.Faces = append(flat.Faces, f)
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+	sum := b.crc.Sum32()
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if binary.LittleEndian.Uint32(p) != sum {
+		return errors.New("checksum mismatch")
+	}
+
+	var decoded Voronoi
+	if err := decoded.unflatten(flat); err != nil {
+		return err
+	}
+	for i, payload := range payloads {
+		site := &decoded.Sites[i]
+		if d.Payload != nil {
+			if err := d.Payload(site, payload); err != nil {
+				return err
+			}
+		} else if len(payload) > 0 {
+			site.Data = payload
+		}
+	}
+	*v = decoded
+	return nil
+}
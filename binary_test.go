@@ -0,0 +1,209 @@
+package voronoi
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/quasoft/dcel"
+)
+
+// binaryTestDiagrams returns generated diagrams of point sites, of clipped
+// cells with a hole, and of segments.
+func binaryTestDiagrams() []*Voronoi {
+	bounds := image.Rect(0, 0, 200, 200)
+	r := rand.New(rand.NewSource(1))
+	var sites SiteSlice
+	for i := 0; i < 100; i++ {
+		sites = append(sites, Site{X: r.Intn(200), Y: r.Intn(200), ID: int64(i)})
+	}
+
+	plain := New(append(SiteSlice(nil), sites...), bounds)
+	plain.Generate()
+
+	clipped := New(append(SiteSlice(nil), sites[:30]...), bounds)
+	clipped.Clip = NewClipPolygon(
+		[]Point{{0, 0}, {200, 0}, {200, 200}, {100, 30.5}, {0, 200}},
+		[]Point{{10, 10}, {20, 10}, {15, 20}},
+	)
+	clipped.Generate()
+
+	segments := NewWithSegments(
+		SiteSlice{{X: 20, Y: 20, ID: 1}, {X: 150, Y: 170, ID: -2}},
+		Polyline([]image.Point{{50, 100}, {100, 120}, {150, 60}}, 10),
+		bounds,
+	)
+	segments.Generate()
+
+	return []*Voronoi{plain, clipped, segments}
+}
+
+func encodeBinary(t *testing.T, diagrams []*Voronoi) []byte {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Payload = func(site *Site) []byte {
+		return []byte{byte(site.ID), byte(site.X)}
+	}
+	for _, v := range diagrams {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	diagrams := binaryTestDiagrams()
+	dec := NewDecoder(bytes.NewReader(encodeBinary(t, diagrams)))
+	for k, want := range diagrams {
+		var got Voronoi
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("diagram %d: %v", k, err)
+		}
+		if got.Bounds != want.Bounds || got.SweepLine != want.SweepLine {
+			t.Errorf("diagram %d: options differ", k)
+		}
+		if (got.Clip == nil) != (want.Clip == nil) {
+			t.Errorf("diagram %d: clip polygon differs", k)
+		}
+		compareBinaryDCEL(t, k, want, &got)
+	}
+	var v Voronoi
+	if err := dec.Decode(&v); err != io.EOF {
+		t.Errorf("got %v after the last diagram, want EOF", err)
+	}
+}
+
+// compareBinaryDCEL compares the sites, segments and DCEL of a decoded
+// diagram with the encoded one, element by element in the order in which
+// they are encoded.
+func compareBinaryDCEL(t *testing.T, k int, want, got *Voronoi) {
+	wi, gi := newDCELIndex(want.DCEL), newDCELIndex(got.DCEL)
+
+	if len(gi.vertexList) != len(wi.vertexList) {
+		t.Fatalf("diagram %d: got %d vertices, want %d", k, len(gi.vertexList), len(wi.vertexList))
+	}
+	for i, w := range wi.vertexList {
+		if g := gi.vertexList[i]; g.X != w.X || g.Y != w.Y {
+			t.Fatalf("diagram %d: vertex %d is (%d, %d), want (%d, %d)", k, i, g.X, g.Y, w.X, w.Y)
+		}
+	}
+
+	if len(gi.halfEdgeList) != len(wi.halfEdgeList) || gi.listed != wi.listed {
+		t.Fatalf("diagram %d: got %d half-edges, want %d", k, len(gi.halfEdgeList), len(wi.halfEdgeList))
+	}
+	for i, w := range wi.halfEdgeList {
+		g := gi.halfEdgeList[i]
+		if gi.vertex(g.Target) != wi.vertex(w.Target) {
+			t.Fatalf("diagram %d: target of half-edge %d differs", k, i)
+		}
+		if gi.halfEdge(g.Twin) != wi.halfEdge(w.Twin) {
+			t.Fatalf("diagram %d: twin of half-edge %d differs", k, i)
+		}
+		if gi.halfEdge(g.Next) != wi.halfEdge(w.Next) {
+			t.Fatalf("diagram %d: next of half-edge %d differs", k, i)
+		}
+		if gi.halfEdge(g.Prev) != wi.halfEdge(w.Prev) {
+			t.Fatalf("diagram %d: prev of half-edge %d differs", k, i)
+		}
+	}
+
+	if len(got.DCEL.Faces) != len(want.DCEL.Faces) {
+		t.Fatalf("diagram %d: got %d faces, want %d", k, len(got.DCEL.Faces), len(want.DCEL.Faces))
+	}
+	for i, w := range want.DCEL.Faces {
+		g := got.DCEL.Faces[i]
+		if g.ID != w.ID || gi.halfEdge(g.HalfEdge) != wi.halfEdge(w.HalfEdge) {
+			t.Fatalf("diagram %d: face %d differs", k, i)
+		}
+		if faceOwner(got, g) != faceOwner(want, w) {
+			t.Fatalf("diagram %d: site or segment of face %d differs", k, i)
+		}
+	}
+
+	if len(got.Sites) != len(want.Sites) || len(got.Segments) != len(want.Segments) {
+		t.Fatalf("diagram %d: got %d sites and %d segments, want %d and %d",
+			k, len(got.Sites), len(got.Segments), len(want.Sites), len(want.Segments))
+	}
+	for i := range want.Sites {
+		w, g := &want.Sites[i], &got.Sites[i]
+		if g.X != w.X || g.Y != w.Y || g.ID != w.ID || g.Outside != w.Outside {
+			t.Fatalf("diagram %d: site %d differs", k, i)
+		}
+		if gi.face(g.Face) != wi.face(w.Face) {
+			t.Fatalf("diagram %d: face of site %d differs", k, i)
+		}
+		payload, ok := g.Data.([]byte)
+		if !ok || !bytes.Equal(payload, []byte{byte(w.ID), byte(w.X)}) {
+			t.Fatalf("diagram %d: payload of site %d is %v", k, i, g.Data)
+		}
+	}
+	for i := range want.Segments {
+		w, g := &want.Segments[i], &got.Segments[i]
+		if g.A != w.A || g.B != w.B || g.ID != w.ID || gi.face(g.Face) != wi.face(w.Face) {
+			t.Fatalf("diagram %d: segment %d differs", k, i)
+		}
+	}
+}
+
+// faceOwner returns the index of the site of a face, or the index of its
+// segment after the sites, or -1 if the face has neither.
+func faceOwner(v *Voronoi, face *dcel.Face) int {
+	for i := range v.Sites {
+		if face.Data == &v.Sites[i] {
+			return i
+		}
+	}
+	for i := range v.Segments {
+		if face.Data == &v.Segments[i] {
+			return len(v.Sites) + i
+		}
+	}
+	return -1
+}
+
+func TestBinaryPayloadDecoder(t *testing.T) {
+	data := encodeBinary(t, binaryTestDiagrams()[:1])
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.Payload = func(site *Site, payload []byte) error {
+		site.Data = int(payload[0])
+		return nil
+	}
+	var v Voronoi
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	for i := range v.Sites {
+		if v.Sites[i].Data != int(byte(v.Sites[i].ID)) {
+			t.Fatalf("site %d has data %v", i, v.Sites[i].Data)
+		}
+	}
+}
+
+func TestBinaryCorrupted(t *testing.T) {
+	data := encodeBinary(t, binaryTestDiagrams()[:1])
+
+	bad := append([]byte(nil), data...)
+	bad[len(bad)-1] ^= 0x01
+	var v Voronoi
+	if err := NewDecoder(bytes.NewReader(bad)).Decode(&v); err == nil || err.Error() != "checksum mismatch" {
+		t.Errorf("corrupted checksum: got %v", err)
+	}
+
+	for _, i := range []int{len(data) / 3, len(data) / 2, len(data) - 10} {
+		bad := append([]byte(nil), data...)
+		bad[i] ^= 0x10
+		if err := NewDecoder(bytes.NewReader(bad)).Decode(&v); err == nil {
+			t.Errorf("corrupted byte %d not detected", i)
+		}
+	}
+
+	if err := NewDecoder(bytes.NewReader(data[:len(data)/2])).Decode(&v); err == nil {
+		t.Error("truncated diagram not detected")
+	}
+	if err := NewDecoder(bytes.NewReader([]byte("VRNX"))).Decode(&v); err == nil {
+		t.Error("wrong magic number not detected")
+	}
+}
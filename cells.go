@@ -0,0 +1,301 @@
+package voronoi
+
+import (
+	"image"
+	"sort"
+
+	"github.com/quasoft/dcel"
+)
+
+// Cell is a read-only view of the cell of a site or a segment, taken from
+// the DCEL.
+type Cell struct {
+	Site    *Site    // site of the cell, or nil for the cell of a segment
+	Segment *Segment // segment of the cell, or nil for the cell of a site
+	Polygon []Point  // vertices, ordered counter-clockwise (with Y pointing up)
+	// Holes are the boundaries of holes, which the clip polygon leaves in
+	// the cell, ordered clockwise.
+	Holes [][]Point
+	// Edges of the polygon, in its order, followed by the edges of each hole.
+	Edges     []Edge
+	Neighbors []int64 // IDs of the neighbouring sites, in the order of the edges
+	// Bounded is set if the cell is surrounded by other cells, and not cut by
+	// the bounds or the clip polygon of the diagram.
+	Bounded bool
+}
+
+// Edge is an edge of a cell, from vertex A to vertex B.
+type Edge struct {
+	A, B     Point
+	HalfEdge *dcel.HalfEdge
+	// Neighbor is the site on the other side of the edge, or nil if the edge
+	// lies on the border of the diagram or next to a segment.
+	Neighbor *Site
+	// NeighborSegment is the segment on the other side of the edge, if any.
+	NeighborSegment *Segment
+}
+
+// Cells returns the cells of the sites, in the order of the sites, followed
+// by the cells of the segments, in their order. A cell split into several
+// faces by the clip polygon is returned once for each face, and sites without
+// a cell are left out. Cells of periodic diagrams are
+// not wrapped into the bounds, so their polygons are contiguous.
+//
+// The diagram is not modified. The DCEL is only read, by following the Next
+// and Twin links of the half-edges, which Generate links in order for all
+// diagrams. Diagrams created by the sweep line have no cells, until all its
+// events are handled.
+func (v *Voronoi) Cells() []Cell {
+	d := v.DCEL
+	if d == nil || (v.usesSweepLine() && v.EventQueue.Len() > 0) {
+		return nil
+	}
+
+	owner := make(map[*dcel.HalfEdge]*dcel.Face, len(d.HalfEdges))
+	faces := make(map[interface{}][]*dcel.Face)
+	for _, face := range d.Faces {
+		switch face.Data.(type) {
+		case *Site, *Segment:
+		default:
+			continue
+		}
+		if face.HalfEdge == nil {
+			continue
+		}
+		faces[face.Data] = append(faces[face.Data], face)
+		he := face.HalfEdge
+		for {
+			owner[he] = face
+			if he = he.Next; he == nil || he == face.HalfEdge {
+				break
+			}
+		}
+	}
+
+	domain := newTorus(v.Bounds, v.Wrap)
+	// Only clipped cells have holes. Cells of periodic diagrams may border on
+	// themselves, which is not a bridge to a hole.
+	holes := v.Clip != nil && v.Wrap == WrapNone && len(v.Segments) == 0
+	var cells []Cell
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		for _, face := range faces[site] {
+			cell := faceCell(image.Point{site.X, site.Y}, face, owner, domain, holes)
+			cell.Site = site
+			cells = append(cells, cell)
+		}
+	}
+	for i := range v.Segments {
+		segment := &v.Segments[i]
+		for _, face := range faces[segment] {
+			cell := faceCell(segment.A, face, owner, domain, holes)
+			cell.Segment = segment
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// cellOrder returns a function giving the position of the site of a cell in
+// Voronoi.Sites, or of its segment in Voronoi.Segments after the sites,
+// which picks the colour of the cell from the palette.
+func (v *Voronoi) cellOrder() func(cell *Cell) int {
+	sites := make(map[*Site]int, len(v.Sites))
+	for i := range v.Sites {
+		sites[&v.Sites[i]] = i
+	}
+	segments := make(map[*Segment]int, len(v.Segments))
+	for i := range v.Segments {
+		segments[&v.Segments[i]] = len(v.Sites) + i
+	}
+	return func(cell *Cell) int {
+		if cell.Site != nil {
+			return sites[cell.Site]
+		}
+		return segments[cell.Segment]
+	}
+}
+
+// linkCells replaces the half-edges and vertices created by the sweep line,
+// which are neither linked in order nor clipped to the bounds, with ones built
+// from the exact cell polygons, the same way Insert and Delete do. The faces
+// are kept. The cells are computed for copies of the sites, numbered by their
+// position, since the IDs of the sites need not be unique.
+func (v *Voronoi) linkCells() {
+	sites := make(SiteSlice, len(v.Sites))
+	for i := range v.Sites {
+		sites[i] = Site{X: v.Sites[i].X, Y: v.Sites[i].Y, ID: int64(i)}
+	}
+	e, err := newEditor(sites, v.Bounds)
+	if err != nil {
+		return
+	}
+
+	d := dcel.NewDCEL()
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		if face := site.Face; face != nil && e.cells[int64(i)] != nil {
+			if _, ok := e.facePos[face]; !ok {
+				face.HalfEdge = nil
+				e.faces[int64(i)] = face
+				e.facePos[face] = len(d.Faces)
+				d.Faces = append(d.Faces, face)
+			}
+		}
+		site.Face = nil
+	}
+	var ids []int64
+	for id := range e.cells {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	e.rebuild(&Voronoi{Bounds: v.Bounds, Sites: sites, DCEL: d}, e.clipToBounds(v.Bounds, ids))
+
+	for i, face := range e.faces {
+		site := &v.Sites[i]
+		face.ID = site.ID
+		face.Data = site
+		site.Face = face
+	}
+	v.DCEL = d
+}
+
+// faceCell collects a cell from the half-edges of its face, unwrapping
+// periodic vertices starting from the origin, the location of its site. With
+// holes set, pairs of twin half-edges within the face are taken for bridges
+// to holes, and left out of the boundaries.
+func faceCell(origin image.Point, face *dcel.Face, owner map[*dcel.HalfEdge]*dcel.Face, domain torus, holes bool) Cell {
+	cell := Cell{Bounded: true}
+	seen := make(map[int64]bool)
+
+	// On a periodic domain, the vertices are wrapped into the bounds, so
+	// each vertex is replaced with its copy nearest to the previous one.
+	// Only copies within half a period of the site, up to rounding, are
+	// considered, as edges of a cell may be longer than half a period.
+	center := domain.wrap(origin)
+	near := func(q image.Point) bool {
+		within := func(d, period int) bool {
+			return period == 0 || 2*d <= period+2 && -2*d <= period+2
+		}
+		return within(q.X-center.X, domain.period.X) && within(q.Y-center.Y, domain.period.Y)
+	}
+	prev := origin
+	unwrap := func(v *dcel.Vertex) Point {
+		p := image.Point{v.X, v.Y}
+		best := p
+		// An edge from a vertex to itself wraps around the whole domain,
+		// when the cell does, and ends at another copy of the vertex.
+		loop := domain.period != image.Point{} && domain.wrap(p) == domain.wrap(prev)
+		for _, q := range domain.images(p) {
+			if !near(q) || loop && q == prev {
+				continue
+			}
+			if !near(best) || loop && best == prev || distSquared(q, prev) < distSquared(best, prev) {
+				best = q
+			}
+		}
+		prev = best
+		return Point{float64(best.X), float64(best.Y)}
+	}
+
+	for k, ring := range faceRings(face, owner, holes) {
+		var polygon []Point
+		var edges []Edge
+		var first Point
+		if last := ring[len(ring)-1]; last.Target != nil {
+			first = unwrap(last.Target)
+		}
+		a := first
+		for i, he := range ring {
+			b := first
+			if i+1 < len(ring) {
+				b = unwrap(he.Target)
+			}
+			edge := Edge{A: a, B: b, HalfEdge: he}
+			if other := owner[he.Twin]; he.Twin != nil && other != nil {
+				switch neighbor := other.Data.(type) {
+				case *Site:
+					edge.Neighbor = neighbor
+					if !seen[neighbor.ID] {
+						seen[neighbor.ID] = true
+						cell.Neighbors = append(cell.Neighbors, neighbor.ID)
+					}
+				case *Segment:
+					edge.NeighborSegment = neighbor
+				}
+			} else {
+				cell.Bounded = false
+			}
+			polygon = append(polygon, a)
+			edges = append(edges, edge)
+			a = b
+		}
+
+		if k == 0 {
+			cell.Polygon = polygon
+			cell.Edges = edges
+			continue
+		}
+		// The outer boundary is the only one oriented counter-clockwise,
+		// but it is not necessarily the first one reached.
+		if signedArea(polygon) > 0 {
+			cell.Holes = append(cell.Holes, cell.Polygon)
+			cell.Polygon = polygon
+			cell.Edges = append(edges, cell.Edges...)
+		} else {
+			cell.Holes = append(cell.Holes, polygon)
+			cell.Edges = append(cell.Edges, edges...)
+		}
+	}
+	return cell
+}
+
+// faceRings returns the boundaries of a face as lists of half-edges. With
+// holes set, a face whose half-edges include bridges, twins within the
+// face, is split into its outer boundary and the boundaries of its holes.
+func faceRings(face *dcel.Face, owner map[*dcel.HalfEdge]*dcel.Face, holes bool) [][]*dcel.HalfEdge {
+	var all []*dcel.HalfEdge
+	bridge := make(map[*dcel.HalfEdge]bool)
+	he := face.HalfEdge
+	for {
+		all = append(all, he)
+		if holes && he.Twin != nil && owner[he.Twin] == face {
+			bridge[he] = true
+		}
+		if he = he.Next; he == nil || he == face.HalfEdge {
+			break
+		}
+	}
+	if len(bridge) == 0 {
+		return [][]*dcel.HalfEdge{all}
+	}
+
+	// The boundary continues past a bridge after returning along its twin.
+	next := func(he *dcel.HalfEdge) *dcel.HalfEdge {
+		n := he.Next
+		for steps := 0; bridge[n] && steps <= len(all); steps++ {
+			n = n.Twin.Next
+		}
+		return n
+	}
+	var rings [][]*dcel.HalfEdge
+	done := make(map[*dcel.HalfEdge]bool)
+	for _, start := range all {
+		if bridge[start] || done[start] {
+			continue
+		}
+		var ring []*dcel.HalfEdge
+		for he := start; !done[he]; he = next(he) {
+			done[he] = true
+			ring = append(ring, he)
+		}
+		rings = append(rings, ring)
+	}
+	return rings
+}
+
+// distSquared returns the squared euclidean distance between two integer points.
+func distSquared(a, b image.Point) int {
+	d := a.Sub(b)
+	return d.X*d.X + d.Y*d.Y
+}
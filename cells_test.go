@@ -0,0 +1,136 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/quasoft/dcel"
+)
+
+// randomSites returns n sites at random locations within the bounds, with
+// IDs counting from zero.
+func randomSites(n int, seed int64, bounds image.Rectangle) SiteSlice {
+	r := rand.New(rand.NewSource(seed))
+	sites := make(SiteSlice, n)
+	for i := range sites {
+		sites[i] = Site{
+			X:  bounds.Min.X + r.Intn(bounds.Dx()),
+			Y:  bounds.Min.Y + r.Intn(bounds.Dy()),
+			ID: int64(i),
+		}
+	}
+	return sites
+}
+
+// cellsArea returns the total area of the cells, without their holes.
+func cellsArea(cells []Cell) float64 {
+	var area float64
+	for _, cell := range cells {
+		area += signedArea(cell.Polygon)
+		for _, hole := range cell.Holes {
+			area += signedArea(hole)
+		}
+	}
+	return area
+}
+
+func TestCellsOfSweepLine(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+	for seed := int64(1); seed <= 3; seed++ {
+		sites := randomSites(80, seed, bounds)
+		// Sites with the zero ID, whose IDs are not unique.
+		sites = append(sites, Site{X: 150, Y: 100}, Site{X: 20, Y: 180})
+		v := New(sites, bounds)
+		v.Generate()
+
+		listed := make(map[*dcel.HalfEdge]bool, len(v.DCEL.HalfEdges))
+		for _, he := range v.DCEL.HalfEdges {
+			listed[he] = true
+		}
+		cells := v.Cells()
+		if len(cells) != len(sites) {
+			t.Fatalf("seed %d: got %d cells, want %d", seed, len(cells), len(sites))
+		}
+		if area := cellsArea(cells); math.Abs(area-60000) > 1e-9*60000 {
+			t.Errorf("seed %d: cells cover %v, want 60000", seed, area)
+		}
+		neighbors := make(map[*Site]map[*Site]bool)
+		for _, cell := range cells {
+			if cell.Site.Face == nil || !pointInPolygon(pointOf(cell.Site), cell.Polygon) && !onPolygonBoundary(pointOf(cell.Site), cell.Polygon) {
+				t.Fatalf("seed %d: site %v lies outside its cell", seed, *cell.Site)
+			}
+			neighbors[cell.Site] = make(map[*Site]bool)
+			for _, edge := range cell.Edges {
+				if !listed[edge.HalfEdge] {
+					t.Fatalf("seed %d: half-edge of site %d is not in the DCEL", seed, cell.Site.ID)
+				}
+				if edge.Neighbor != nil {
+					neighbors[cell.Site][edge.Neighbor] = true
+				}
+			}
+		}
+		for a, sites := range neighbors {
+			for b := range sites {
+				if !neighbors[b][a] {
+					t.Fatalf("seed %d: site %d neighbours %d, but not the other way", seed, a.ID, b.ID)
+				}
+			}
+		}
+	}
+}
+
+func TestCellsDoNotModifyDiagram(t *testing.T) {
+	v := New(randomSites(50, 1, image.Rect(0, 0, 100, 100)), image.Rect(0, 0, 100, 100))
+	v.Generate()
+	d := v.DCEL
+	faces, halfEdges := len(d.Faces), len(d.HalfEdges)
+	first := v.Cells()
+	if v.DCEL != d || len(d.Faces) != faces || len(d.HalfEdges) != halfEdges || v.editor != nil {
+		t.Fatal("Cells modified the DCEL")
+	}
+	second := v.Cells()
+	for i := range first {
+		if first[i].Site != second[i].Site || !samePolygon(first[i].Polygon, second[i].Polygon) {
+			t.Fatalf("cell %d differs between calls", i)
+		}
+	}
+}
+
+func TestCellsWithHoles(t *testing.T) {
+	v := New(randomSites(30, 2, image.Rect(0, 0, 200, 200)), image.Rect(0, 0, 200, 200))
+	v.Clip = NewClipPolygon(
+		[]Point{{0, 0}, {200, 0}, {200, 200}, {0, 200}},
+		[]Point{{50, 50}, {50, 150}, {150, 150}, {150, 50}},
+	)
+	v.Generate()
+	if area := cellsArea(v.Cells()); math.Abs(area-30000) > 1e-6*30000 {
+		t.Errorf("cells cover %v, want 30000", area)
+	}
+}
+
+func TestFaceHelpersKeepLinks(t *testing.T) {
+	v := New(randomSites(40, 3, image.Rect(0, 0, 100, 100)), image.Rect(0, 0, 100, 100))
+	v.Generate()
+	next := make(map[*dcel.HalfEdge]*dcel.HalfEdge)
+	for _, he := range v.DCEL.HalfEdges {
+		next[he] = he.Next
+	}
+	for _, face := range v.DCEL.Faces {
+		edges := v.GetFaceHalfEdges(face)
+		for i, he := range edges {
+			if he.Next != edges[(i+1)%len(edges)] {
+				t.Fatalf("half-edges of face %d are not in order", face.ID)
+			}
+		}
+		if vertices := v.GetFaceVertices(face); len(vertices) != len(edges) {
+			t.Fatalf("face %d has %d vertices and %d half-edges", face.ID, len(vertices), len(edges))
+		}
+	}
+	for _, he := range v.DCEL.HalfEdges {
+		if he.Next != next[he] {
+			t.Fatal("the links of the half-edges were modified")
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// ClipPolygon is a simple polygon with optional holes, to which the cells of
+// a diagram are clipped, in addition to the bounds rectangle.
+// Cells crossing a concave part of the polygon are split into several faces.
+type ClipPolygon struct {
+	Outer []Point   // Outer boundary of the polygon.
+	Holes [][]Point // Boundaries of holes inside the polygon.
+	// DropOutside removes the sites lying outside the polygon before the
+	// diagram is generated, so they do not affect the cells of the other sites.
+	// Otherwise such sites are kept and marked with Site.Outside.
+	DropOutside bool
+}
+
+// NewClipPolygon creates a clip polygon with the given outer boundary and holes.
+// The orientation of the boundaries does not matter.
+func NewClipPolygon(outer []Point, holes ...[]Point) *ClipPolygon {
+	return &ClipPolygon{Outer: outer, Holes: holes}
+}
+
+// Contains reports if the point lies inside the polygon and not inside any of its holes.
+func (c *ClipPolygon) Contains(p Point) bool {
+	if !pointInPolygon(p, c.Outer) {
+		return false
+	}
+	for _, hole := range c.Holes {
+		if pointInPolygon(p, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// isConvex reports if the polygon has no holes and no reflex vertices.
+func (c *ClipPolygon) isConvex() bool {
+	if len(c.Holes) > 0 {
+		return false
+	}
+	return isConvexPolygon(c.Outer)
+}
+
+// rings returns the boundaries of the polygon, with the outer boundary
+// oriented counter-clockwise and the holes clockwise, so that the inside of
+// the polygon is always on the left side.
+func (c *ClipPolygon) rings() [][]Point {
+	outer := c.Outer
+	if signedArea(outer) < 0 {
+		outer = reversePolygon(outer)
+	}
+	rings := [][]Point{outer}
+	for _, hole := range c.Holes {
+		if signedArea(hole) > 0 {
+			hole = reversePolygon(hole)
+		}
+		rings = append(rings, hole)
+	}
+	return rings
+}
+
+// filterSites removes the sites outside the polygon from the list if
+// DropOutside is set.
+func (c *ClipPolygon) filterSites(sites []*Site) []*Site {
+	if !c.DropOutside {
+		return sites
+	}
+	var kept []*Site
+	for _, site := range sites {
+		if c.Contains(pointOf(site)) {
+			kept = append(kept, site)
+		}
+	}
+	return kept
+}
+
+// clipCells intersects each cell with the polygon. Cells may be split into
+// several polygons, in which case the site is listed once for each of them,
+// with the largest polygon first. Cells outside of the polygon are removed.
+// Holes in the resulting polygons are connected to their outer boundary.
+func (c *ClipPolygon) clipCells(sites []*Site, cells [][]Point, convexCells bool) ([]*Site, [][]Point) {
+	rings := c.rings()
+	fast := convexCells && c.isConvex()
+
+	var owners []*Site
+	var polygons [][]Point
+	for i, cell := range cells {
+		var pieces [][]Point
+		if fast {
+			if piece := clipConvex(cell, rings[0]); piece != nil {
+				pieces = append(pieces, piece)
+			}
+		} else {
+			pieces = intersectPolygon(cell, rings, c.Contains)
+		}
+		sort.Slice(pieces, func(a, b int) bool {
+			return signedArea(pieces[a]) > signedArea(pieces[b])
+		})
+		for _, piece := range pieces {
+			owners = append(owners, sites[i])
+			polygons = append(polygons, piece)
+		}
+	}
+	return owners, polygons
+}
+
+// clipConvex returns the intersection of a polygon with a convex,
+// counter-clockwise oriented polygon, using the Sutherland–Hodgman algorithm.
+func clipConvex(poly []Point, convex []Point) []Point {
+	for i, a := range convex {
+		b := convex[(i+1)%len(convex)]
+		// Keep the points on the left side of ab.
+		n := Point{b.Y - a.Y, a.X - b.X}
+		poly = clipHalfPlane(poly, n, n.X*a.X+n.Y*a.Y)
+		if poly == nil {
+			return nil
+		}
+	}
+	return poly
+}
+
+// intersectPolygon returns the pieces of a simple polygon, which lie inside
+// the region bounded by the given rings, where contains tests if a point is
+// inside the region. Edges of the polygon and of the rings are split where
+// they cross, and the pieces of edges lying inside the other polygon are
+// then chained into the boundaries of the intersection.
+func intersectPolygon(poly []Point, rings [][]Point, contains func(Point) bool) [][]Point {
+	if signedArea(poly) < 0 {
+		poly = reversePolygon(poly)
+	}
+	min, max := polygonBox(poly)
+
+	// Only edges of the rings near the polygon can cross it.
+	var ringEdges [][2]Point
+	for _, ring := range rings {
+		for i, p := range ring {
+			q := ring[(i+1)%len(ring)]
+			if math.Max(p.X, q.X) >= min.X && math.Min(p.X, q.X) <= max.X &&
+				math.Max(p.Y, q.Y) >= min.Y && math.Min(p.Y, q.Y) <= max.Y {
+				ringEdges = append(ringEdges, [2]Point{p, q})
+			}
+		}
+	}
+	var polyEdges [][2]Point
+	for i, p := range poly {
+		polyEdges = append(polyEdges, [2]Point{p, poly[(i+1)%len(poly)]})
+	}
+
+	// The scale of the polygon determines the tolerance of comparisons.
+	eps := 1e-9 * (1 + max.X - min.X + max.Y - min.Y)
+	inPoly := func(p Point) bool { return pointInPolygon(p, poly) }
+
+	var boundary [][2]Point
+	for _, e := range splitEdges(polyEdges, ringEdges, eps) {
+		mid := lerp(e[0], e[1], 0.5)
+		if onSegments(mid, ringEdges, eps) {
+			// Edge shared with the boundary of the region - keep it only
+			// if the inside of both is on the same side of it.
+			if contains(leftOf(e[0], e[1], mid, eps)) {
+				boundary = append(boundary, e)
+			}
+		} else if contains(mid) {
+			boundary = append(boundary, e)
+		}
+	}
+	for _, e := range splitEdges(ringEdges, polyEdges, eps) {
+		mid := lerp(e[0], e[1], 0.5)
+		if !onSegments(mid, polyEdges, eps) && inPoly(mid) {
+			boundary = append(boundary, e)
+		}
+	}
+
+	outers, holes := chainEdges(boundary, eps)
+
+	// Connect each hole to the outer boundary containing it.
+	for _, hole := range holes {
+		for i, outer := range outers {
+			if pointInPolygon(hole[0], outer) {
+				outers[i] = bridgeHole(outer, hole, holes)
+				break
+			}
+		}
+	}
+	return outers
+}
+
+// splitEdges splits the edges where they cross or touch any of the other edges.
+func splitEdges(edges, others [][2]Point, eps float64) [][2]Point {
+	var pieces [][2]Point
+	for _, e := range edges {
+		cuts := []float64{0, 1}
+		for _, o := range others {
+			if t, _, ok := segmentIntersection(e[0], e[1], o[0], o[1]); ok {
+				cuts = append(cuts, t)
+			}
+			// Ends of overlapping or touching edges
+			for _, p := range o {
+				t := project(e[0], e[1], p)
+				if dist(lerp(e[0], e[1], t), p) < eps {
+					cuts = append(cuts, t)
+				}
+			}
+		}
+		sort.Float64s(cuts)
+		length := dist(e[0], e[1])
+		for i := 0; i+1 < len(cuts); i++ {
+			if (cuts[i+1]-cuts[i])*length < eps {
+				continue
+			}
+			pieces = append(pieces, [2]Point{lerp(e[0], e[1], cuts[i]), lerp(e[0], e[1], cuts[i+1])})
+		}
+	}
+	return pieces
+}
+
+// onSegments reports if the point lies on any of the segments.
+func onSegments(p Point, segments [][2]Point, eps float64) bool {
+	for _, s := range segments {
+		if dist(lerp(s[0], s[1], project(s[0], s[1], p)), p) < eps {
+			return true
+		}
+	}
+	return false
+}
+
+// leftOf returns a point near p, on the left side of the direction from a to b.
+func leftOf(a, b, p Point, eps float64) Point {
+	d := dist(a, b)
+	offset := 1000 * eps
+	return Point{p.X - (b.Y-a.Y)/d*offset, p.Y + (b.X-a.X)/d*offset}
+}
+
+// chainEdges links directed edges into closed rings. Rings oriented
+// counter-clockwise are returned as outer boundaries, the rest as holes.
+// Where several rings touch at a vertex, the edge turning most to the left
+// is followed, which keeps the rings separate.
+func chainEdges(edges [][2]Point, eps float64) (outers, holes [][]Point) {
+	key := func(p Point) [2]int64 {
+		return [2]int64{int64(math.Round(p.X / eps / 100)), int64(math.Round(p.Y / eps / 100))}
+	}
+	outgoing := make(map[[2]int64][]int)
+	for i, e := range edges {
+		k := key(e[0])
+		outgoing[k] = append(outgoing[k], i)
+	}
+
+	used := make([]bool, len(edges))
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var ring []Point
+		cur := start
+		for !used[cur] {
+			used[cur] = true
+			e := edges[cur]
+			ring = append(ring, e[0])
+
+			// Choose the next edge, which turns first clockwise from the
+			// reversed direction of the current edge.
+			back := math.Atan2(e[0].Y-e[1].Y, e[0].X-e[1].X)
+			next, best := -1, math.Inf(1)
+			for _, j := range outgoing[key(e[1])] {
+				if used[j] && j != start {
+					continue
+				}
+				o := edges[j]
+				turn := math.Mod(back-math.Atan2(o[1].Y-o[0].Y, o[1].X-o[0].X)+4*math.Pi, 2*math.Pi)
+				if turn < 1e-12 {
+					turn = 2 * math.Pi
+				}
+				if turn < best {
+					next, best = j, turn
+				}
+			}
+			if next < 0 {
+				break
+			}
+			cur = next
+		}
+
+		ring = cleanPolygon(ring)
+		if ring == nil {
+			continue
+		}
+		if signedArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	return outers, holes
+}
+
+// bridgeHole connects a hole to the outer boundary with a pair of coincident
+// edges, running between the closest pair of their vertices, which can see
+// each other past all holes of the polygon. The result is a single ring, as
+// required by the DCEL faces, whose half-edges along the bridge are twins
+// within the same face. Cells splits such rings into their boundaries again.
+func bridgeHole(outer, hole []Point, holes [][]Point) []Point {
+	bestI, bestJ, bestDist := -1, -1, math.Inf(1)
+	for i, p := range outer {
+		for j, q := range hole {
+			d := dist(p, q)
+			if d >= bestDist || crossesRing(p, q, outer) {
+				continue
+			}
+			blocked := false
+			for _, other := range holes {
+				if crossesRing(p, q, other) {
+					blocked = true
+					break
+				}
+			}
+			if blocked {
+				continue
+			}
+			bestI, bestJ, bestDist = i, j, d
+		}
+	}
+	if bestI < 0 {
+		return outer
+	}
+
+	var ring []Point
+	ring = append(ring, outer[:bestI+1]...)
+	for k := 0; k <= len(hole); k++ {
+		ring = append(ring, hole[(bestJ+k)%len(hole)])
+	}
+	ring = append(ring, outer[bestI:]...)
+	return ring
+}
+
+// crossesRing reports if segment pq properly crosses any edge of the ring.
+func crossesRing(p, q Point, ring []Point) bool {
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		if a == p || a == q || b == p || b == q {
+			continue
+		}
+		if _, _, ok := segmentIntersection(p, q, a, b); ok {
+			return true
+		}
+	}
+	return false
+}
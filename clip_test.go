@@ -0,0 +1,134 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestClipPolygon(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 300)
+	// A U-shaped polygon with holes, one of them in each arm.
+	clip := NewClipPolygon(
+		[]Point{{10, 10}, {290, 10}, {290, 290}, {200, 290}, {200, 100}, {100, 100}, {100, 290}, {10, 290}},
+		[]Point{{30, 30}, {60, 30}, {60, 60}, {30, 60}},
+		[]Point{{220, 150}, {260, 150}, {260, 200}, {220, 200}},
+	)
+	want := math.Abs(signedArea(clip.Outer)) - 900 - 2000
+	for _, m := range []Metric{Euclidean, Manhattan} {
+		for seed := int64(1); seed <= 3; seed++ {
+			v := NewWithMetric(randomSites(20, seed, bounds), bounds, m)
+			v.Clip = clip
+			v.Generate()
+			cells := v.Cells()
+			if area := cellsArea(cells); math.Abs(area-want) > 1e-6*want {
+				t.Errorf("%v, seed %d: cells cover %v, want %v", m, seed, area, want)
+			}
+
+			// Points inside a cell lie inside the polygon and are nearest
+			// to the site of the cell.
+			r := rand.New(rand.NewSource(seed))
+			for k := 0; k < 300; k++ {
+				p := Point{r.Float64() * 300, r.Float64() * 300}
+				nearest := math.Inf(1)
+				for i := range v.Sites {
+					nearest = math.Min(nearest, m.Distance(p, pointOf(&v.Sites[i])))
+				}
+				found := 0
+				for _, cell := range cells {
+					if !cellContains(cell, p) {
+						continue
+					}
+					found++
+					if boundaryDistance(cell.Polygon, p) > 1 && m.Distance(p, pointOf(cell.Site)) > nearest+1e-9 {
+						t.Fatalf("%v, seed %d: %v lies in the cell of a site, which is not the nearest", m, seed, p)
+					}
+				}
+				if found > 1 || found == 1 && !clip.Contains(p) {
+					t.Fatalf("%v, seed %d: %v lies in %d cells", m, seed, p, found)
+				}
+			}
+		}
+	}
+}
+
+func TestClipPolygonSites(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	clip := NewClipPolygon([]Point{{50, 0}, {100, 50}, {50, 100}, {0, 50}})
+	sites := SiteSlice{{X: 50, Y: 50, ID: 1}, {X: 40, Y: 40, ID: 2}, {X: 5, Y: 5, ID: 3}}
+
+	v := New(sites, bounds)
+	v.Clip = clip
+	v.Generate()
+	for _, site := range v.Sites {
+		if site.Outside != (site.ID == 3) {
+			t.Errorf("site %d is marked as outside: %v", site.ID, site.Outside)
+		}
+	}
+	if area := cellsArea(v.Cells()); math.Abs(area-5000) > 1e-6 {
+		t.Errorf("cells cover %v, want 5000", area)
+	}
+
+	// Dropped sites do not affect the cells of the other sites.
+	clip.DropOutside = true
+	w := New(sites, bounds)
+	w.Clip = clip
+	w.Generate()
+	for _, cell := range w.Cells() {
+		if cell.Site.ID == 3 {
+			t.Error("dropped site has a cell")
+		}
+	}
+	if !pointInPolygon(Point{20, 45}, cellOf(w.Cells(), 2).Polygon) {
+		t.Error("cell of a site next to a dropped site is too small")
+	}
+}
+
+// A single cell keeps the holes of the polygon.
+func TestClipPolygonHolesOfCell(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 100)
+	var holes [][]Point
+	for i := 0; i < 6; i++ {
+		x := float64(20 + 45*i)
+		holes = append(holes, []Point{{x, 20}, {x + 30, 20}, {x + 30, 80}, {x, 80}})
+	}
+	v := New(SiteSlice{{X: 150, Y: 10}}, bounds)
+	v.Clip = NewClipPolygon(rectPolygon(bounds), holes...)
+	v.Generate()
+	cells := v.Cells()
+	if len(cells) != 1 || len(cells[0].Holes) != 6 {
+		t.Fatalf("got %d cells, want a single cell with 6 holes", len(cells))
+	}
+	for _, hole := range cells[0].Holes {
+		if signedArea(hole) >= 0 {
+			t.Error("hole is not oriented clockwise")
+		}
+	}
+	if area := cellsArea(cells); math.Abs(area-(30000-6*1800)) > 1e-6 {
+		t.Errorf("cell covers %v, want %v", area, 30000-6*1800)
+	}
+}
+
+// cellContains reports if the point lies inside the cell and not in its holes.
+func cellContains(cell Cell, p Point) bool {
+	if !pointInPolygon(p, cell.Polygon) {
+		return false
+	}
+	for _, hole := range cell.Holes {
+		if pointInPolygon(p, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// cellOf returns the first cell of the site with the given ID.
+func cellOf(cells []Cell, id int64) Cell {
+	for _, cell := range cells {
+		if cell.Site.ID == id {
+			return cell
+		}
+	}
+	return Cell{}
+}
@@ -64,7 +64,14 @@ func btreeToJSON(node *voronoi.Node) []byte {
 func dumpDCEL(v *voronoi.Voronoi) string {
 	dcel := ""
 	for _, face := range v.DCEL.Faces {
-		dcel += fmt.Sprintf("Face #%d for site %v:\r\n", face.ID, face.Data.(*voronoi.Site))
+		switch data := face.Data.(type) {
+		case *voronoi.Site:
+			dcel += fmt.Sprintf("Face #%d for site %v:\r\n", face.ID, data)
+		case *voronoi.Segment:
+			dcel += fmt.Sprintf("Face #%d for segment %v:\r\n", face.ID, data)
+		default:
+			dcel += fmt.Sprintf("Face #%d:\r\n", face.ID)
+		}
 		dcel += fmt.Sprintln(strings.Repeat("-", 25))
 
 		edges := v.GetFaceHalfEdges(face)
@@ -0,0 +1,119 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+)
+
+// Crust reconstructs a curve from points sampled along it, using the crust
+// algorithm of Amenta, Bern and Eppstein. The vertices of the voronoi
+// diagram of the sites approximate the medial axis of the curve, so an edge
+// of the Delaunay dual of the diagram of the sites together with the
+// vertices, which connects two sites, is part of the curve. The vertices are
+// rounded to integer coordinates, like the sites. Returns the edges of the
+// curve, sorted by the IDs of the sites. Sites at the same location as an
+// earlier site are left out. The reconstruction is correct for dense enough
+// samples.
+func Crust(sites SiteSlice) []SiteEdge {
+	d := New(sites, siteBounds(sites)).dual()
+	if len(d.sites) < 2 {
+		return nil
+	}
+
+	// Sites of the second diagram are numbered by their index, which tells
+	// the sites from the vertices.
+	var all SiteSlice
+	for i, p := range d.points {
+		all = append(all, Site{X: int(p.X), Y: int(p.Y), ID: int64(i)})
+	}
+	for _, c := range d.centers {
+		if math.IsNaN(c.X) || math.IsInf(c.X, 0) || math.IsNaN(c.Y) || math.IsInf(c.Y, 0) {
+			continue
+		}
+		all = append(all, Site{X: int(math.Round(c.X)), Y: int(math.Round(c.Y)), ID: int64(len(all))})
+	}
+
+	var edges [][2]int
+	crust := New(all, siteBounds(all)).dual()
+	for _, e := range crust.edges {
+		a, b := crust.sites[e[0]].ID, crust.sites[e[1]].ID
+		if a < int64(len(d.sites)) && b < int64(len(d.sites)) {
+			edges = append(edges, [2]int{int(a), int(b)})
+		}
+	}
+	return siteEdges(d.sites, edges)
+}
+
+// NNCrust reconstructs a curve from points sampled along it, using the
+// nearest neighbour crust of Dey and Kumar. Each site is connected to its
+// nearest neighbour, and to the nearest of the neighbours, which lie on the
+// other side of it - at an angle of at least 90 degrees to the nearest one.
+// Both are taken from the neighbours, with which the cell of the site shares
+// an edge in the voronoi diagram of the sites. Returns the edges of the
+// curve, sorted by the IDs of the sites. Sites at the same location as an
+// earlier site are left out.
+func NNCrust(sites SiteSlice) []SiteEdge {
+	d := New(sites, siteBounds(sites)).dual()
+	unique, points := d.sites, d.points
+	if len(points) < 2 {
+		return nil
+	}
+
+	adjacent := make([][]int, len(points))
+	for _, e := range d.edges {
+		adjacent[e[0]] = append(adjacent[e[0]], e[1])
+		adjacent[e[1]] = append(adjacent[e[1]], e[0])
+	}
+
+	seen := make(map[[2]int]bool)
+	var edges [][2]int
+	addEdge := func(a, b int) {
+		if a > b {
+			a, b = b, a
+		}
+		if !seen[[2]int{a, b}] {
+			seen[[2]int{a, b}] = true
+			edges = append(edges, [2]int{a, b})
+		}
+	}
+	for i, p := range points {
+		nearest := -1
+		for _, j := range adjacent[i] {
+			if nearest < 0 || dist(p, points[j]) < dist(p, points[nearest]) {
+				nearest = j
+			}
+		}
+		if nearest < 0 {
+			continue
+		}
+		addEdge(i, nearest)
+
+		half := -1
+		for _, j := range adjacent[i] {
+			if j == nearest || angleBetween(p, points[nearest], points[j]) < math.Pi/2 {
+				continue
+			}
+			if half < 0 || dist(p, points[j]) < dist(p, points[half]) {
+				half = j
+			}
+		}
+		if half >= 0 {
+			addEdge(i, half)
+		}
+	}
+	return siteEdges(unique, edges)
+}
+
+// siteBounds returns the smallest rectangle containing the sites.
+func siteBounds(sites SiteSlice) image.Rectangle {
+	var r image.Rectangle
+	for i, site := range sites {
+		p := image.Rect(site.X, site.Y, site.X+1, site.Y+1)
+		if i == 0 {
+			r = p
+		} else {
+			r = r.Union(p)
+		}
+	}
+	return r
+}
@@ -0,0 +1,85 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestDual(t *testing.T) {
+	check := func(name string, sites SiteSlice, full bool) {
+		d := New(sites, siteBounds(sites)).dual()
+		var area float64
+		for i, tri := range d.triangles {
+			a, b, c := d.points[tri[0]], d.points[tri[1]], d.points[tri[2]]
+			if cross(a, b, c) <= 0 {
+				t.Fatalf("%s: triangle %v is not counter-clockwise", name, tri)
+			}
+			area += cross(a, b, c) / 2
+			center, _ := circumcenterOf(a, b, c)
+			if dist(center, d.centers[i]) > 1e-6 {
+				t.Fatalf("%s: triangle %v has center %v, want %v", name, tri, d.centers[i], center)
+			}
+			r := dist(center, a)
+			for _, p := range d.points {
+				if dist(center, p) < r-1e-6 {
+					t.Fatalf("%s: circumcircle of triangle %v contains %v", name, tri, p)
+				}
+			}
+		}
+
+		// The triangles cover the convex hull, but for thin triangles along it.
+		var hull []Point
+		for _, i := range convexHull(d.points) {
+			hull = append(hull, d.points[i])
+		}
+		if h := math.Abs(signedArea(hull)); area > h+1e-6 || area < 0.97*h || full && math.Abs(area-h) > 1e-6 {
+			t.Errorf("%s: triangles cover %v of the hull of area %v", name, area, h)
+		}
+	}
+	for seed := int64(1); seed <= 5; seed++ {
+		check("random", randomSites(200, seed, image.Rect(0, 0, 500, 400)), false)
+	}
+
+	// Sites on a grid lie on common circles.
+	var grid SiteSlice
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			grid = append(grid, Site{X: x * 10, Y: y * 10, ID: int64(len(grid))})
+		}
+	}
+	check("grid", grid, true)
+
+	line := SiteSlice{{X: 0, Y: 0, ID: 1}, {X: 20, Y: 10, ID: 2}, {X: 10, Y: 5, ID: 3}, {X: 10, Y: 5, ID: 4}}
+	if d := New(line, siteBounds(line)).dual(); len(d.sites) != 3 || len(d.edges) != 2 || len(d.triangles) != 0 {
+		t.Errorf("sites on a line have edges %v and triangles %v", d.edges, d.triangles)
+	}
+}
+
+func TestCrust(t *testing.T) {
+	// Points along a wavy ellipse.
+	const n = 80
+	var sites SiteSlice
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / n
+		sites = append(sites, Site{
+			X:  int(math.Round(500 + 300*math.Cos(a))),
+			Y:  int(math.Round(400 + 150*math.Sin(a) + 30*math.Sin(3*a))),
+			ID: int64(i),
+		})
+	}
+	for name, edges := range map[string][]SiteEdge{"crust": Crust(sites), "NN-crust": NNCrust(sites)} {
+		if len(edges) != n {
+			t.Errorf("%s has %d edges for %d points", name, len(edges), n)
+		}
+		for _, e := range edges {
+			if e.B-e.A != 1 && !(e.A == 0 && e.B == n-1) {
+				t.Errorf("%s connects points %d and %d, which do not follow each other", name, e.A, e.B)
+			}
+		}
+	}
+
+	if Crust(sites[:1]) != nil || NNCrust(sites[:1]) != nil {
+		t.Error("curve of a single point has edges")
+	}
+}
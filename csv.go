@@ -0,0 +1,212 @@
+package voronoi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions configures the reading of sites from CSV and plain-text files.
+type CSVOptions struct {
+	// Comma is the field delimiter of CSV files. Defaults to ','.
+	Comma rune
+	// Comment starts lines, which are ignored, when not zero.
+	Comment rune
+	// PlainText reads fields separated by runs of spaces and tabs, without
+	// quoting, instead of CSV.
+	PlainText bool
+	// Header reads the names of the columns from the first line. Otherwise
+	// the columns are named by Columns.
+	Header bool
+	// Columns names the columns of files without a header. Defaults to
+	// "x" and "y", followed by "id" if the first line has a third field.
+	Columns []string
+	// X, Y and ID name the columns holding the coordinates and the ID of each
+	// site. Default to "x", "y" and "id". Coordinates are rounded to the
+	// nearest integer. The ID column is optional, and sites get their line
+	// number as ID when it is missing. When it is present, every line must
+	// have an ID.
+	X, Y, ID string
+	// Extra names the columns, whose values are stored into the Data of each
+	// site as a map[string]string, keyed by the column names. Data is left
+	// nil when no extra columns are given.
+	Extra []string
+}
+
+// ReadSitesCSV reads a site from each line of a CSV or plain-text file.
+// Empty lines are skipped. Errors report the line, at which they occur.
+func ReadSitesCSV(r io.Reader, opts CSVOptions) (SiteSlice, error) {
+	next := csvRecords(r, opts)
+
+	columns := opts.Columns
+	if opts.Header {
+		record, _, err := next()
+		if err == io.EOF {
+			return nil, errors.New("missing header")
+		}
+		if err != nil {
+			return nil, err
+		}
+		columns = record
+	} else if columns == nil {
+		// The default columns depend on the first line, which is read again
+		// by the loop below.
+		record, line, err := next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		columns = []string{"x", "y"}
+		if len(record) > 2 {
+			columns = append(columns, "id")
+		}
+		read := next
+		first := true
+		next = func() ([]string, int, error) {
+			if first {
+				first = false
+				return record, line, nil
+			}
+			return read()
+		}
+	}
+	index := make(map[string]int, len(columns))
+	for i, name := range columns {
+		name = strings.TrimSpace(name)
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	column := func(name, fallback string) (int, error) {
+		if name == "" {
+			name = fallback
+		}
+		i, ok := index[name]
+		if !ok {
+			return -1, fmt.Errorf("missing column %q", name)
+		}
+		return i, nil
+	}
+
+	xColumn, err := column(opts.X, "x")
+	if err != nil {
+		return nil, err
+	}
+	yColumn, err := column(opts.Y, "y")
+	if err != nil {
+		return nil, err
+	}
+	idColumn, err := column(opts.ID, "id")
+	if err != nil && opts.ID != "" {
+		return nil, err
+	}
+	extra := make([]int, len(opts.Extra))
+	for i, name := range opts.Extra {
+		if extra[i], err = column(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var sites SiteSlice
+	for {
+		record, line, err := next()
+		if err == io.EOF {
+			return sites, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(i int) (string, error) {
+			if i >= len(record) {
+				return "", fmt.Errorf("line %d: missing column %q", line, columns[i])
+			}
+			return strings.TrimSpace(record[i]), nil
+		}
+		coordinate := func(i int) (int, error) {
+			s, err := field(i)
+			if err != nil {
+				return 0, err
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsNaN(f) || math.Abs(f) > math.MaxInt32 {
+				return 0, fmt.Errorf("line %d: invalid %s coordinate %q", line, columns[i], s)
+			}
+			return int(math.Round(f)), nil
+		}
+
+		site := Site{ID: int64(line)}
+		if site.X, err = coordinate(xColumn); err != nil {
+			return nil, err
+		}
+		if site.Y, err = coordinate(yColumn); err != nil {
+			return nil, err
+		}
+		if idColumn >= 0 {
+			s, err := field(idColumn)
+			if err != nil {
+				return nil, err
+			}
+			if site.ID, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid id %q", line, s)
+			}
+		}
+		if len(extra) > 0 {
+			data := make(map[string]string, len(extra))
+			for k, i := range extra {
+				if data[opts.Extra[k]], err = field(i); err != nil {
+					return nil, err
+				}
+			}
+			site.Data = data
+		}
+		sites = append(sites, site)
+	}
+}
+
+// csvRecords returns a function, which reads the next non-empty record and
+// the line, at which it starts, until it returns io.EOF.
+func csvRecords(r io.Reader, opts CSVOptions) func() ([]string, int, error) {
+	if opts.PlainText {
+		scanner := bufio.NewScanner(r)
+		line := 0
+		return func() ([]string, int, error) {
+			for scanner.Scan() {
+				line++
+				text := scanner.Text()
+				if opts.Comment != 0 && strings.HasPrefix(strings.TrimSpace(text), string(opts.Comment)) {
+					continue
+				}
+				if fields := strings.Fields(text); len(fields) > 0 {
+					return fields, line, nil
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, line, fmt.Errorf("line %d: %v", line+1, err)
+			}
+			return nil, line, io.EOF
+		}
+	}
+
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.Comment = opts.Comment
+	reader.FieldsPerRecord = -1
+	return func() ([]string, int, error) {
+		record, err := reader.Read()
+		if err != nil {
+			// Parse errors report their line already.
+			return nil, 0, err
+		}
+		line, _ := reader.FieldPos(0)
+		return record, line, nil
+	}
+}
@@ -0,0 +1,116 @@
+package voronoi
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSitesCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts CSVOptions
+		want SiteSlice
+	}{
+		{
+			"mapped columns",
+			"name,lon,lat,key\n# comment\nA,1.4,2.6,7\n\n\"B, inc\",3,-4,8\n",
+			CSVOptions{Header: true, Comment: '#', X: "lon", Y: "lat", ID: "key", Extra: []string{"name"}},
+			SiteSlice{
+				{X: 1, Y: 3, ID: 7, Data: map[string]string{"name": "A"}},
+				{X: 3, Y: -4, ID: 8, Data: map[string]string{"name": "B, inc"}},
+			},
+		},
+		{
+			"IDs from line numbers",
+			"y;x\n10;20\n30;40\n",
+			CSVOptions{Header: true, Comma: ';'},
+			SiteSlice{{X: 20, Y: 10, ID: 2}, {X: 40, Y: 30, ID: 3}},
+		},
+		{
+			"default columns",
+			"1,2,30\n4,5,40\n",
+			CSVOptions{},
+			SiteSlice{{X: 1, Y: 2, ID: 30}, {X: 4, Y: 5, ID: 40}},
+		},
+		{
+			"given columns",
+			"5,1,2\n",
+			CSVOptions{Columns: []string{"id", "x", "y"}},
+			SiteSlice{{X: 1, Y: 2, ID: 5}},
+		},
+		{
+			"plain text",
+			"  1\t2  red\n# comment\n\n5 6 blue\n",
+			CSVOptions{PlainText: true, Comment: '#', Columns: []string{"x", "y", "color"}, Extra: []string{"color"}},
+			SiteSlice{
+				{X: 1, Y: 2, ID: 1, Data: map[string]string{"color": "red"}},
+				{X: 5, Y: 6, ID: 4, Data: map[string]string{"color": "blue"}},
+			},
+		},
+		{"empty", "", CSVOptions{}, nil},
+	}
+	for _, test := range tests {
+		got, err := ReadSitesCSV(strings.NewReader(test.in), test.opts)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadSitesCSVErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts CSVOptions
+		want string
+	}{
+		{"x,y\n1,2\n\n3,zz\n", CSVOptions{Header: true}, "line 4: invalid y coordinate"},
+		{"x,y,id\n1,2,3\n4,5\n", CSVOptions{Header: true}, "line 3: missing column \"id\""},
+		{"1,2,3\n4,5\n", CSVOptions{}, "line 2: missing column \"id\""},
+		{"x,y,id\n1,2,a\n", CSVOptions{Header: true}, "line 2: invalid id"},
+		{"x,y\n1,2\n3,\"4\n", CSVOptions{Header: true}, "line 3"},
+		{"x,y\n1,NaN\n", CSVOptions{Header: true}, "line 2: invalid y coordinate"},
+		{"1 2\n3 4\n", CSVOptions{PlainText: true, Columns: []string{"x", "y", "tag"}, Extra: []string{"tag"}}, "line 1: missing column \"tag\""},
+		{"a,b\n", CSVOptions{Header: true}, "missing column \"x\""},
+		{"x,y\n", CSVOptions{Header: true, ID: "key"}, "missing column \"key\""},
+		{"", CSVOptions{Header: true}, "missing header"},
+	}
+	for _, test := range tests {
+		_, err := ReadSitesCSV(strings.NewReader(test.in), test.opts)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got error %v, want %q", test.in, err, test.want)
+		}
+	}
+}
+
+// Sites written as CSV and as plain text are read back unchanged.
+func TestReadSitesCSVRoundTrip(t *testing.T) {
+	sites := randomSites(50, 1, image.Rect(-100, -100, 100, 100))
+	for _, opts := range []CSVOptions{{Header: true}, {PlainText: true}} {
+		var b strings.Builder
+		if opts.Header {
+			b.WriteString("id,x,y\n")
+		}
+		for _, site := range sites {
+			if opts.PlainText {
+				fmt.Fprintf(&b, "%d\t%d %d\n", site.X, site.Y, site.ID)
+			} else {
+				fmt.Fprintf(&b, "%d,%d,%d\n", site.ID, site.X, site.Y)
+			}
+		}
+		got, err := ReadSitesCSV(strings.NewReader(b.String()), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, sites) {
+			t.Errorf("plain text %v: got %v, want %v", opts.PlainText, got, sites)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package voronoi
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Density is a non-negative weight of the points of the plane, which pulls
+// the sites of a centroidal voronoi tessellation towards its heavier parts.
+type Density func(x, y float64) float64
+
+// ImageDensity returns a density, which is the darkness of the pixels of a
+// grayscale image: 1 for black and 0 for white, as used for stippling.
+// Points outside the image take the density of the nearest pixel.
+func ImageDensity(img image.Image) Density {
+	b := img.Bounds()
+	return func(x, y float64) float64 {
+		px := int(math.Floor(x))
+		py := int(math.Floor(y))
+		if px < b.Min.X {
+			px = b.Min.X
+		} else if px >= b.Max.X {
+			px = b.Max.X - 1
+		}
+		if py < b.Min.Y {
+			py = b.Min.Y
+		} else if py >= b.Max.Y {
+			py = b.Max.Y - 1
+		}
+		gray := color.Gray16Model.Convert(img.At(px, py)).(color.Gray16)
+		return 1 - float64(gray.Y)/0xffff
+	}
+}
+
+// CVTOptions configures the relaxation of a diagram towards a centroidal
+// voronoi tessellation.
+type CVTOptions struct {
+	// Density weights the points of the cells. A nil density is uniform,
+	// which makes the relaxation the same as Lloyd's algorithm.
+	Density Density
+	// Iterations is the largest number of iterations, 1 if zero.
+	Iterations int
+	// Resolution is the size of the triangles, over which the density is
+	// sampled, 1 if zero. Only used with a density.
+	Resolution float64
+}
+
+// Relax moves each site to the centroid of its cell, weighted by the
+// density, and generates the diagram again, for the given number of
+// iterations or until no site moves. The cells are taken from the faces of
+// the diagram, as returned by Cells, which is generated first if it has none.
+// Sites keep their IDs and data, and only their coordinates change. As the
+// coordinates of sites are integers, centroids are rounded to the nearest
+// integer point, so sites stop moving once they are within half a unit of
+// their centroid. Cells of clipped diagrams are integrated over all of their
+// pieces, leaving out their holes, while sites of periodic diagrams are
+// wrapped back into the bounds. Sites without a cell, or whose cell has no
+// weight, stay in place. Returns the number of iterations done.
+// Not supported for farthest-point diagrams and diagrams with segment sites.
+func (v *Voronoi) Relax(opts CVTOptions) (int, error) {
+	if v.Farthest || len(v.Segments) > 0 {
+		return 0, errors.New("relaxation is not supported for farthest-point diagrams and segment sites")
+	}
+	iterations := opts.Iterations
+	if iterations <= 0 {
+		iterations = 1
+	}
+	resolution := opts.Resolution
+	if resolution <= 0 {
+		resolution = 1
+	}
+
+	if v.DCEL == nil || len(v.DCEL.Faces) == 0 {
+		v.Generate()
+	}
+	domain := newTorus(v.Bounds, v.Wrap)
+	done := 0
+	for done < iterations {
+		cells := v.Cells()
+		done++
+
+		var order []*Site
+		mass := make(map[*Site]float64)
+		moment := make(map[*Site]Point)
+		for _, cell := range cells {
+			site := cell.Site
+			if _, ok := mass[site]; !ok {
+				order = append(order, site)
+			}
+			for k, ring := range append([][]Point{cell.Polygon}, cell.Holes...) {
+				m, c := integratePolygon(ring, opts.Density, resolution)
+				if k > 0 {
+					m = -m
+				}
+				mass[site] += m
+				moment[site] = Point{moment[site].X + m*c.X, moment[site].Y + m*c.Y}
+			}
+		}
+
+		moved := false
+		for _, site := range order {
+			if mass[site] <= 0 {
+				continue
+			}
+			c := image.Point{
+				int(math.Round(moment[site].X / mass[site])),
+				int(math.Round(moment[site].Y / mass[site])),
+			}
+			c = domain.wrap(c)
+			if c.X != site.X || c.Y != site.Y {
+				site.X, site.Y = c.X, c.Y
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+		v.Generate()
+	}
+	return done, nil
+}
+
+// integratePolygon returns the integral of the density over a polygon and
+// the centroid of the polygon weighted by the density. The polygon is split
+// into a fan of triangles with signed areas, which works for concave
+// polygons too, and each triangle is subdivided into triangles no larger
+// than the resolution, sampled at their centroids.
+func integratePolygon(poly []Point, density Density, resolution float64) (float64, Point) {
+	if len(poly) < 3 {
+		return 0, Point{}
+	}
+	if density == nil {
+		area := signedArea(poly)
+		if area == 0 {
+			return 0, Point{}
+		}
+		var cx, cy float64
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			f := p.X*q.Y - q.X*p.Y
+			cx += (p.X + q.X) * f
+			cy += (p.Y + q.Y) * f
+		}
+		return math.Abs(area), Point{cx / (6 * area), cy / (6 * area)}
+	}
+
+	var mass, mx, my float64
+	a := poly[0]
+	for i := 1; i+1 < len(poly); i++ {
+		b, c := poly[i], poly[i+1]
+		area := cross(a, b, c) / 2
+		if area == 0 {
+			continue
+		}
+		longest := math.Max(dist(a, b), math.Max(dist(b, c), dist(c, a)))
+		n := int(math.Ceil(longest / resolution))
+		if n < 1 {
+			n = 1
+		}
+		sub := area / float64(n*n)
+
+		// Points of the subdivision are a + (b - a)*i/n + (c - a)*j/n.
+		at := func(i, j float64) Point {
+			return Point{
+				a.X + ((b.X-a.X)*i+(c.X-a.X)*j)/float64(n),
+				a.Y + ((b.Y-a.Y)*i+(c.Y-a.Y)*j)/float64(n),
+			}
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; i+j < n; j++ {
+				// Triangle pointing the same way as abc
+				p := at(float64(i)+1.0/3, float64(j)+1.0/3)
+				w := density(p.X, p.Y) * sub
+				mass, mx, my = mass+w, mx+w*p.X, my+w*p.Y
+				if i+j+1 < n {
+					// Triangle pointing the opposite way
+					p := at(float64(i)+2.0/3, float64(j)+2.0/3)
+					w := density(p.X, p.Y) * sub
+					mass, mx, my = mass+w, mx+w*p.X, my+w*p.Y
+				}
+			}
+		}
+	}
+
+	// The polygon may be oriented either way.
+	if mass < 0 {
+		mass, mx, my = -mass, -mx, -my
+	}
+	if mass == 0 {
+		return 0, Point{}
+	}
+	return mass, Point{mx / mass, my / mass}
+}
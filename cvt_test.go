@@ -0,0 +1,103 @@
+package voronoi
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestIntegratePolygon(t *testing.T) {
+	square := []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	mass, centroid := integratePolygon(square, nil, 1)
+	if math.Abs(mass-100) > 1e-9 || math.Abs(centroid.X-5) > 1e-9 || math.Abs(centroid.Y-5) > 1e-9 {
+		t.Errorf("got mass %v and centroid %v, want 100 and (5, 5)", mass, centroid)
+	}
+	// The mass of a linear density is exact, its moments close.
+	mass, centroid = integratePolygon(reversePolygon(square), func(x, y float64) float64 { return x }, 0.5)
+	if math.Abs(mass-500) > 1e-6 || math.Abs(centroid.X-20.0/3) > 1e-2 || math.Abs(centroid.Y-5) > 1e-2 {
+		t.Errorf("got mass %v and centroid %v, want 500 and (6.67, 5)", mass, centroid)
+	}
+}
+
+func TestRelax(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 300)
+	v := New(randomSites(40, 1, bounds), bounds)
+	n, err := v.Relax(CVTOptions{Iterations: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n >= 200 {
+		t.Fatalf("relaxation did not converge in %d iterations", n)
+	}
+	// Converged sites lie within half a unit of the centroids of their cells.
+	cells := v.Cells()
+	if area := cellsArea(cells); math.Abs(area-90000) > 1e-6 {
+		t.Errorf("cells cover %v, want 90000", area)
+	}
+	for _, cell := range cells {
+		_, c := integratePolygon(cell.Polygon, nil, 1)
+		if math.Abs(c.X-float64(cell.Site.X)) > 0.5+1e-9 || math.Abs(c.Y-float64(cell.Site.Y)) > 0.5+1e-9 {
+			t.Errorf("site %d at (%d, %d) is away from the centroid %v", cell.Site.ID, cell.Site.X, cell.Site.Y, c)
+		}
+	}
+
+	// Sites gather where the image is dark.
+	img := image.NewGray(bounds)
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 255 / 300)})
+		}
+	}
+	if _, err := v.Relax(CVTOptions{Iterations: 30, Density: ImageDensity(img), Resolution: 2}); err != nil {
+		t.Fatal(err)
+	}
+	left := 0
+	for _, site := range v.Sites {
+		if site.X < 150 {
+			left++
+		}
+	}
+	if left <= 20 {
+		t.Errorf("only %d of 40 sites lie in the dark half", left)
+	}
+}
+
+func TestRelaxPeriodic(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	v := NewPeriodic(randomSites(20, 2, bounds), bounds, WrapXY)
+	if _, err := v.Relax(CVTOptions{Iterations: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, site := range v.Sites {
+		if !(image.Point{site.X, site.Y}).In(bounds) {
+			t.Errorf("site %d at (%d, %d) is not wrapped into the bounds", site.ID, site.X, site.Y)
+		}
+	}
+	if area := cellsArea(v.Cells()); math.Abs(area-20000) > 1e-6*20000 {
+		t.Errorf("cells cover %v, want 20000", area)
+	}
+}
+
+func TestRelaxHoles(t *testing.T) {
+	clip := NewClipPolygon([]Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}})
+	clip.Holes = [][]Point{{{60, 40}, {60, 60}, {80, 60}, {80, 40}}}
+	v := New(SiteSlice{{X: 20, Y: 50, ID: 1}}, image.Rect(0, 0, 100, 100))
+	v.Clip = clip
+	v.Generate()
+	if _, err := v.Relax(CVTOptions{Iterations: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The centroid of the square without the hole is (48.75, 50).
+	if v.Sites[0].X != 49 || v.Sites[0].Y != 50 {
+		t.Errorf("site moved to (%d, %d), want (49, 50)", v.Sites[0].X, v.Sites[0].Y)
+	}
+}
+
+func TestRelaxInvalid(t *testing.T) {
+	v := New(SiteSlice{{X: 10, Y: 10}, {X: 50, Y: 50, ID: 1}, {X: 80, Y: 20, ID: 2}}, image.Rect(0, 0, 100, 100))
+	v.Farthest = true
+	if _, err := v.Relax(CVTOptions{}); err == nil {
+		t.Error("farthest-point diagram relaxed")
+	}
+}
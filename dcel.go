@@ -1,7 +1,7 @@
 package voronoi
 
 import (
-	"fmt"
+	"image"
 	"math"
 	"sort"
 
@@ -74,6 +74,9 @@ func (s halfEdgesByCCW) UpdateLinks() {
 }
 
 // ReorderFaceEdges reorders face half-edges in a clockwise way, while also removing duplicates.
+//
+// Deprecated: faces of generated diagrams are linked in order already, and
+// relinking the half-edges of a face with holes breaks it apart.
 func (v *Voronoi) ReorderFaceEdges(face *dcel.Face) {
 	var edges []*dcel.HalfEdge
 	//exists := make(map[string]bool)
@@ -98,22 +101,41 @@ func (v *Voronoi) ReorderFaceEdges(face *dcel.Face) {
 }
 
 // GetFaceHalfEdges returns the half-edges that form the boundary of a face (cell).
+// Faces of generated diagrams are linked in order, and their half-edges are
+// returned in that order. While the sweep line is in progress, they are
+// sorted counter-clockwise instead. The links are not modified.
 func (v *Voronoi) GetFaceHalfEdges(face *dcel.Face) []*dcel.HalfEdge {
-	v.ReorderFaceEdges(face)
+	edges := faceHalfEdges(face)
+	if !linkedInOrder(edges) {
+		sort.Sort(halfEdgesByCCW(edges))
+	}
+	return edges
+}
 
+// faceHalfEdges follows the Next links of the half-edges of a face, until
+// they end or return to a half-edge already visited.
+func faceHalfEdges(face *dcel.Face) []*dcel.HalfEdge {
 	var edges []*dcel.HalfEdge
-	edge := face.HalfEdge
-	for edge != nil {
+	seen := make(map[*dcel.HalfEdge]bool)
+	for edge := face.HalfEdge; edge != nil && !seen[edge]; edge = edge.Next {
+		seen[edge] = true
 		edges = append(edges, edge)
-		edge = edge.Next
-		if edge == face.HalfEdge {
-			break
-		}
 	}
-
 	return edges
 }
 
+// linkedInOrder reports if the half-edges form a closed boundary, where
+// each half-edge starts at the target of the previous one.
+func linkedInOrder(edges []*dcel.HalfEdge) bool {
+	for i, edge := range edges {
+		next := edges[(i+1)%len(edges)]
+		if edge.Target == nil || edge.Next != next || next.Twin == nil || next.Twin.Target != edge.Target {
+			return false
+		}
+	}
+	return len(edges) > 0
+}
+
 // verticesByCCW implements a slice of vertices that sort in counter-clockwise order.
 type verticesByCCW []*dcel.Vertex
 
@@ -141,33 +163,278 @@ func (s verticesByCCW) Less(i, j int) bool {
 }
 
 // GetFaceVertices returns the vertices that form the boundary of a face (cell),
-// sorted in counter-clockwise order.
+// each once. Vertices of faces linked in order are returned in the order of
+// the half-edges, which is counter-clockwise (with Y pointing up), otherwise
+// they are sorted in counter-clockwise order.
 func (v *Voronoi) GetFaceVertices(face *dcel.Face) []*dcel.Vertex {
+	edges := faceHalfEdges(face)
+	linked := linkedInOrder(edges)
+
 	var vertices []*dcel.Vertex
-	exists := make(map[string]bool)
-	edge := face.HalfEdge
-	for edge != nil {
+	exists := make(map[image.Point]bool)
+	add := func(vertex *dcel.Vertex) {
+		if p := (image.Point{vertex.X, vertex.Y}); !exists[p] {
+			exists[p] = true
+			vertices = append(vertices, vertex)
+		}
+	}
+	for _, edge := range edges {
 		if edge.Target != nil {
-			id := fmt.Sprintf("%v", edge.Target)
-			if !exists[id] {
-				exists[id] = true
-				vertices = append(vertices, edge.Target)
+			add(edge.Target)
+		}
+		if !linked && edge.Twin != nil && edge.Twin.Target != nil {
+			add(edge.Twin.Target)
+		}
+	}
+
+	if !linked {
+		sort.Sort(verticesByCCW(vertices))
+	}
+	return vertices
+}
+
+// addSiteFaces adds a face for each site to the DCEL, with the boundary
+// of the face given as a polygon, and links the site and the face.
+// A site may be listed several times, if its cell consists of several
+// polygons - the site is then linked to the first of its faces.
+func addSiteFaces(d *dcel.DCEL, sites []*Site, polygons [][]Point, domain torus) {
+	faces := addPolygonFaces(d, polygons, domain)
+	linked := make(map[*Site]bool)
+	for i, face := range faces {
+		if face == nil {
+			continue
+		}
+		face.ID = sites[i].ID
+		face.Data = sites[i]
+		if !linked[sites[i]] {
+			linked[sites[i]] = true
+			sites[i].Face = face
+		}
+	}
+}
+
+// addPolygonFaces adds a face for each polygon to the DCEL and returns the
+// faces in the order of the polygons. Faces of polygons, which degenerate
+// to less than three vertices, are nil. Polygon vertices are rounded to integer
+// coordinates and shared between neighbouring faces, so that the half-edges
+// on the common border of two cells become twins. Half-edges on the outer
+// border of the diagram get a twin that belongs to no face.
+// Half-edges of each face are linked counter-clockwise (with Y pointing up).
+// On a periodic domain, vertices are wrapped into the unit cell and edges
+// leaving the unit cell are connected to their twins on the opposite side.
+func addPolygonFaces(d *dcel.DCEL, polygons [][]Point, domain torus) []*dcel.Face {
+	polygons = splitAtVertices(polygons, domain)
+
+	// Round vertices and drop the ones collapsing into their neighbours.
+	rings := make([][]image.Point, len(polygons))
+	vertices := make(map[image.Point]*dcel.Vertex)
+	var points []image.Point
+	for i, poly := range polygons {
+		ring := roundRing(poly)
+		if ring == nil {
+			continue
+		}
+		rings[i] = ring
+		for _, ip := range ring {
+			if wp := domain.wrap(ip); vertices[wp] == nil {
+				vertices[wp] = d.NewVertex(wp.X, wp.Y)
+				points = append(points, domain.images(wp)...)
 			}
 		}
+	}
 
-		if edge.Twin != nil && edge.Twin.Target != nil {
-			id := fmt.Sprintf("%v", edge.Twin.Target)
-			if !exists[id] {
-				exists[id] = true
-				vertices = append(vertices, edge.Twin.Target)
+	// Vertices of edges approximated differently on both sides, like curved
+	// edges, may still lie near the middle of an edge of the neighbour after
+	// rounding. Split such edges, so that both sides of a border consist of
+	// the same half-edges.
+	for i, ring := range rings {
+		var split []image.Point
+		for j, p := range ring {
+			q := ring[(j+1)%len(ring)]
+			split = append(split, p)
+			split = append(split, pointsOnSegment(points, p, q)...)
+		}
+		rings[i] = split
+	}
+
+	faces := make([]*dcel.Face, len(polygons))
+	// Rounding may leave the same directed edge in more than one ring, so
+	// each key maps to all of its half-edges.
+	edges := make(map[[2]image.Point][]*dcel.HalfEdge)
+	var keys [][2]image.Point
+	var halfEdges []*dcel.HalfEdge
+	for i, ring := range rings {
+		if ring == nil {
+			continue
+		}
+		face := d.NewFace()
+		faces[i] = face
+
+		var first, prev *dcel.HalfEdge
+		for j, p := range ring {
+			q := ring[(j+1)%len(ring)]
+			key := [2]image.Point{domain.wrap(p), domain.wrap(q)}
+			he := &dcel.HalfEdge{Target: vertices[key[1]]}
+			d.HalfEdges = append(d.HalfEdges, he)
+			edges[key] = append(edges[key], he)
+			keys = append(keys, key)
+			halfEdges = append(halfEdges, he)
+			if prev != nil {
+				prev.Next, he.Prev = he, prev
+			} else {
+				first = he
 			}
+			prev = he
 		}
-		edge = edge.Next
-		if edge == face.HalfEdge {
-			break
+		prev.Next, first.Prev = first, prev
+		face.HalfEdge = first
+	}
+
+	for i, key := range keys {
+		he := halfEdges[i]
+		if he.Twin != nil {
+			continue
 		}
+		for _, twin := range edges[[2]image.Point{key[1], key[0]}] {
+			if twin.Twin == nil && twin != he {
+				he.Twin, twin.Twin = twin, he
+				break
+			}
+		}
+		if he.Twin != nil {
+			continue
+		}
+		twin := &dcel.HalfEdge{Target: vertices[key[0]], Twin: he}
+		he.Twin = twin
+		d.HalfEdges = append(d.HalfEdges, twin)
 	}
 
-	sort.Sort(verticesByCCW(vertices))
-	return vertices
+	return faces
+}
+
+// splitAtVertices returns copies of the polygons, whose edges are split at the
+// vertices of the other polygons lying on them. A vertex of one cell may lie
+// in the middle of an edge of its neighbour, which rounding would move off
+// the edge, leaving a gap between the cells.
+func splitAtVertices(polygons [][]Point, domain torus) [][]Point {
+	var points []Point
+	seen := make(map[image.Point]bool)
+	for _, poly := range polygons {
+		for _, p := range poly {
+			key := image.Point{int(math.Round(p.X * 1e6)), int(math.Round(p.Y * 1e6))}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			for _, s := range domain.shifts() {
+				points = append(points, Point{p.X + s.X, p.Y + s.Y})
+			}
+		}
+	}
+
+	split := make([][]Point, len(polygons))
+	for i, poly := range polygons {
+		for j, a := range poly {
+			b := poly[(j+1)%len(poly)]
+			split[i] = append(split[i], a)
+			l := dist(a, b)
+			var found []Point
+			var params []float64
+			for _, c := range points {
+				t := project(a, b, c)
+				if t*l <= 1e-6 || (1-t)*l <= 1e-6 || math.Abs(cross(a, b, c)) > 1e-6*l {
+					continue
+				}
+				found = append(found, c)
+				params = append(params, t)
+			}
+			sort.Sort(floatPointsByParam{found, params})
+			split[i] = append(split[i], found...)
+		}
+	}
+	return split
+}
+
+// floatPointsByParam sorts points by their parameter along a segment.
+type floatPointsByParam struct {
+	points []Point
+	params []float64
+}
+
+func (s floatPointsByParam) Len() int           { return len(s.points) }
+func (s floatPointsByParam) Less(i, j int) bool { return s.params[i] < s.params[j] }
+func (s floatPointsByParam) Swap(i, j int) {
+	s.points[i], s.points[j] = s.points[j], s.points[i]
+	s.params[i], s.params[j] = s.params[j], s.params[i]
+}
+
+// roundRing rounds the vertices of a polygon to integer coordinates, orders
+// them counter-clockwise and drops the ones collapsing into their neighbours.
+// Returns nil for polygons degenerating to less than three vertices.
+func roundRing(poly []Point) []image.Point {
+	if signedArea(poly) < 0 {
+		poly = reversePolygon(poly)
+	}
+	var ring []image.Point
+	for _, p := range poly {
+		ip := roundPoint(p)
+		if len(ring) > 0 && ring[len(ring)-1] == ip {
+			continue
+		}
+		ring = append(ring, ip)
+	}
+	for len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 {
+		return nil
+	}
+	return ring
+}
+
+// roundPoint rounds a point to integer coordinates. Coordinates are first
+// rounded to a fine grid, so that copies of a vertex computed in different
+// cells, which differ only by floating point errors, round the same way.
+func roundPoint(p Point) image.Point {
+	const grid = 1e6
+	return image.Point{
+		int(math.Round(math.Round(p.X*grid) / grid)),
+		int(math.Round(math.Round(p.Y*grid) / grid)),
+	}
+}
+
+// pointsOnSegment returns the points lying strictly inside segment pq,
+// ordered from p to q.
+func pointsOnSegment(points []image.Point, p, q image.Point) []image.Point {
+	a, b := Point{float64(p.X), float64(p.Y)}, Point{float64(q.X), float64(q.Y)}
+	l := dist(a, b)
+	var found []image.Point
+	var params []float64
+	for _, ip := range points {
+		if ip == p || ip == q {
+			continue
+		}
+		c := Point{float64(ip.X), float64(ip.Y)}
+		t := project(a, b, c)
+		if t <= 0 || t >= 1 || math.Abs(cross(a, b, c)) > 0.5*l {
+			continue
+		}
+		found = append(found, ip)
+		params = append(params, t)
+	}
+	sort.Sort(pointsByParam{found, params})
+	return found
+}
+
+// pointsByParam sorts points by their parameter along a segment.
+type pointsByParam struct {
+	points []image.Point
+	params []float64
+}
+
+func (s pointsByParam) Len() int           { return len(s.points) }
+func (s pointsByParam) Less(i, j int) bool { return s.params[i] < s.params[j] }
+func (s pointsByParam) Swap(i, j int) {
+	s.points[i], s.points[j] = s.points[j], s.points[i]
+	s.params[i], s.params[j] = s.params[j], s.params[i]
 }
@@ -0,0 +1,191 @@
+package voronoi
+
+import (
+	"math"
+	"sort"
+)
+
+// SiteEdge is an edge of a graph on the sites, given by the IDs of its end
+// points, with A < B.
+type SiteEdge struct {
+	A, B int64
+}
+
+// delaunayDual is the Delaunay triangulation of the sites of a diagram, read
+// from the cells of their nearest-point diagram: two sites are connected if
+// their cells share an edge, and the sites around each vertex of the cells
+// form triangles, whose circumcircle is centered at the vertex. The cells are
+// computed within a box around the sites, so thin triangles along the convex
+// hull, whose circumradius exceeds the extent of the sites, may be missing.
+type delaunayDual struct {
+	sites     []*Site // sites with a cell
+	points    []Point
+	edges     [][2]int // indices of the sites, with the smaller one first
+	triangles [][3]int // ordered counter-clockwise (with Y pointing up)
+	centers   []Point  // voronoi vertex of each triangle
+	// around lists the neighbours across the edges of each cell, in
+	// counter-clockwise order, with -1 for edges on the border of the box,
+	// within which the cells are computed.
+	around [][]int
+}
+
+// dual returns the Delaunay dual of the sites of the diagram. The cells kept
+// by Insert and Delete are used if the diagram has them, otherwise the cells
+// of the plain nearest-point diagram of the sites are computed, whatever the
+// options of the diagram. Of several sites at the same location, only the
+// first one is part of the dual.
+func (v *Voronoi) dual() *delaunayDual {
+	e, sites := v.editor, v.Sites
+	if e == nil {
+		// IDs of the sites need not be unique, so the cells are computed for
+		// their indices.
+		sites = make(SiteSlice, len(v.Sites))
+		for i, site := range v.Sites {
+			sites[i] = Site{X: site.X, Y: site.Y, ID: int64(i)}
+		}
+		e, _ = newEditor(sites, v.Bounds)
+	}
+
+	d := &delaunayDual{}
+	var ids []int64 // IDs of the sites in the editor
+	index := make(map[int64]int)
+	for i := range sites {
+		if id := sites[i].ID; e.cells[id] != nil {
+			index[id] = len(d.sites)
+			ids = append(ids, id)
+			d.sites = append(d.sites, &v.Sites[i])
+			d.points = append(d.points, pointOf(&sites[i]))
+		}
+	}
+
+	for i, id := range ids {
+		for _, k := range sortedIDs(e.neighbors[id]) {
+			if j := index[k]; j > i {
+				d.edges = append(d.edges, [2]int{i, j})
+			}
+		}
+	}
+
+	// The neighbour across an edge is equidistant from its midpoint.
+	d.around = make([][]int, len(ids))
+	for i, id := range ids {
+		a := d.points[i]
+		cell := e.cells[id]
+		for k, p := range cell {
+			m := lerp(p, cell[(k+1)%len(cell)], 0.5)
+			dm := dist(m, a)
+			across, best := -1, 1e-6*(1+dm)
+			for n := range e.neighbors[id] {
+				if diff := math.Abs(dist(m, d.points[index[n]]) - dm); diff < best {
+					across, best = index[n], diff
+				}
+			}
+			d.around[i] = append(d.around[i], across)
+		}
+	}
+
+	// A vertex shared by the cells of more than three sites, which lie on one
+	// circle, is found with different neighbours in each of the cells, so the
+	// vertices are matched by their exact position, and the sites around each
+	// of them are split into a fan of triangles.
+	var keys [][3]int64
+	members := make(map[[3]int64][]int)
+	for i, around := range d.around {
+		for k, b := range around {
+			c := around[(k+1)%len(around)]
+			if b < 0 || c < 0 {
+				continue
+			}
+			key, ok := vertexKey(d.points[0], d.points[i], d.points[b], d.points[c])
+			if !ok {
+				continue
+			}
+			if _, ok := members[key]; !ok {
+				keys = append(keys, key)
+			}
+			for _, j := range [3]int{i, b, c} {
+				if !containsInt(members[key], j) {
+					members[key] = append(members[key], j)
+				}
+			}
+		}
+	}
+	for _, key := range keys {
+		center := Point{
+			d.points[0].X + float64(key[0])/float64(key[2]),
+			d.points[0].Y + float64(key[1])/float64(key[2]),
+		}
+		around := members[key]
+		sort.Slice(around, func(a, b int) bool {
+			pa, pb := d.points[around[a]], d.points[around[b]]
+			return math.Atan2(pa.Y-center.Y, pa.X-center.X) < math.Atan2(pb.Y-center.Y, pb.X-center.X)
+		})
+		for k := 1; k+1 < len(around); k++ {
+			d.triangles = append(d.triangles, [3]int{around[0], around[k], around[k+1]})
+			d.centers = append(d.centers, center)
+		}
+	}
+	return d
+}
+
+// vertexKey returns the circumcenter of three points with integer
+// coordinates as an exact fraction: the numerators of its coordinates
+// relative to the origin, and their common denominator. Returns false if the
+// points lie on one line.
+func vertexKey(origin, a, b, c Point) ([3]int64, bool) {
+	ax, ay := int64(a.X-origin.X), int64(a.Y-origin.Y)
+	bx, by := int64(b.X-a.X), int64(b.Y-a.Y)
+	cx, cy := int64(c.X-a.X), int64(c.Y-a.Y)
+	den := 2 * (bx*cy - by*cx)
+	if den == 0 {
+		return [3]int64{}, false
+	}
+	x := ax*den + cy*(bx*bx+by*by) - by*(cx*cx+cy*cy)
+	y := ay*den + bx*(cx*cx+cy*cy) - cx*(bx*bx+by*by)
+	if den < 0 {
+		x, y, den = -x, -y, -den
+	}
+	g := gcd(gcd(x, y), den)
+	return [3]int64{x / g, y / g, den / g}, true
+}
+
+// gcd returns the greatest common divisor of the absolute values of a and b.
+func gcd(a, b int64) int64 {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// siteEdges converts edges between indices of sites to sorted edges between
+// their IDs.
+func siteEdges(sites []*Site, edges [][2]int) []SiteEdge {
+	result := make([]SiteEdge, 0, len(edges))
+	for _, e := range edges {
+		a, b := sites[e[0]].ID, sites[e[1]].ID
+		if a > b {
+			a, b = b, a
+		}
+		result = append(result, SiteEdge{a, b})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].A != result[j].A {
+			return result[i].A < result[j].A
+		}
+		return result[i].B < result[j].B
+	})
+	return result
+}
+
+// angleBetween returns the angle at p between the directions to a and b.
+func angleBetween(p, a, b Point) float64 {
+	u := Point{a.X - p.X, a.Y - p.Y}
+	v := Point{b.X - p.X, b.Y - p.Y}
+	return math.Abs(math.Atan2(u.X*v.Y-u.Y*v.X, u.X*v.X+u.Y*v.Y))
+}
@@ -0,0 +1,113 @@
+package voronoi
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// DistanceGrid holds distances for each pixel of a raster, row by row.
+// Pixels outside of all cells hold -1.
+type DistanceGrid struct {
+	Width, Height int
+	// Site holds the distance from the center of each pixel to the site of
+	// its cell, measured with the metric of the diagram.
+	Site []float32
+	// Edge holds the euclidean distance from the center of each pixel to the
+	// nearest edge between two cells, which makes a medial distance map.
+	// Edges along the bounds and the clip polygon are not counted, so pixels
+	// of a diagram with a single site hold -1.
+	Edge []float32
+}
+
+// At returns the distances of a pixel to its site and to the nearest edge.
+func (g *DistanceGrid) At(x, y int) (site, edge float32) {
+	return g.Site[y*g.Width+x], g.Edge[y*g.Width+x]
+}
+
+// DistanceField computes the distance field of the diagram within the given
+// bounds, with resolution pixels per unit. The pixels are labelled with their
+// sites the same way as by Labels, so the nearest site of each pixel is known
+// without a search, and the nearest edge lies on the border of its cell. The
+// edges are taken from the DCEL, whose vertices are rounded to integers, so
+// the distances to the edges may be off by up to half the diagonal of a unit.
+// Not supported for diagrams with segment sites.
+func (v *Voronoi) DistanceField(bounds image.Rectangle, resolution float64) (*DistanceGrid, error) {
+	if len(v.Segments) > 0 {
+		return nil, errors.New("distance fields are not supported for segment sites")
+	}
+	r := newRaster(bounds, resolution)
+	grid := &DistanceGrid{
+		Width:  r.width,
+		Height: r.height,
+		Site:   make([]float32, r.width*r.height),
+		Edge:   make([]float32, r.width*r.height),
+	}
+	for i := range grid.Site {
+		grid.Site[i], grid.Edge[i] = -1, -1
+	}
+
+	cells := v.Cells()
+	labels := make([]int32, r.width*r.height)
+	for i := range labels {
+		labels[i] = -1
+	}
+	v.labelCells(r, cells, labels)
+
+	// A cell split by the clip polygon has several pieces.
+	index := make(map[*Site]int, len(v.Sites))
+	for i := range v.Sites {
+		index[&v.Sites[i]] = i
+	}
+	pieces := make([][]*Cell, len(v.Sites))
+	for i := range cells {
+		k := index[cells[i].Site]
+		pieces[k] = append(pieces[k], &cells[i])
+	}
+	shifts := newTorus(v.Bounds, v.Wrap).shifts()
+
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			label := labels[y*r.width+x]
+			if label < 0 {
+				continue
+			}
+			c := r.center(x, y)
+
+			// On a periodic domain, the pixel belongs to the nearest copy of
+			// the site and of its cell.
+			p := pointOf(&v.Sites[label])
+			var shift Point
+			distance := math.Inf(1)
+			for _, s := range shifts {
+				if d := v.Metric.Distance(c, Point{p.X + s.X, p.Y + s.Y}); d < distance {
+					shift, distance = s, d
+				}
+			}
+			grid.Site[y*r.width+x] = float32(distance)
+
+			at := Point{c.X - shift.X, c.Y - shift.Y}
+			var cell *Cell
+			for _, piece := range pieces[label] {
+				if cell == nil || cellDistance(piece, at) < cellDistance(cell, at) {
+					cell = piece
+				}
+			}
+			if cell == nil {
+				continue
+			}
+			// Edges along the border of the diagram have no neighbour.
+			// Periodic diagrams have no border.
+			nearest := math.Inf(1)
+			for _, e := range cell.Edges {
+				if e.Neighbor != nil {
+					nearest = math.Min(nearest, dist(at, lerp(e.A, e.B, project(e.A, e.B, at))))
+				}
+			}
+			if !math.IsInf(nearest, 1) {
+				grid.Edge[y*r.width+x] = float32(nearest)
+			}
+		}
+	}
+	return grid, nil
+}
@@ -0,0 +1,80 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestDistanceField(t *testing.T) {
+	bounds := image.Rect(0, 0, 150, 100)
+	v := New(randomSites(30, 1, bounds), bounds)
+	v.Generate()
+	grid, err := v.DistanceField(bounds, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRaster(bounds, 0.5)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			c := r.center(x, y)
+			nearest := nearestLabel(v, c)
+			a := pointOf(&v.Sites[nearest])
+			site, edge := grid.At(x, y)
+			if math.Abs(float64(site)-dist(c, a)) > 1e-3 {
+				t.Fatalf("pixel (%d, %d) is %v from its site, want %v", x, y, site, dist(c, a))
+			}
+
+			// The nearest edge lies on the bisector of the site and one of
+			// the other sites, unless the border of the bounds is nearer.
+			bisector := math.Inf(1)
+			for i := range v.Sites {
+				if int32(i) == nearest {
+					continue
+				}
+				b := pointOf(&v.Sites[i])
+				bisector = math.Min(bisector, (dist(c, b)*dist(c, b)-dist(c, a)*dist(c, a))/(2*dist(a, b)))
+			}
+			border := math.Min(math.Min(c.X, 150-c.X), math.Min(c.Y, 100-c.Y))
+			if float64(edge) < bisector-0.75 || (bisector < border-0.75 && math.Abs(float64(edge)-bisector) > 0.75) {
+				t.Fatalf("pixel (%d, %d) is %v from the nearest edge, want %v", x, y, edge, bisector)
+			}
+		}
+	}
+}
+
+func TestDistanceFieldPeriodic(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	v := New(randomSites(20, 2, bounds), bounds)
+	v.Wrap = WrapX | WrapY
+	v.Generate()
+	grid, err := v.DistanceField(bounds, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRaster(bounds, 1)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			c := r.center(x, y)
+			want := labelDistance(v, c, nearestLabel(v, c))
+			if site, edge := grid.At(x, y); math.Abs(float64(site)-want) > 1e-3 || edge < 0 {
+				t.Fatalf("pixel (%d, %d) has distances %v and %v, want %v to its site", x, y, site, edge, want)
+			}
+		}
+	}
+}
+
+func TestDistanceFieldSingleSite(t *testing.T) {
+	bounds := image.Rect(0, 0, 20, 20)
+	v := New(SiteSlice{{X: 5, Y: 5}}, bounds)
+	v.Generate()
+	grid, err := v.DistanceField(bounds, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range grid.Edge {
+		if grid.Edge[i] != -1 || grid.Site[i] < 0 {
+			t.Fatalf("pixel %d has distances %v and %v", i, grid.Site[i], grid.Edge[i])
+		}
+	}
+}
@@ -0,0 +1,890 @@
+package voronoi
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/quasoft/dcel"
+)
+
+// editor keeps the state needed to update a diagram incrementally: the cell
+// of each site, the neighbours of each cell (the Delaunay graph of the sites)
+// and the DCEL elements of each face, so that they can be replaced without
+// touching the rest of the diagram.
+type editor struct {
+	// Cells are computed within a box much larger than the bounds, so that
+	// sites outside the bounds have cells and neighbours too.
+	box       image.Rectangle
+	index     map[int64]int // position of each site in Voronoi.Sites
+	cells     map[int64][]Point
+	neighbors map[int64]map[int64]bool
+
+	polygons  map[int64][]Point // cells clipped to the bounds
+	faces     map[int64]*dcel.Face
+	rings     map[int64][]image.Point // rounded polygons, before splitting at T-junctions
+	halfEdges map[int64][]*dcel.HalfEdge
+	keys      map[*dcel.HalfEdge][2]image.Point // end points of the half-edges of faces
+	orphans   []*dcel.HalfEdge                  // half-edges, whose twin was removed
+
+	vertices map[image.Point]*dcel.Vertex
+	uses     map[image.Point]int
+	edges    map[[2]image.Point][]*dcel.HalfEdge
+	outer    map[*dcel.HalfEdge]bool // half-edges on the outer border, which belong to no face
+
+	// Positions of the elements in the DCEL lists, so that they can be removed
+	// in constant time.
+	facePos   map[*dcel.Face]int
+	edgePos   map[*dcel.HalfEdge]int
+	vertexPos map[*dcel.Vertex]int
+
+	grid *siteGrid // sites with a cell, for point location
+	// Sites at the location of a site with a cell, which have no cell of
+	// their own, in the order of the sites.
+	shared map[int64][]int64
+}
+
+// Insert adds a site to a generated diagram and updates only the cells
+// affected by it: the new cell and the cells of its Delaunay neighbours, from
+// which the new cell takes its area. Returns the IDs of the faces, which
+// changed within the bounds, starting with the face of the new site.
+//
+// The cell containing the site is found with a uniform grid of the sites, in
+// expected constant time. The DCEL does not record the neighbours of the
+// cells, so the first call to Insert or Delete computes them once, in
+// expected linear time, and rebuilds the half-edges of the faces, which are
+// kept.
+// Only supported for nearest-point diagrams with the euclidean metric, which
+// are neither clipped, nor periodic and have no segment sites.
+func (v *Voronoi) Insert(site Site) ([]int64, error) {
+	if err := v.initEditor(); err != nil {
+		return nil, err
+	}
+	e := v.editor
+	if _, ok := e.index[site.ID]; ok {
+		return nil, fmt.Errorf("a site with ID %d already exists", site.ID)
+	}
+	site.Face = nil
+	site.Outside = false
+
+	p := pointOf(&site)
+	if !pointInRect(p, e.box) {
+		// Sites far outside the bounds change the cells of many sites, so
+		// the state is computed again.
+		v.Sites = append(v.Sites, site)
+		v.editor = nil
+		if err := v.initEditor(); err != nil {
+			return nil, err
+		}
+		return sortedIDs(v.editor.faceIDs()), nil
+	}
+
+	if nearest, ok := e.grid.nearest(p); ok && pointOf(&v.Sites[e.index[nearest]]) == p {
+		return nil, fmt.Errorf("site %d already exists at %v", nearest, site)
+	}
+	v.addSite(site)
+	return e.addCell(v, site.ID), nil
+}
+
+// Delete removes the site with the given ID from a generated diagram. The
+// cell of the site is divided among its Delaunay neighbours, the other cells
+// stay the same. Returns the IDs of the faces, which changed within the bounds.
+// Has the same requirements as Insert. The last site in Voronoi.Sites takes
+// the place of the deleted site, in constant time. If other sites lie at the location of the deleted site, the
+// first of them takes over its cell, whose face is the only one that changes.
+func (v *Voronoi) Delete(id int64) ([]int64, error) {
+	if err := v.initEditor(); err != nil {
+		return nil, err
+	}
+	e := v.editor
+	if _, ok := e.index[id]; !ok {
+		return nil, fmt.Errorf("no site with ID %d", id)
+	}
+	changed := e.removeCell(v, id)
+	v.removeSite(id)
+	return changed, nil
+}
+
+// addCell computes the cell of a site in Voronoi.Sites, which has none yet,
+// and updates the cells of its neighbours. Returns the IDs of the changed
+// faces, starting with the face of the site.
+func (e *editor) addCell(v *Voronoi, id int64) []int64 {
+	p := pointOf(&v.Sites[e.index[id]])
+	nearest, ok := e.grid.nearest(p)
+	e.grid.add(id, p)
+	e.neighbors[id] = make(map[int64]bool)
+
+	// The cells losing area to the new site form a connected set around the
+	// cell containing it.
+	var affected []int64
+	var queue []int64
+	visited := make(map[int64]bool)
+	if ok {
+		visited[nearest] = true
+		queue = append(queue, nearest)
+	}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		if !closerVertex(e.cells[k], p, pointOf(&v.Sites[e.index[k]])) {
+			continue
+		}
+		affected = append(affected, k)
+		for j := range e.neighbors[k] {
+			if !visited[j] {
+				visited[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	sort.Slice(affected, func(i, j int) bool { return affected[i] < affected[j] })
+
+	// The neighbours of the new site are exactly the affected sites.
+	cell := rectPolygon(e.box)
+	update := map[int64]map[int64]bool{id: {}}
+	for _, k := range affected {
+		q := pointOf(&v.Sites[e.index[k]])
+		cell = clipCloser(cell, p, q)
+		e.cells[k] = clipCloser(e.cells[k], q, p)
+		update[id][k] = true
+		update[k] = copyIDs(e.neighbors[k])
+		update[k][id] = true
+	}
+	e.cells[id] = cell
+	e.updateNeighbors(v.Sites, update)
+
+	changed := e.clipToBounds(v.Bounds, append([]int64{id}, affected...))
+	e.rebuild(v, changed)
+	return changed
+}
+
+// removeCell removes the cell of a site, leaving the site in Voronoi.Sites
+// without a face, and divides the cell among its neighbours. Returns the IDs
+// of the changed faces.
+func (e *editor) removeCell(v *Voronoi, id int64) []int64 {
+	site := &v.Sites[e.index[id]]
+	defer func() { site.Face = nil }()
+
+	p := pointOf(site)
+	if e.cells[id] == nil {
+		// The site shares the cell of another site at its location.
+		owner, _ := e.grid.nearest(p)
+		e.shared[owner] = removeID(e.shared[owner], id)
+		return nil
+	}
+	if shared := e.shared[id]; len(shared) > 0 {
+		delete(e.shared, id)
+		if len(shared) > 1 {
+			e.shared[shared[0]] = shared[1:]
+		}
+		e.grid.remove(id, p)
+		e.grid.add(shared[0], p)
+		var changed []int64
+		if e.faces[id] != nil {
+			changed = []int64{shared[0]}
+		}
+		e.transfer(v, id, shared[0])
+		return changed
+	}
+	e.grid.remove(id, p)
+
+	neighbors := sortedIDs(e.neighbors[id])
+	e.removeFace(v.DCEL, id, true)
+	for _, k := range neighbors {
+		e.setLink(id, k, false)
+	}
+	delete(e.cells, id)
+	delete(e.polygons, id)
+	delete(e.neighbors, id)
+	delete(e.rings, id)
+
+	// Each neighbour takes the part of the cell, which is closer to it than to
+	// the other neighbours. New neighbours of a cell come only from the
+	// neighbours of the removed site.
+	update := make(map[int64]map[int64]bool)
+	for _, k := range neighbors {
+		a := pointOf(&v.Sites[e.index[k]])
+		candidates := copyIDs(e.neighbors[k])
+		for _, j := range neighbors {
+			if j != k {
+				candidates[j] = true
+			}
+		}
+		cell := rectPolygon(e.box)
+		for _, j := range sortedIDs(candidates) {
+			cell = clipCloser(cell, a, pointOf(&v.Sites[e.index[j]]))
+		}
+		e.cells[k] = cell
+		update[k] = candidates
+	}
+	e.updateNeighbors(v.Sites, update)
+
+	changed := e.clipToBounds(v.Bounds, neighbors)
+	e.rebuild(v, changed)
+	return changed
+}
+
+// addSite appends a site to Voronoi.Sites, and links the faces to the sites
+// again if the slice had to grow.
+func (v *Voronoi) addSite(site Site) {
+	e := v.editor
+	grown := len(v.Sites) == cap(v.Sites)
+	v.Sites = append(v.Sites, site)
+	e.index[site.ID] = len(v.Sites) - 1
+	if grown {
+		for id, i := range e.index {
+			e.link(&v.Sites[i], e.faces[id])
+		}
+	}
+}
+
+// removeSite removes a site from Voronoi.Sites, moving the last site into
+// its place and linking the face of the moved site to it again.
+func (v *Voronoi) removeSite(id int64) {
+	e := v.editor
+	i, last := e.index[id], len(v.Sites)-1
+	if i != last {
+		v.Sites[i] = v.Sites[last]
+		e.index[v.Sites[i].ID] = i
+		e.link(&v.Sites[i], e.faces[v.Sites[i].ID])
+	}
+	v.Sites[last] = Site{}
+	v.Sites = v.Sites[:last]
+	delete(e.index, id)
+}
+
+// transfer hands the cell of a site over to another site at the same
+// location, which had no cell.
+func (e *editor) transfer(v *Voronoi, from, to int64) {
+	e.cells[to], e.polygons[to], e.rings[to] = e.cells[from], e.polygons[from], e.rings[from]
+	delete(e.cells, from)
+	delete(e.polygons, from)
+	delete(e.rings, from)
+	e.halfEdges[to] = e.halfEdges[from]
+	delete(e.halfEdges, from)
+	for _, k := range sortedIDs(e.neighbors[from]) {
+		e.setLink(from, k, false)
+		e.setLink(to, k, true)
+	}
+	delete(e.neighbors, from)
+	if face := e.faces[from]; face != nil {
+		delete(e.faces, from)
+		e.faces[to] = face
+		face.ID = to
+		e.link(&v.Sites[e.index[to]], face)
+	}
+}
+
+// initEditor prepares the diagram for incremental updates, computing the
+// cells and their neighbours and rebuilding the half-edges of the DCEL from
+// them. Faces of the generated diagram are kept for their sites.
+// Of several sites at the same location only the first gets a cell.
+func (v *Voronoi) initEditor() error {
+	if !v.usesSweepLine() {
+		return errors.New("incremental updates are only supported for plain nearest-point diagrams")
+	}
+	if v.editor != nil {
+		return nil
+	}
+	e, err := newEditor(v.Sites, v.Bounds)
+	if err != nil {
+		return err
+	}
+
+	d := dcel.NewDCEL()
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		if face := site.Face; face != nil && e.cells[site.ID] != nil {
+			if _, ok := e.facePos[face]; !ok {
+				face.HalfEdge = nil
+				e.faces[site.ID] = face
+				e.facePos[face] = len(d.Faces)
+				d.Faces = append(d.Faces, face)
+			}
+		}
+		site.Face = nil
+		site.Outside = false
+	}
+	var ids []int64
+	for id := range e.cells {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+
+	v.DCEL = d
+	v.editor = e
+	e.rebuild(v, e.clipToBounds(v.Bounds, ids))
+	return nil
+}
+
+// newEditor computes the cells of the sites and their neighbours, without
+// modifying the sites. The DCEL is left to rebuild.
+func newEditor(sites SiteSlice, bounds image.Rectangle) (*editor, error) {
+	e := &editor{
+		index:     make(map[int64]int),
+		cells:     make(map[int64][]Point),
+		neighbors: make(map[int64]map[int64]bool),
+		polygons:  make(map[int64][]Point),
+		faces:     make(map[int64]*dcel.Face),
+		rings:     make(map[int64][]image.Point),
+		halfEdges: make(map[int64][]*dcel.HalfEdge),
+		keys:      make(map[*dcel.HalfEdge][2]image.Point),
+		vertices:  make(map[image.Point]*dcel.Vertex),
+		uses:      make(map[image.Point]int),
+		edges:     make(map[[2]image.Point][]*dcel.HalfEdge),
+		outer:     make(map[*dcel.HalfEdge]bool),
+		facePos:   make(map[*dcel.Face]int),
+		edgePos:   make(map[*dcel.HalfEdge]int),
+		vertexPos: make(map[*dcel.Vertex]int),
+		shared:    make(map[int64][]int64),
+	}
+
+	// The box contains the bounds and all sites, with a margin as large as
+	// the box itself, leaving room for sites inserted later.
+	box := bounds
+	for i := range sites {
+		site := &sites[i]
+		if _, ok := e.index[site.ID]; ok {
+			return nil, fmt.Errorf("site ID %d is not unique", site.ID)
+		}
+		e.index[site.ID] = i
+		e.neighbors[site.ID] = make(map[int64]bool)
+		box = box.Union(image.Rect(site.X, site.Y, site.X+1, site.Y+1))
+	}
+	margin := box.Dx()
+	if box.Dy() > margin {
+		margin = box.Dy()
+	}
+	e.box = box.Inset(-margin)
+
+	e.grid = newSiteGrid(e.box, len(sites))
+	for i := range sites {
+		e.grid.add(sites[i].ID, pointOf(&sites[i]))
+	}
+
+	for i := range sites {
+		site := &sites[i]
+
+		// The sites around the site are visited ring by ring, until the
+		// rest are too far away to affect its cell.
+		a := pointOf(site)
+		cell := rectPolygon(e.box)
+		cutters := make(map[int64]bool)
+		visit := func(other gridSite) {
+			if cell == nil || other.id == site.ID {
+				return
+			}
+			if other.p == a {
+				if e.index[other.id] < i {
+					cell = nil
+				}
+				return
+			}
+			if clipped := clipCloser(cell, a, other.p); len(clipped) != len(cell) || !samePolygon(clipped, cell) {
+				cell = clipped
+				cutters[other.id] = true
+			}
+		}
+		for k := 0; cell != nil && float64(k-1)*e.grid.size < 2*cellRadius(cell, a); k++ {
+			if !e.grid.ring(a, k, visit) {
+				break
+			}
+		}
+		if cell == nil {
+			continue
+		}
+		e.cells[site.ID] = cell
+
+		// Cells computed later may not be final yet, so neighbours are linked
+		// in both directions as soon as either of them finds the other.
+		for k := range e.findNeighbors(sites, site.ID, cutters) {
+			e.setLink(site.ID, k, true)
+		}
+	}
+
+	// Sites sharing the location of an earlier site take over its cell, when
+	// it is deleted.
+	for i := range sites {
+		if id := sites[i].ID; e.cells[id] == nil {
+			p := pointOf(&sites[i])
+			e.grid.remove(id, p)
+			owner, _ := e.grid.nearest(p)
+			e.shared[owner] = append(e.shared[owner], id)
+		}
+	}
+	return e, nil
+}
+
+// clipToBounds clips the cells to the bounds and returns the IDs of the
+// sites, whose clipped polygons changed.
+func (e *editor) clipToBounds(bounds image.Rectangle, ids []int64) []int64 {
+	rect := rectPolygon(bounds)
+	var changed []int64
+	for _, id := range ids {
+		var poly []Point
+		if cell := e.cells[id]; cell != nil {
+			poly = clipConvex(cell, rect)
+		}
+		old, ok := e.polygons[id]
+		if ok && len(old) == len(poly) && samePolygon(old, poly) {
+			continue
+		}
+		e.polygons[id] = poly
+		changed = append(changed, id)
+	}
+	return changed
+}
+
+// faceIDs returns the IDs of the sites, which have a face.
+func (e *editor) faceIDs() map[int64]bool {
+	ids := make(map[int64]bool)
+	for id := range e.faces {
+		ids[id] = true
+	}
+	return ids
+}
+
+// sortedIDs returns the IDs in a set in ascending order.
+func sortedIDs(ids map[int64]bool) []int64 {
+	sorted := make([]int64, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	return sorted
+}
+
+// removeID removes an ID from a list, keeping the order of the others.
+func removeID(ids []int64, id int64) []int64 {
+	for i, other := range ids {
+		if other == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+func copyIDs(ids map[int64]bool) map[int64]bool {
+	copied := make(map[int64]bool, len(ids))
+	for id := range ids {
+		copied[id] = true
+	}
+	return copied
+}
+
+// cellRadius returns the largest distance from the site to a vertex of its cell.
+func cellRadius(cell []Point, site Point) float64 {
+	var radius float64
+	for _, p := range cell {
+		radius = math.Max(radius, dist(p, site))
+	}
+	return radius
+}
+
+// samePolygon reports if two polygons of the same length have the same vertices.
+func samePolygon(a, b []Point) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// closerVertex reports if any vertex of the cell is closer to p than to the
+// site of the cell, which means that a site at p takes part of the cell.
+func closerVertex(cell []Point, p, site Point) bool {
+	for _, q := range cell {
+		if dist(q, p) < dist(q, site)-1e-9 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateNeighbors finds the neighbours of the changed cells among their
+// candidates and updates the neighbours of both sides. Two changed cells stay
+// linked if either of them finds the other, as one side may miss a very
+// short edge.
+func (e *editor) updateNeighbors(sites SiteSlice, candidates map[int64]map[int64]bool) {
+	found := make(map[int64]map[int64]bool)
+	for id, c := range candidates {
+		found[id] = e.findNeighbors(sites, id, c)
+	}
+	for id, f := range found {
+		for k := range e.neighbors[id] {
+			if !f[k] && !found[k][id] {
+				e.setLink(id, k, false)
+			}
+		}
+		for k := range f {
+			e.setLink(id, k, true)
+		}
+	}
+}
+
+// setLink links or unlinks the cells of two sites as neighbours.
+func (e *editor) setLink(a, b int64, linked bool) {
+	if e.neighbors[a][b] == linked {
+		return
+	}
+	if linked {
+		e.neighbors[a][b] = true
+		e.neighbors[b][a] = true
+	} else {
+		delete(e.neighbors[a], b)
+		delete(e.neighbors[b], a)
+	}
+}
+
+// findNeighbors returns the candidates, which lie across an edge of the cell.
+func (e *editor) findNeighbors(sites SiteSlice, id int64, candidates map[int64]bool) map[int64]bool {
+	a := pointOf(&sites[e.index[id]])
+	found := make(map[int64]bool)
+	cell := e.cells[id]
+	for i, p := range cell {
+		m := lerp(p, cell[(i+1)%len(cell)], 0.5)
+		d := dist(m, a)
+		for k := range candidates {
+			if k != id && math.Abs(dist(m, pointOf(&sites[e.index[k]]))-d) < 1e-6*(1+d) {
+				found[k] = true
+			}
+		}
+	}
+	return found
+}
+
+// link connects a site and its face.
+func (e *editor) link(site *Site, face *dcel.Face) {
+	site.Face = face
+	if face != nil {
+		face.Data = site
+	}
+}
+
+// rebuild replaces the faces of the changed cells and of their neighbours in
+// the DCEL. The neighbours are rebuilt too, as vertices of the changed cells
+// may split their edges. Faces of existing sites are reused.
+func (e *editor) rebuild(v *Voronoi, changed []int64) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	add := func(id int64) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range changed {
+		add(id)
+	}
+	for _, id := range changed {
+		for _, k := range sortedIDs(e.neighbors[id]) {
+			add(k)
+		}
+	}
+
+	for _, id := range ids {
+		e.removeFace(v.DCEL, id, false)
+		e.rings[id] = roundRing(e.polygons[id])
+	}
+
+	var added []*dcel.HalfEdge
+	for _, id := range ids {
+		site := &v.Sites[e.index[id]]
+		ring := e.rings[id]
+		if ring == nil {
+			if face := e.faces[id]; face != nil {
+				e.removeFace(v.DCEL, id, true)
+			}
+			site.Face = nil
+			continue
+		}
+
+		// Split edges at vertices of the neighbouring cells.
+		var points []image.Point
+		for k := range e.neighbors[id] {
+			points = append(points, e.rings[k]...)
+		}
+		var split []image.Point
+		for j, p := range ring {
+			split = append(split, p)
+			split = append(split, pointsOnSegment(points, p, ring[(j+1)%len(ring)])...)
+		}
+
+		face := e.faces[id]
+		if face == nil {
+			face = v.DCEL.NewFace()
+			e.facePos[face] = len(v.DCEL.Faces) - 1
+			e.faces[id] = face
+		}
+		face.ID = id
+		e.link(site, face)
+
+		var first, prev *dcel.HalfEdge
+		for j, p := range split {
+			q := split[(j+1)%len(split)]
+			key := [2]image.Point{p, q}
+			he := &dcel.HalfEdge{Target: e.vertex(v.DCEL, q)}
+			e.uses[q]++
+			e.addHalfEdge(v.DCEL, he)
+			e.edges[key] = append(e.edges[key], he)
+			e.keys[he] = key
+			e.halfEdges[id] = append(e.halfEdges[id], he)
+			added = append(added, he)
+			if prev != nil {
+				prev.Next, he.Prev = he, prev
+			} else {
+				first = he
+			}
+			prev = he
+		}
+		prev.Next, first.Prev = first, prev
+		face.HalfEdge = first
+	}
+
+	// Pair the new half-edges with their twins, replacing the outer twins
+	// of existing half-edges, which are now covered by a face.
+	for _, he := range added {
+		if he.Twin != nil {
+			continue
+		}
+		key := e.keys[he]
+		for _, twin := range e.edges[[2]image.Point{key[1], key[0]}] {
+			if twin.Twin == nil || e.outer[twin.Twin] {
+				if twin.Twin != nil {
+					e.removeHalfEdge(v.DCEL, twin.Twin)
+				}
+				he.Twin, twin.Twin = twin, he
+				break
+			}
+		}
+		if he.Twin == nil {
+			e.addOuterTwin(v.DCEL, he, key[0])
+		}
+	}
+	for _, he := range e.orphans {
+		if _, ok := e.edgePos[he]; ok && he.Twin == nil {
+			e.addOuterTwin(v.DCEL, he, e.keys[he][0])
+		}
+	}
+	e.orphans = e.orphans[:0]
+}
+
+// removeFace removes the half-edges of a face from the DCEL, together with
+// their outer twins and the vertices no longer used by any face. Twins in
+// other faces are left without a twin, until the face is added again.
+// With drop set, the face itself is removed too.
+func (e *editor) removeFace(d *dcel.DCEL, id int64, drop bool) {
+	for _, he := range e.halfEdges[id] {
+		key := e.keys[he]
+		q := key[1]
+		list := e.edges[key]
+		for i, other := range list {
+			if other == he {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(e.edges, key)
+		} else {
+			e.edges[key] = list
+		}
+
+		if twin := he.Twin; twin != nil {
+			if e.outer[twin] {
+				e.removeHalfEdge(d, twin)
+			} else if twin.Twin == he {
+				twin.Twin = nil
+				e.orphans = append(e.orphans, twin)
+			}
+		}
+		e.removeHalfEdge(d, he)
+
+		if e.uses[q]--; e.uses[q] == 0 {
+			vertex := e.vertices[q]
+			last := len(d.Vertices) - 1
+			i := e.vertexPos[vertex]
+			d.Vertices[i] = d.Vertices[last]
+			e.vertexPos[d.Vertices[i]] = i
+			d.Vertices = d.Vertices[:last]
+			delete(e.vertexPos, vertex)
+			delete(e.vertices, q)
+			delete(e.uses, q)
+		}
+	}
+	delete(e.halfEdges, id)
+
+	if face := e.faces[id]; drop && face != nil {
+		last := len(d.Faces) - 1
+		i := e.facePos[face]
+		d.Faces[i] = d.Faces[last]
+		e.facePos[d.Faces[i]] = i
+		d.Faces = d.Faces[:last]
+		delete(e.facePos, face)
+		delete(e.faces, id)
+	}
+}
+
+// vertex returns the DCEL vertex at the given point, creating it if needed.
+func (e *editor) vertex(d *dcel.DCEL, p image.Point) *dcel.Vertex {
+	vertex := e.vertices[p]
+	if vertex == nil {
+		vertex = d.NewVertex(p.X, p.Y)
+		e.vertices[p] = vertex
+		e.vertexPos[vertex] = len(d.Vertices) - 1
+	}
+	return vertex
+}
+
+func (e *editor) addHalfEdge(d *dcel.DCEL, he *dcel.HalfEdge) {
+	e.edgePos[he] = len(d.HalfEdges)
+	d.HalfEdges = append(d.HalfEdges, he)
+}
+
+func (e *editor) addOuterTwin(d *dcel.DCEL, he *dcel.HalfEdge, target image.Point) {
+	twin := &dcel.HalfEdge{Target: e.vertices[target], Twin: he}
+	he.Twin = twin
+	e.outer[twin] = true
+	e.addHalfEdge(d, twin)
+}
+
+func (e *editor) removeHalfEdge(d *dcel.DCEL, he *dcel.HalfEdge) {
+	last := len(d.HalfEdges) - 1
+	i := e.edgePos[he]
+	d.HalfEdges[i] = d.HalfEdges[last]
+	e.edgePos[d.HalfEdges[i]] = i
+	d.HalfEdges = d.HalfEdges[:last]
+	delete(e.edgePos, he)
+	delete(e.outer, he)
+	delete(e.keys, he)
+}
+
+// siteGrid is a uniform grid of sites, which finds the site nearest to a
+// point in expected constant time.
+type siteGrid struct {
+	box     image.Rectangle
+	size    float64 // size of the buckets
+	columns int
+	rows    int
+	buckets [][]gridSite
+	count   int
+	sized   int // number of sites, for which the buckets were sized
+}
+
+type gridSite struct {
+	id int64
+	p  Point
+}
+
+// newSiteGrid creates an empty grid over a box, with about twice as many
+// buckets as the given number of sites.
+func newSiteGrid(box image.Rectangle, n int) *siteGrid {
+	if n < 1 {
+		n = 1
+	}
+	g := &siteGrid{box: box, sized: n}
+	g.size = math.Max(1, math.Sqrt(float64(box.Dx())*float64(box.Dy())/float64(2*n)))
+	g.columns = int(float64(box.Dx())/g.size) + 1
+	g.rows = int(float64(box.Dy())/g.size) + 1
+	g.buckets = make([][]gridSite, g.columns*g.rows)
+	return g
+}
+
+// bucket returns the column and row of the bucket containing a point,
+// clamped to the grid.
+func (g *siteGrid) bucket(p Point) (int, int) {
+	c := int(math.Floor((p.X - float64(g.box.Min.X)) / g.size))
+	r := int(math.Floor((p.Y - float64(g.box.Min.Y)) / g.size))
+	if c < 0 {
+		c = 0
+	} else if c >= g.columns {
+		c = g.columns - 1
+	}
+	if r < 0 {
+		r = 0
+	} else if r >= g.rows {
+		r = g.rows - 1
+	}
+	return c, r
+}
+
+// add adds a site to the grid. The grid is made finer, when the number of
+// sites has doubled.
+func (g *siteGrid) add(id int64, p Point) {
+	if g.count >= 2*g.sized {
+		finer := newSiteGrid(g.box, 2*g.count)
+		for _, bucket := range g.buckets {
+			for _, s := range bucket {
+				finer.add(s.id, s.p)
+			}
+		}
+		*g = *finer
+	}
+	c, r := g.bucket(p)
+	g.buckets[r*g.columns+c] = append(g.buckets[r*g.columns+c], gridSite{id, p})
+	g.count++
+}
+
+// remove removes a site from the grid.
+func (g *siteGrid) remove(id int64, p Point) {
+	c, r := g.bucket(p)
+	bucket := g.buckets[r*g.columns+c]
+	for i, s := range bucket {
+		if s.id == id {
+			last := len(bucket) - 1
+			bucket[i] = bucket[last]
+			g.buckets[r*g.columns+c] = bucket[:last]
+			g.count--
+			return
+		}
+	}
+}
+
+// ring calls visit for the sites in the buckets k steps away from the bucket
+// containing p. A point within the grid is at least (k-1)*size away from
+// these sites. Returns false if the ring lies entirely outside the grid.
+func (g *siteGrid) ring(p Point, k int, visit func(gridSite)) bool {
+	c, r := g.bucket(p)
+	if c-k < 0 && r-k < 0 && c+k >= g.columns && r+k >= g.rows {
+		return false
+	}
+	for y := r - k; y <= r+k; y++ {
+		if y < 0 || y >= g.rows {
+			continue
+		}
+		// Rows between the first and the last one have two buckets in the ring.
+		step := 2 * k
+		if y == r-k || y == r+k {
+			step = 1
+		}
+		for x := c - k; x <= c+k; x += step {
+			if x >= 0 && x < g.columns {
+				for _, s := range g.buckets[y*g.columns+x] {
+					visit(s)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// nearest returns the ID of the site nearest to a point within the grid,
+// searching the buckets ring by ring around the point.
+func (g *siteGrid) nearest(p Point) (int64, bool) {
+	var best gridSite
+	d := math.Inf(1)
+	visit := func(s gridSite) {
+		if ds := dist(s.p, p); ds < d {
+			best, d = s, ds
+		}
+	}
+	for k := 0; d > float64(k-1)*g.size; k++ {
+		if !g.ring(p, k, visit) {
+			break
+		}
+	}
+	return best.id, !math.IsInf(d, 1)
+}
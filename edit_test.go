@@ -0,0 +1,129 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestInsertDelete(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 150)
+	sites := randomSites(40, 5, bounds)
+	v := New(sites[:20], bounds)
+	v.Generate()
+	for _, site := range sites[20:] {
+		changed, err := v.Insert(site)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changed) == 0 || changed[0] != site.ID {
+			t.Fatalf("insert of site %d changed faces %v", site.ID, changed)
+		}
+	}
+	deleted := map[int64]bool{3: true, 21: true, 39: true, 10: true}
+	for _, id := range []int64{3, 21, 39, 10} {
+		if _, err := v.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(v.Sites) != 36 {
+		t.Fatalf("got %d sites, want 36", len(v.Sites))
+	}
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		if deleted[site.ID] {
+			t.Fatalf("deleted site %d is still listed", site.ID)
+		}
+		if site.Face == nil || site.Face.Data != site || site.Face.ID != site.ID {
+			t.Fatalf("site %d is not linked to its face", site.ID)
+		}
+	}
+
+	var kept SiteSlice
+	for _, site := range sites {
+		if !deleted[site.ID] {
+			kept = append(kept, site)
+		}
+	}
+	fresh := New(kept, bounds)
+	fresh.Generate()
+	want := make(map[int64][]Point)
+	for _, cell := range fresh.Cells() {
+		want[cell.Site.ID] = cell.Polygon
+	}
+	cells := v.Cells()
+	if len(cells) != len(want) {
+		t.Fatalf("got %d cells, want %d", len(cells), len(want))
+	}
+	for _, cell := range cells {
+		if !sameVertices(cell.Polygon, want[cell.Site.ID]) {
+			t.Errorf("cell of site %d is %v, want %v", cell.Site.ID, cell.Polygon, want[cell.Site.ID])
+		}
+	}
+	if area := cellsArea(cells); math.Abs(area-30000) > 1e-9 {
+		t.Errorf("cells cover %v, want 30000", area)
+	}
+}
+
+func TestInsertDeleteErrors(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	v := New(SiteSlice{{X: 10, Y: 10, ID: 1}, {X: 60, Y: 40, ID: 2}, {X: 30, Y: 80, ID: 3}}, bounds)
+	v.Generate()
+	if _, err := v.Insert(Site{X: 50, Y: 50, ID: 2}); err == nil {
+		t.Error("site with a duplicate ID inserted")
+	}
+	if _, err := v.Insert(Site{X: 60, Y: 40, ID: 4}); err == nil {
+		t.Error("site at the location of another site inserted")
+	}
+	if _, err := v.Delete(5); err == nil {
+		t.Error("unknown site deleted")
+	}
+
+	w := New(v.Sites, bounds)
+	w.Farthest = true
+	w.Generate()
+	if _, err := w.Insert(Site{X: 50, Y: 50, ID: 4}); err == nil {
+		t.Error("site inserted into a farthest-point diagram")
+	}
+}
+
+// Sites at the same location share a cell, which the next of them takes
+// over when the first is deleted.
+func TestDeleteSharedSite(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	v := New(SiteSlice{{X: 20, Y: 20, ID: 1}, {X: 70, Y: 30, ID: 2}, {X: 20, Y: 20, ID: 3}, {X: 40, Y: 80, ID: 4}}, bounds)
+	v.Generate()
+	changed, err := v.Delete(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0] != 3 {
+		t.Fatalf("got changed faces %v, want [3]", changed)
+	}
+	for i := range v.Sites {
+		if site := &v.Sites[i]; site.ID == 3 && (site.Face == nil || site.Face.Data != site) {
+			t.Fatal("site sharing the location did not take over the cell")
+		}
+	}
+}
+
+// sameVertices reports if two polygons have the same vertices in the same
+// cyclic order.
+func sameVertices(a, b []Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for shift := range b {
+		same := true
+		for i := range a {
+			if a[i] != b[(i+shift)%len(b)] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return len(a) == 0
+}
@@ -0,0 +1,207 @@
+package voronoi
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// Circle is a circle in the plane.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Annulus is the region between two concentric circles.
+type Annulus struct {
+	Center       Point
+	Inner, Outer float64
+}
+
+// Width returns the difference between the outer and inner radius of the annulus.
+func (a Annulus) Width() float64 {
+	return a.Outer - a.Inner
+}
+
+// FarthestPoint creates the farthest-point voronoi diagram for a list of sites
+// within the specified bounds. Every point in the cell of a site is farther
+// from this site than from any other site. Only the sites on the convex hull
+// have cells - the Face of the other sites is left nil.
+func FarthestPoint(sites SiteSlice, bounds image.Rectangle) *Voronoi {
+	voronoi := New(sites, bounds)
+	voronoi.Farthest = true
+	voronoi.Generate()
+	return voronoi
+}
+
+// farthestCells computes the farthest-point cells of the sites on the convex
+// hull, by clipping the bounds polygon with the bisectors to the other hull
+// sites. Returns the indices of hull sites and their cells.
+func farthestCells(points []Point, bounds []Point) ([]int, [][]Point) {
+	var indices []int
+	var cells [][]Point
+	hull := convexHull(points)
+	for _, i := range hull {
+		a := points[i]
+		cell := bounds
+		for _, j := range hull {
+			b := points[j]
+			if i == j || a == b {
+				continue
+			}
+			// Keep the points farther from a, which are closer to b.
+			cell = clipCloser(cell, b, a)
+			if cell == nil {
+				break
+			}
+		}
+		if cell != nil {
+			indices = append(indices, i)
+			cells = append(cells, cell)
+		}
+	}
+	return indices, cells
+}
+
+// farthestPolygons computes the farthest-point cells of the sites.
+func farthestPolygons(sites []*Site, bounds image.Rectangle) ([]*Site, [][]Point) {
+	points := make([]Point, len(sites))
+	for i, site := range sites {
+		points[i] = pointOf(site)
+	}
+
+	indices, cells := farthestCells(points, rectPolygon(bounds))
+	owners := make([]*Site, len(indices))
+	for k, i := range indices {
+		owners[k] = sites[i]
+	}
+	return owners, cells
+}
+
+// innerEdges returns the edges of the cell polygons, which do not lie on
+// the border of the bounds rectangle.
+func innerEdges(cells [][]Point, bounds image.Rectangle) [][2]Point {
+	var edges [][2]Point
+	for _, cell := range cells {
+		for i, p := range cell {
+			q := cell[(i+1)%len(cell)]
+			if !onRectBorder(p, q, bounds) {
+				edges = append(edges, [2]Point{p, q})
+			}
+		}
+	}
+	return edges
+}
+
+// onRectBorder reports if the segment pq lies on the border of the rectangle.
+func onRectBorder(p, q Point, r image.Rectangle) bool {
+	const eps = 1e-6
+	x0, y0 := float64(r.Min.X), float64(r.Min.Y)
+	x1, y1 := float64(r.Max.X), float64(r.Max.Y)
+	return (math.Abs(p.X-x0) < eps && math.Abs(q.X-x0) < eps) ||
+		(math.Abs(p.X-x1) < eps && math.Abs(q.X-x1) < eps) ||
+		(math.Abs(p.Y-y0) < eps && math.Abs(q.Y-y0) < eps) ||
+		(math.Abs(p.Y-y1) < eps && math.Abs(q.Y-y1) < eps)
+}
+
+// maxDistance returns the distance from p to the farthest of the points.
+func maxDistance(p Point, points []Point) float64 {
+	var r float64
+	for _, q := range points {
+		r = math.Max(r, dist(p, q))
+	}
+	return r
+}
+
+// SmallestEnclosingCircle returns the smallest circle containing all sites.
+// The center of the circle lies on a vertex or an edge of the farthest-point
+// voronoi diagram and is searched for within the bounds.
+func SmallestEnclosingCircle(sites SiteSlice, bounds image.Rectangle) (Circle, error) {
+	if len(sites) == 0 {
+		return Circle{}, errors.New("no sites to enclose")
+	}
+
+	points := make([]Point, len(sites))
+	for i := range sites {
+		points[i] = pointOf(&sites[i])
+	}
+	indices, cells := farthestCells(points, rectPolygon(bounds))
+
+	best := Circle{Center: points[0], Radius: maxDistance(points[0], points)}
+	for k, cell := range cells {
+		site := points[indices[k]]
+		for _, edge := range innerEdges([][]Point{cell}, bounds) {
+			// The edge separates the cells of two sites. The distance to both is the
+			// smallest at the point of the edge, which is nearest to their midpoint.
+			mid := lerp(edge[0], edge[1], project(edge[0], edge[1], site))
+			for _, c := range []Point{edge[0], mid} {
+				if r := maxDistance(c, points); r < best.Radius {
+					best = Circle{Center: c, Radius: r}
+				}
+			}
+		}
+	}
+	return best, nil
+}
+
+// MinimumWidthAnnulus returns the thinnest annulus containing all sites,
+// which is used to measure the roundness of sampled points. The center of
+// the annulus is a vertex of the nearest-point or the farthest-point voronoi
+// diagram, or an intersection of edges of the two, and is searched for
+// within the bounds. Takes O(n^3) time in the worst case.
+func MinimumWidthAnnulus(sites SiteSlice, bounds image.Rectangle) (Annulus, error) {
+	if len(sites) == 0 {
+		return Annulus{}, errors.New("no sites to enclose")
+	}
+
+	points := make([]Point, len(sites))
+	for i := range sites {
+		points[i] = pointOf(&sites[i])
+	}
+	boundsPoly := rectPolygon(bounds)
+
+	var nearest [][]Point
+	for i := range points {
+		if cell := Euclidean.metricCell(points, i, boundsPoly); cell != nil {
+			nearest = append(nearest, cell)
+		}
+	}
+	_, farthest := farthestCells(points, boundsPoly)
+
+	nearEdges := innerEdges(nearest, bounds)
+	farEdges := innerEdges(farthest, bounds)
+
+	var candidates []Point
+	for _, edge := range nearEdges {
+		candidates = append(candidates, edge[0])
+	}
+	for _, edge := range farEdges {
+		candidates = append(candidates, edge[0])
+	}
+	for _, e := range nearEdges {
+		for _, f := range farEdges {
+			if t, _, ok := segmentIntersection(e[0], e[1], f[0], f[1]); ok {
+				candidates = append(candidates, lerp(e[0], e[1], t))
+			}
+		}
+	}
+
+	best := annulusAt(points[0], points)
+	for _, c := range candidates {
+		if a := annulusAt(c, points); a.Width() < best.Width() {
+			best = a
+		}
+	}
+	return best, nil
+}
+
+// annulusAt returns the thinnest annulus with the given center containing all points.
+func annulusAt(center Point, points []Point) Annulus {
+	a := Annulus{Center: center, Inner: math.Inf(1)}
+	for _, p := range points {
+		d := dist(center, p)
+		a.Inner = math.Min(a.Inner, d)
+		a.Outer = math.Max(a.Outer, d)
+	}
+	return a
+}
@@ -0,0 +1,172 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFarthestPointCells(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 300)
+	sites := randomSites(25, 1, image.Rect(100, 100, 200, 200))
+	v := FarthestPoint(sites, bounds)
+	cells := v.Cells()
+	if area := cellsArea(cells); math.Abs(area-90000) > 1e-6*90000 {
+		t.Errorf("cells cover %v, want 90000", area)
+	}
+
+	// Only the sites on the convex hull have cells, while the cells of sites
+	// at very obtuse corners of the hull may lie outside the bounds.
+	hull := hullVertices(sitePoints(v.Sites))
+	for i := range v.Sites {
+		if p := pointOf(&v.Sites[i]); v.Sites[i].Face != nil && !hull[p] {
+			t.Errorf("site at %v inside the convex hull has a cell", p)
+		}
+	}
+
+	// Points inside a cell are farthest from its site.
+	r := rand.New(rand.NewSource(1))
+	for k := 0; k < 500; k++ {
+		p := Point{r.Float64() * 300, r.Float64() * 300}
+		for _, cell := range cells {
+			if !pointInPolygon(p, cell.Polygon) || boundaryDistance(cell.Polygon, p) < 1 {
+				continue
+			}
+			if d := dist(p, pointOf(cell.Site)); d < maxDistance(p, sitePoints(v.Sites))-1e-9 {
+				t.Fatalf("%v lies in the cell of a site at %v, which is not the farthest", p, d)
+			}
+		}
+	}
+}
+
+// sitePoints returns the locations of the sites.
+func sitePoints(sites SiteSlice) []Point {
+	points := make([]Point, len(sites))
+	for i := range sites {
+		points[i] = pointOf(&sites[i])
+	}
+	return points
+}
+
+// hullVertices returns the vertices of the convex hull of the points, which
+// lie neither in a triangle of three other points nor between two of them.
+func hullVertices(points []Point) map[Point]bool {
+	hull := make(map[Point]bool)
+	for _, p := range points {
+		inside := false
+		for _, a := range points {
+			for _, b := range points {
+				if a == p || b == p || a == b {
+					continue
+				}
+				if cross(a, b, p) == 0 && project(a, b, p) > 0 && project(a, b, p) < 1 {
+					inside = true
+				}
+				for _, c := range points {
+					if c == p || c == a || c == b || cross(a, b, c) <= 0 {
+						continue
+					}
+					if cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0 {
+						inside = true
+					}
+				}
+			}
+		}
+		if !inside {
+			hull[p] = true
+		}
+	}
+	return hull
+}
+
+func TestSmallestEnclosingCircle(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	for _, test := range []struct {
+		sites SiteSlice
+		want  Circle
+	}{
+		{SiteSlice{{X: 10, Y: 10}, {X: 30, Y: 10}, {X: 10, Y: 30}, {X: 30, Y: 30}, {X: 20, Y: 20}}, Circle{Point{20, 20}, math.Sqrt(200)}},
+		{SiteSlice{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 5, Y: 1}}, Circle{Point{5, 0}, 5}},
+		{SiteSlice{{X: 40, Y: 50}}, Circle{Point{40, 50}, 0}},
+	} {
+		got, err := SmallestEnclosingCircle(test.sites, bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dist(got.Center, test.want.Center) > 1e-9 || math.Abs(got.Radius-test.want.Radius) > 1e-9 {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+	if _, err := SmallestEnclosingCircle(nil, bounds); err == nil {
+		t.Error("circle of no sites")
+	}
+}
+
+// The smallest enclosing circle passes through two sites on its diameter,
+// or three sites.
+func TestSmallestEnclosingCircleBruteForce(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	for seed := int64(1); seed <= 5; seed++ {
+		sites := randomSites(12, seed, image.Rect(50, 50, 150, 150))
+		points := sitePoints(sites)
+		got, err := SmallestEnclosingCircle(sites, bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := math.Inf(1)
+		for i, a := range points {
+			for j, b := range points[:i] {
+				want = math.Min(want, maxDistance(lerp(a, b, 0.5), points))
+				for _, c := range points[:j] {
+					if center, ok := circumcenterOf(a, b, c); ok {
+						want = math.Min(want, maxDistance(center, points))
+					}
+				}
+			}
+		}
+		if math.Abs(got.Radius-want) > 1e-6 || maxDistance(got.Center, points) > got.Radius+1e-9 {
+			t.Errorf("seed %d: got radius %v, want %v", seed, got.Radius, want)
+		}
+	}
+}
+
+// circumcenterOf returns the center of the circle through three points.
+func circumcenterOf(a, b, c Point) (Point, bool) {
+	d := 2 * (a.X*(b.Y-c.Y) + b.X*(c.Y-a.Y) + c.X*(a.Y-b.Y))
+	if d == 0 {
+		return Point{}, false
+	}
+	a2, b2, c2 := a.X*a.X+a.Y*a.Y, b.X*b.X+b.Y*b.Y, c.X*c.X+c.Y*c.Y
+	return Point{
+		(a2*(b.Y-c.Y) + b2*(c.Y-a.Y) + c2*(a.Y-b.Y)) / d,
+		(a2*(c.X-b.X) + b2*(a.X-c.X) + c2*(b.X-a.X)) / d,
+	}, true
+}
+
+func TestMinimumWidthAnnulus(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	var sites SiteSlice
+	for i := 0; i < 16; i++ {
+		angle := 2 * math.Pi * float64(i) / 16
+		sites = append(sites, Site{X: int(math.Round(50 + 30*math.Cos(angle))), Y: int(math.Round(50 + 30*math.Sin(angle))), ID: int64(i)})
+	}
+	got, err := MinimumWidthAnnulus(sites, bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width() > 1 || dist(got.Center, Point{50, 50}) > 1 {
+		t.Errorf("got %v for sites rounded from a circle", got)
+	}
+
+	// No center nearby makes a thinner annulus.
+	points := sitePoints(sites)
+	r := rand.New(rand.NewSource(1))
+	for k := 0; k < 2000; k++ {
+		c := Point{got.Center.X + 2*r.Float64() - 1, got.Center.Y + 2*r.Float64() - 1}
+		if a := annulusAt(c, points); a.Width() < got.Width()-1e-9 {
+			t.Fatalf("annulus at %v is thinner: %v < %v", c, a.Width(), got.Width())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package voronoi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/quasoft/dcel"
+)
+
+// GeoJSONOptions configures the GeoJSON output of a diagram.
+type GeoJSONOptions struct {
+	// Properties returns additional properties of the features of a site,
+	// typically derived from Site.Data. Optional.
+	Properties func(site *Site) map[string]interface{}
+	// Edges adds a LineString feature for each edge, with the IDs of the
+	// sites on its left and right side. The right side is null for edges on
+	// the border of the diagram. Sides with a segment are null too, and the
+	// ID of the segment is "leftSegment" or "rightSegment" instead.
+	Edges bool
+	// Sites adds a Point feature for each site.
+	Sites bool
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON writes the diagram as a GeoJSON FeatureCollection, with a
+// MultiPolygon feature for the cell of each site, as returned by Cells,
+// followed by the optional edge and site features. The polygons of a cell
+// are the pieces, into which the clip polygon splits it. Coordinates are
+// written as they are, and outer rings are counter-clockwise in the
+// coordinates of the diagram, following the right-hand rule of GeoJSON, while
+// holes left by the clip polygon are clockwise. Every feature has a "kind"
+// property, which is "cell", "segment", "edge" or "site". The features of
+// cells and sites have the ID of the site as "id" property, and the features
+// of the cells of segments the ID of the segment. Their feature IDs are
+// "cell-<site ID>", "segment-<segment ID>" and "site-<site ID>", so that
+// they are unique.
+func (v *Voronoi) WriteGeoJSON(w io.Writer, opts GeoJSONOptions) error {
+	properties := func(kind string, site *Site) map[string]interface{} {
+		props := make(map[string]interface{})
+		if site != nil && opts.Properties != nil {
+			for k, value := range opts.Properties(site) {
+				props[k] = value
+			}
+		}
+		props["kind"] = kind
+		if site != nil {
+			props["id"] = site.ID
+		}
+		return props
+	}
+
+	features := []geoJSONFeature{}
+	cells := v.Cells()
+	var order []*Cell
+	polygons := make(map[interface{}][][][][2]float64)
+	for i := range cells {
+		cell := &cells[i]
+		rings := [][][2]float64{geoJSONRing(cell.Polygon)}
+		for _, hole := range cell.Holes {
+			rings = append(rings, geoJSONRing(hole))
+		}
+		key := cellKey(cell)
+		if _, ok := polygons[key]; !ok {
+			order = append(order, cell)
+		}
+		polygons[key] = append(polygons[key], rings)
+	}
+	for _, cell := range order {
+		feature := geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "MultiPolygon", Coordinates: polygons[cellKey(cell)]},
+		}
+		if cell.Site != nil {
+			feature.ID = fmt.Sprintf("cell-%d", cell.Site.ID)
+			feature.Properties = properties("cell", cell.Site)
+		} else {
+			feature.ID = fmt.Sprintf("segment-%d", cell.Segment.ID)
+			feature.Properties = properties("segment", nil)
+			feature.Properties["id"] = cell.Segment.ID
+		}
+		features = append(features, feature)
+	}
+
+	if opts.Edges {
+		written := make(map[*dcel.HalfEdge]bool)
+		for _, cell := range cells {
+			for _, edge := range cell.Edges {
+				if edge.HalfEdge.Twin != nil && written[edge.HalfEdge.Twin] {
+					continue
+				}
+				written[edge.HalfEdge] = true
+				props := properties("edge", nil)
+				props["left"] = nil
+				if cell.Site != nil {
+					props["left"] = cell.Site.ID
+				} else {
+					props["leftSegment"] = cell.Segment.ID
+				}
+				props["right"] = nil
+				if edge.Neighbor != nil {
+					props["right"] = edge.Neighbor.ID
+				} else if edge.NeighborSegment != nil {
+					props["rightSegment"] = edge.NeighborSegment.ID
+				}
+				features = append(features, geoJSONFeature{
+					Type: "Feature",
+					Geometry: geoJSONGeometry{
+						Type:        "LineString",
+						Coordinates: [][2]float64{{edge.A.X, edge.A.Y}, {edge.B.X, edge.B.Y}},
+					},
+					Properties: props,
+				})
+			}
+		}
+	}
+
+	if opts.Sites {
+		for i := range v.Sites {
+			site := &v.Sites[i]
+			features = append(features, geoJSONFeature{
+				Type:       "Feature",
+				ID:         fmt.Sprintf("site-%d", site.ID),
+				Geometry:   geoJSONGeometry{Type: "Point", Coordinates: [2]float64{float64(site.X), float64(site.Y)}},
+				Properties: properties("site", site),
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{"FeatureCollection", features})
+}
+
+// cellKey returns the site or the segment of a cell.
+func cellKey(cell *Cell) interface{} {
+	if cell.Site != nil {
+		return cell.Site
+	}
+	return cell.Segment
+}
+
+// geoJSONRing returns the coordinates of a closed ring.
+func geoJSONRing(poly []Point) [][2]float64 {
+	ring := make([][2]float64, 0, len(poly)+1)
+	for _, p := range poly {
+		ring = append(ring, [2]float64{p.X, p.Y})
+	}
+	return append(ring, ring[0])
+}
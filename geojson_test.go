@@ -0,0 +1,134 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"testing"
+)
+
+// geoJSONCollection is a decoded FeatureCollection.
+type geoJSONCollection struct {
+	Type     string
+	Features []struct {
+		ID       interface{}
+		Geometry struct {
+			Type        string
+			Coordinates json.RawMessage
+		}
+		Properties map[string]interface{}
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	v := New(randomSites(20, 1, bounds), bounds)
+	for i := range v.Sites {
+		v.Sites[i].Data = fmt.Sprint("site ", v.Sites[i].ID)
+	}
+	v.Generate()
+	var buf bytes.Buffer
+	err := v.WriteGeoJSON(&buf, GeoJSONOptions{Edges: true, Sites: true, Properties: func(site *Site) map[string]interface{} {
+		return map[string]interface{}{"name": site.Data, "kind": "ignored"}
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fc geoJSONCollection
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Type != "FeatureCollection" {
+		t.Fatalf("got type %q", fc.Type)
+	}
+
+	// Cell features round-trip to the polygons of the cells.
+	want := make(map[string][]Point)
+	edges := 0
+	for _, cell := range v.Cells() {
+		want[fmt.Sprint("cell-", cell.Site.ID)] = cell.Polygon
+		for _, edge := range cell.Edges {
+			if edge.Neighbor == nil || edge.Neighbor.ID > cell.Site.ID {
+				edges++
+			}
+		}
+	}
+	ids := make(map[interface{}]bool)
+	counts := make(map[string]int)
+	var area float64
+	for _, f := range fc.Features {
+		kind := f.Properties["kind"]
+		counts[fmt.Sprint(kind)]++
+		if f.ID != nil {
+			if ids[f.ID] {
+				t.Fatalf("feature ID %v is not unique", f.ID)
+			}
+			ids[f.ID] = true
+		}
+		switch kind {
+		case "cell":
+			var polygons [][][][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+				t.Fatal(err)
+			}
+			ring := polygons[0][0]
+			if len(polygons) != 1 || len(polygons[0]) != 1 || ring[0] != ring[len(ring)-1] {
+				t.Fatalf("feature %v has rings %v, want one closed ring", f.ID, polygons)
+			}
+			var got []Point
+			for _, c := range ring[:len(ring)-1] {
+				got = append(got, Point{c[0], c[1]})
+			}
+			if !sameVertices(got, want[fmt.Sprint(f.ID)]) {
+				t.Errorf("feature %v has ring %v, want %v", f.ID, got, want[fmt.Sprint(f.ID)])
+			}
+			area += signedArea(got)
+			if f.Properties["name"] != fmt.Sprint("site ", f.Properties["id"]) {
+				t.Errorf("feature %v has properties %v", f.ID, f.Properties)
+			}
+		case "edge":
+			if f.Geometry.Type != "LineString" || f.Properties["left"] == nil {
+				t.Errorf("edge feature %v has no left side", f.Properties)
+			}
+		case "site":
+			var c [2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &c); err != nil || f.Geometry.Type != "Point" {
+				t.Fatalf("site feature %v has geometry %s", f.ID, f.Geometry.Coordinates)
+			}
+		}
+	}
+	if counts["cell"] != 20 || counts["site"] != 20 || counts["edge"] != edges {
+		t.Errorf("got features %v, want 20 cells, 20 sites and %d edges", counts, edges)
+	}
+	if math.Abs(area-40000) > 1e-6 {
+		t.Errorf("cell features cover %v, want 40000", area)
+	}
+}
+
+// A cell split by the clip polygon is one feature with several polygons.
+func TestGeoJSONSplitCells(t *testing.T) {
+	v := New(SiteSlice{{X: 50, Y: 80, ID: 1}, {X: 150, Y: 20, ID: 2}}, image.Rect(0, 0, 200, 100))
+	v.Clip = NewClipPolygon([]Point{{0, 0}, {200, 0}, {200, 100}, {120, 100}, {120, 30}, {80, 30}, {80, 100}, {0, 100}})
+	v.Generate()
+	var buf bytes.Buffer
+	if err := v.WriteGeoJSON(&buf, GeoJSONOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var fc geoJSONCollection
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+	pieces := 0
+	for _, f := range fc.Features {
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			t.Fatal(err)
+		}
+		pieces += len(polygons)
+	}
+	if len(fc.Features) != 2 || pieces != len(v.Cells()) || pieces <= 2 {
+		t.Errorf("got %d features with %d polygons for %d cells", len(fc.Features), pieces, len(v.Cells()))
+	}
+}
@@ -0,0 +1,218 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// CellIndex is a uniform grid over the bounding boxes of the cells of a
+// diagram, which answers which cells intersect a rectangle or a circle
+// without testing every cell. The index is not updated when the diagram
+// changes and has to be created again.
+type CellIndex struct {
+	cells []Cell
+	boxes [][2]Point // bounding box of each cell
+
+	min     Point
+	size    float64 // size of the buckets
+	columns int
+	rows    int
+	buckets [][]int // indices of the cells, whose box overlaps each bucket
+}
+
+// NewCellIndex creates an index over the cells of a diagram, as returned by
+// Voronoi.Cells. The grid has about as many buckets as there are cells.
+func NewCellIndex(v *Voronoi) *CellIndex {
+	idx := &CellIndex{cells: v.Cells()}
+	if len(idx.cells) == 0 {
+		return idx
+	}
+
+	min := Point{math.Inf(1), math.Inf(1)}
+	max := Point{math.Inf(-1), math.Inf(-1)}
+	for _, cell := range idx.cells {
+		lo, hi := polygonBox(cell.Polygon)
+		idx.boxes = append(idx.boxes, [2]Point{lo, hi})
+		min = Point{math.Min(min.X, lo.X), math.Min(min.Y, lo.Y)}
+		max = Point{math.Max(max.X, hi.X), math.Max(max.Y, hi.Y)}
+	}
+
+	idx.min = min
+	w, h := math.Max(max.X-min.X, 1), math.Max(max.Y-min.Y, 1)
+	idx.size = math.Sqrt(w * h / float64(len(idx.cells)))
+	idx.columns = int(math.Ceil(w/idx.size)) + 1
+	idx.rows = int(math.Ceil(h/idx.size)) + 1
+	idx.buckets = make([][]int, idx.columns*idx.rows)
+	for i, box := range idx.boxes {
+		c0, r0 := idx.bucket(box[0])
+		c1, r1 := idx.bucket(box[1])
+		for r := r0; r <= r1; r++ {
+			for c := c0; c <= c1; c++ {
+				idx.buckets[r*idx.columns+c] = append(idx.buckets[r*idx.columns+c], i)
+			}
+		}
+	}
+	return idx
+}
+
+// bucket returns the column and row of the bucket containing a point,
+// clamped to the grid.
+func (idx *CellIndex) bucket(p Point) (int, int) {
+	c := int(math.Floor((p.X - idx.min.X) / idx.size))
+	r := int(math.Floor((p.Y - idx.min.Y) / idx.size))
+	if c < 0 {
+		c = 0
+	} else if c >= idx.columns {
+		c = idx.columns - 1
+	}
+	if r < 0 {
+		r = 0
+	} else if r >= idx.rows {
+		r = idx.rows - 1
+	}
+	return c, r
+}
+
+// candidates returns the indices of the cells, whose bounding box overlaps
+// the given box, in the order of the cells.
+func (idx *CellIndex) candidates(lo, hi Point) []int {
+	if len(idx.cells) == 0 {
+		return nil
+	}
+	c0, r0 := idx.bucket(lo)
+	c1, r1 := idx.bucket(hi)
+	seen := make(map[int]bool)
+	var found []int
+	for r := r0; r <= r1; r++ {
+		for c := c0; c <= c1; c++ {
+			for _, i := range idx.buckets[r*idx.columns+c] {
+				box := idx.boxes[i]
+				if seen[i] || box[0].X > hi.X || box[1].X < lo.X || box[0].Y > hi.Y || box[1].Y < lo.Y {
+					continue
+				}
+				seen[i] = true
+				found = append(found, i)
+			}
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// CellsInRect returns the cells, whose polygon intersects the rectangle,
+// including cells touching its border.
+func (idx *CellIndex) CellsInRect(r image.Rectangle) []Cell {
+	lo := Point{float64(r.Min.X), float64(r.Min.Y)}
+	hi := Point{float64(r.Max.X), float64(r.Max.Y)}
+	rect := rectPolygon(r)
+	var cells []Cell
+	for _, i := range idx.candidates(lo, hi) {
+		if cellIntersects(&idx.cells[i], rect) {
+			cells = append(cells, idx.cells[i])
+		}
+	}
+	return cells
+}
+
+// CellsWithin returns the cells, which have a point within the given
+// distance from p, including the cell containing p.
+func (idx *CellIndex) CellsWithin(p Point, radius float64) []Cell {
+	lo := Point{p.X - radius, p.Y - radius}
+	hi := Point{p.X + radius, p.Y + radius}
+	var cells []Cell
+	for _, i := range idx.candidates(lo, hi) {
+		if cellDistance(&idx.cells[i], p) <= radius {
+			cells = append(cells, idx.cells[i])
+		}
+	}
+	return cells
+}
+
+// cellIntersects reports if a polygon overlaps or touches a cell, which it
+// does not if it lies inside a hole of the cell.
+func cellIntersects(cell *Cell, poly []Point) bool {
+	if !polygonsIntersect(cell.Polygon, poly) {
+		return false
+	}
+	for _, hole := range cell.Holes {
+		if pointInPolygon(poly[0], hole) && !boundariesTouch(hole, poly) {
+			return false
+		}
+	}
+	return true
+}
+
+// cellDistance returns the distance from a point to a cell, which is zero
+// for points inside the cell.
+func cellDistance(cell *Cell, p Point) float64 {
+	for _, hole := range cell.Holes {
+		if pointInPolygon(p, hole) {
+			d := math.Inf(1)
+			for i, a := range hole {
+				b := hole[(i+1)%len(hole)]
+				d = math.Min(d, dist(p, lerp(a, b, project(a, b, p))))
+			}
+			return d
+		}
+	}
+	return polygonDistance(cell.Polygon, p)
+}
+
+// polygonsIntersect reports if two polygons overlap or touch.
+func polygonsIntersect(a, b []Point) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if pointInPolygon(a[0], b) || pointInPolygon(b[0], a) {
+		return true
+	}
+	return boundariesTouch(a, b)
+}
+
+// boundariesTouch reports if the boundaries of two polygons have a common
+// point.
+func boundariesTouch(a, b []Point) bool {
+	for i, p := range a {
+		q := a[(i+1)%len(a)]
+		for j, r := range b {
+			s := b[(j+1)%len(b)]
+			if segmentsTouch(p, q, r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// segmentsTouch reports if segments ab and cd have a common point.
+func segmentsTouch(a, b, c, d Point) bool {
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return onSegment(c, d, a) || onSegment(c, d, b) || onSegment(a, b, c) || onSegment(a, b, d)
+}
+
+// onSegment reports if point p lies on segment ab.
+func onSegment(a, b, p Point) bool {
+	return dist(p, lerp(a, b, project(a, b, p))) < 1e-9
+}
+
+// polygonDistance returns the distance from a point to a polygon, which is
+// zero for points inside the polygon.
+func polygonDistance(poly []Point, p Point) float64 {
+	if len(poly) == 0 {
+		return math.Inf(1)
+	}
+	if pointInPolygon(p, poly) {
+		return 0
+	}
+	d := math.Inf(1)
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		d = math.Min(d, dist(p, lerp(a, b, project(a, b, p))))
+	}
+	return d
+}
@@ -0,0 +1,105 @@
+package voronoi
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestCellIndex(t *testing.T) {
+	bounds := image.Rect(0, 0, 500, 400)
+	for seed := int64(1); seed <= 4; seed++ {
+		v := New(randomSites(200, seed, bounds), bounds)
+		if seed%2 == 0 {
+			v.Metric = Chebyshev
+		}
+		v.Generate()
+		idx := NewCellIndex(v)
+		cells := v.Cells()
+
+		// The index finds the same cells as testing every cell, and those
+		// containing sample points of the query.
+		r := rand.New(rand.NewSource(seed))
+		for q := 0; q < 200; q++ {
+			x, y := r.Intn(600)-50, r.Intn(500)-50
+			rect := image.Rect(x, y, x+r.Intn(100), y+r.Intn(100))
+			want := make(map[int64]bool)
+			for i := range cells {
+				if cellIntersects(&cells[i], rectPolygon(rect)) {
+					want[cells[i].Site.ID] = true
+				}
+			}
+			got := make(map[int64]bool)
+			for _, cell := range idx.CellsInRect(rect) {
+				got[cell.Site.ID] = true
+			}
+			if !sameIDs(got, want) {
+				t.Fatalf("seed %d: cells in %v are %v, want %v", seed, rect, got, want)
+			}
+			for k := 0; k < 10; k++ {
+				p := Point{float64(x) + r.Float64()*float64(rect.Dx()), float64(y) + r.Float64()*float64(rect.Dy())}
+				for _, cell := range cells {
+					if cellContains(cell, p) && !got[cell.Site.ID] {
+						t.Fatalf("seed %d: cell of site %d contains %v in %v, but is not found", seed, cell.Site.ID, p, rect)
+					}
+				}
+			}
+
+			p := Point{float64(x), float64(y)}
+			radius := r.Float64() * 60
+			want = make(map[int64]bool)
+			for i := range cells {
+				if cellDistance(&cells[i], p) <= radius {
+					want[cells[i].Site.ID] = true
+				}
+			}
+			got = make(map[int64]bool)
+			for _, cell := range idx.CellsWithin(p, radius) {
+				got[cell.Site.ID] = true
+			}
+			if !sameIDs(got, want) {
+				t.Fatalf("seed %d: cells within %v of %v are %v, want %v", seed, radius, p, got, want)
+			}
+			for _, cell := range cells {
+				if cellContains(cell, p) && !got[cell.Site.ID] {
+					t.Fatalf("seed %d: cell of site %d contains %v, but is not found", seed, cell.Site.ID, p)
+				}
+			}
+		}
+	}
+}
+
+// Queries inside a hole of a cell do not find the cell.
+func TestCellIndexHoles(t *testing.T) {
+	clip := NewClipPolygon([]Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}})
+	clip.Holes = [][]Point{{{40, 40}, {40, 60}, {60, 60}, {60, 40}}}
+	v := New(SiteSlice{{X: 20, Y: 50, ID: 1}}, image.Rect(0, 0, 100, 100))
+	v.Clip = clip
+	v.Generate()
+	idx := NewCellIndex(v)
+	if cells := idx.CellsInRect(image.Rect(45, 45, 55, 55)); len(cells) != 0 {
+		t.Errorf("rectangle inside the hole meets %d cells", len(cells))
+	}
+	if cells := idx.CellsInRect(image.Rect(45, 45, 65, 55)); len(cells) != 1 {
+		t.Errorf("rectangle across the hole meets %d cells, want 1", len(cells))
+	}
+	if cells := idx.CellsWithin(Point{50, 50}, 5); len(cells) != 0 {
+		t.Errorf("circle inside the hole meets %d cells", len(cells))
+	}
+	if cells := idx.CellsWithin(Point{50, 50}, 10); len(cells) != 1 {
+		t.Errorf("circle touching the hole meets %d cells, want 1", len(cells))
+	}
+}
+
+// sameIDs reports if two sets of IDs are equal.
+func sameIDs(a, b map[int64]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if !b[id] {
+			return false
+		}
+	}
+	return true
+}
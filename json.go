@@ -0,0 +1,377 @@
+package voronoi
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+
+	"github.com/quasoft/dcel"
+)
+
+// diagramJSON is the JSON form of a diagram, into which Decoder reads the
+// binary encoding too, before linking it. Elements of the DCEL refer to each other by their index in the lists,
+// with -1 for no element.
+type diagramJSON struct {
+	Bounds       [4]int         `json:"bounds"`
+	Metric       Metric         `json:"metric,omitempty"`
+	Farthest     bool           `json:"farthest,omitempty"`
+	Clip         *clipJSON      `json:"clip,omitempty"`
+	Wrap         Wrap           `json:"wrap,omitempty"`
+	CurveSamples int            `json:"curveSamples,omitempty"`
+	SweepLine    int            `json:"sweepLine"`
+	Sites        []siteJSON     `json:"sites"`
+	Segments     []segmentJSON  `json:"segments,omitempty"`
+	Vertices     [][2]int       `json:"vertices"`
+	HalfEdges    []halfEdgeJSON `json:"halfEdges"`
+	Faces        []faceJSON     `json:"faces"`
+}
+
+type clipJSON struct {
+	Outer       [][2]float64   `json:"outer"`
+	Holes       [][][2]float64 `json:"holes,omitempty"`
+	DropOutside bool           `json:"dropOutside,omitempty"`
+}
+
+type siteJSON struct {
+	X       int         `json:"x"`
+	Y       int         `json:"y"`
+	ID      int64       `json:"id"`
+	Face    int         `json:"face"`
+	Data    interface{} `json:"data,omitempty"`
+	Outside bool        `json:"outside,omitempty"`
+}
+
+type segmentJSON struct {
+	A    [2]int      `json:"a"`
+	B    [2]int      `json:"b"`
+	ID   int64       `json:"id"`
+	Face int         `json:"face"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type halfEdgeJSON struct {
+	Target int `json:"target"`
+	Twin   int `json:"twin"`
+	Next   int `json:"next"`
+	Prev   int `json:"prev"`
+	// Unlisted is set for half-edges, which are linked from other elements,
+	// but missing from the list of half-edges of the DCEL.
+	Unlisted bool `json:"unlisted,omitempty"`
+}
+
+type faceJSON struct {
+	ID       int64 `json:"id"`
+	HalfEdge int   `json:"halfEdge"`
+	Site     int   `json:"site"`    // index of the site of the face, or -1
+	Segment  int   `json:"segment"` // index of the segment of the face, or -1
+}
+
+// MarshalJSON encodes the diagram with its options, sites, segments and
+// DCEL, replacing pointers with indices, so that a decoded diagram supports
+// the same queries. The Data of sites and segments is encoded with the
+// encoding/json package, and is decoded into generic values, like maps for
+// objects. The state of the sweep line of an unfinished diagram, and of
+// incremental updates, is not encoded.
+func (v *Voronoi) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.flatten())
+}
+
+// flatten returns the JSON form of the diagram. Encoder does not use it, but
+// writes the elements of the DCEL directly.
+func (v *Voronoi) flatten() *diagramJSON {
+	out := &diagramJSON{
+		Bounds:       [4]int{v.Bounds.Min.X, v.Bounds.Min.Y, v.Bounds.Max.X, v.Bounds.Max.Y},
+		Metric:       v.Metric,
+		Farthest:     v.Farthest,
+		Wrap:         v.Wrap,
+		CurveSamples: v.CurveSamples,
+		SweepLine:    v.SweepLine,
+		Sites:        []siteJSON{},
+		Vertices:     [][2]int{},
+		HalfEdges:    []halfEdgeJSON{},
+		Faces:        []faceJSON{},
+	}
+	if v.Clip != nil {
+		out.Clip = &clipJSON{Outer: pointPairs(v.Clip.Outer), DropOutside: v.Clip.DropOutside}
+		for _, hole := range v.Clip.Holes {
+			out.Clip.Holes = append(out.Clip.Holes, pointPairs(hole))
+		}
+	}
+
+	d := v.DCEL
+	if d == nil {
+		d = dcel.NewDCEL()
+	}
+	index := newDCELIndex(d)
+
+	sites := make(map[*Site]int)
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		sites[site] = i
+		out.Sites = append(out.Sites, siteJSON{
+			X: site.X, Y: site.Y, ID: site.ID, Face: index.face(site.Face), Data: site.Data, Outside: site.Outside,
+		})
+	}
+	segments := make(map[*Segment]int)
+	for i := range v.Segments {
+		segment := &v.Segments[i]
+		segments[segment] = i
+		out.Segments = append(out.Segments, segmentJSON{
+			A:    [2]int{segment.A.X, segment.A.Y},
+			B:    [2]int{segment.B.X, segment.B.Y},
+			ID:   segment.ID,
+			Face: index.face(segment.Face),
+			Data: segment.Data,
+		})
+	}
+
+	for _, face := range d.Faces {
+		f := faceJSON{ID: face.ID, HalfEdge: index.halfEdge(face.HalfEdge), Site: -1, Segment: -1}
+		switch data := face.Data.(type) {
+		case *Site:
+			if i, ok := sites[data]; ok {
+				f.Site = i
+			}
+		case *Segment:
+			if i, ok := segments[data]; ok {
+				f.Segment = i
+			}
+		}
+		out.Faces = append(out.Faces, f)
+	}
+
+	for i, he := range index.halfEdgeList {
+		out.HalfEdges = append(out.HalfEdges, halfEdgeJSON{
+			Target:   index.vertex(he.Target),
+			Twin:     index.halfEdge(he.Twin),
+			Next:     index.halfEdge(he.Next),
+			Prev:     index.halfEdge(he.Prev),
+			Unlisted: i >= index.listed,
+		})
+	}
+	for _, vertex := range index.vertexList {
+		out.Vertices = append(out.Vertices, [2]int{vertex.X, vertex.Y})
+	}
+	return out
+}
+
+// dcelIndex numbers the vertices, half-edges and faces of a DCEL, for the
+// JSON and the binary encodings. Vertices and half-edges linked from other
+// elements, but missing from the lists of the DCEL, are numbered after the
+// listed ones.
+type dcelIndex struct {
+	vertices     map[*dcel.Vertex]int
+	vertexList   []*dcel.Vertex
+	halfEdges    map[*dcel.HalfEdge]int
+	halfEdgeList []*dcel.HalfEdge
+	listed       int // number of half-edges in the list of the DCEL
+	faces        map[*dcel.Face]int
+}
+
+func newDCELIndex(d *dcel.DCEL) *dcelIndex {
+	x := &dcelIndex{
+		vertices:  make(map[*dcel.Vertex]int, len(d.Vertices)),
+		halfEdges: make(map[*dcel.HalfEdge]int, len(d.HalfEdges)),
+		faces:     make(map[*dcel.Face]int, len(d.Faces)),
+	}
+	for _, vertex := range d.Vertices {
+		x.addVertex(vertex)
+	}
+	for _, he := range d.HalfEdges {
+		x.addHalfEdge(he)
+	}
+	x.listed = len(x.halfEdgeList)
+	for i, face := range d.Faces {
+		x.faces[face] = i
+		x.addHalfEdge(face.HalfEdge)
+	}
+	// Half-edges linked from other half-edges are indexed while the list
+	// is walked, so the list may grow.
+	for i := 0; i < len(x.halfEdgeList); i++ {
+		he := x.halfEdgeList[i]
+		x.addVertex(he.Target)
+		x.addHalfEdge(he.Twin)
+		x.addHalfEdge(he.Next)
+		x.addHalfEdge(he.Prev)
+	}
+	return x
+}
+
+func (x *dcelIndex) addVertex(vertex *dcel.Vertex) {
+	if _, ok := x.vertices[vertex]; vertex != nil && !ok {
+		x.vertices[vertex] = len(x.vertexList)
+		x.vertexList = append(x.vertexList, vertex)
+	}
+}
+
+func (x *dcelIndex) addHalfEdge(he *dcel.HalfEdge) {
+	if _, ok := x.halfEdges[he]; he != nil && !ok {
+		x.halfEdges[he] = len(x.halfEdgeList)
+		x.halfEdgeList = append(x.halfEdgeList, he)
+	}
+}
+
+// vertex returns the index of a vertex, or -1 for nil.
+func (x *dcelIndex) vertex(vertex *dcel.Vertex) int {
+	if i, ok := x.vertices[vertex]; ok {
+		return i
+	}
+	return -1
+}
+
+// halfEdge returns the index of a half-edge, or -1 for nil.
+func (x *dcelIndex) halfEdge(he *dcel.HalfEdge) int {
+	if i, ok := x.halfEdges[he]; ok {
+		return i
+	}
+	return -1
+}
+
+// face returns the index of a face, or -1 for nil and faces not in the DCEL.
+func (x *dcelIndex) face(face *dcel.Face) int {
+	if i, ok := x.faces[face]; ok && face != nil {
+		return i
+	}
+	return -1
+}
+
+// UnmarshalJSON decodes a diagram encoded by MarshalJSON, replacing the
+// diagram, and links the sites, segments and faces again.
+func (v *Voronoi) UnmarshalJSON(data []byte) error {
+	var in diagramJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	return v.unflatten(&in)
+}
+
+// unflatten replaces the diagram with the one in flat form, decoded from JSON
+// or from the binary encoding.
+func (v *Voronoi) unflatten(in *diagramJSON) error {
+	d := dcel.NewDCEL()
+	vertices := make([]*dcel.Vertex, len(in.Vertices))
+	for i, p := range in.Vertices {
+		vertices[i] = d.NewVertex(p[0], p[1])
+	}
+	halfEdges := make([]*dcel.HalfEdge, len(in.HalfEdges))
+	for i := range halfEdges {
+		halfEdges[i] = &dcel.HalfEdge{}
+	}
+	halfEdge := func(i int) (*dcel.HalfEdge, error) {
+		if i < -1 || i >= len(halfEdges) {
+			return nil, fmt.Errorf("half-edge %d out of range", i)
+		}
+		if i < 0 {
+			return nil, nil
+		}
+		return halfEdges[i], nil
+	}
+	for i, e := range in.HalfEdges {
+		he := halfEdges[i]
+		if e.Target < -1 || e.Target >= len(vertices) {
+			return fmt.Errorf("vertex %d out of range", e.Target)
+		}
+		if e.Target >= 0 {
+			he.Target = vertices[e.Target]
+		}
+		var err error
+		if he.Twin, err = halfEdge(e.Twin); err != nil {
+			return err
+		}
+		if he.Next, err = halfEdge(e.Next); err != nil {
+			return err
+		}
+		if he.Prev, err = halfEdge(e.Prev); err != nil {
+			return err
+		}
+		if !e.Unlisted {
+			d.HalfEdges = append(d.HalfEdges, he)
+		}
+	}
+
+	sites := make(SiteSlice, len(in.Sites))
+	segments := make([]Segment, len(in.Segments))
+	for _, f := range in.Faces {
+		face := d.NewFace()
+		face.ID = f.ID
+		var err error
+		if face.HalfEdge, err = halfEdge(f.HalfEdge); err != nil {
+			return err
+		}
+		if f.Site >= len(sites) || f.Segment >= len(segments) {
+			return fmt.Errorf("face %d refers to a missing site", f.ID)
+		}
+		if f.Site >= 0 {
+			face.Data = &sites[f.Site]
+		} else if f.Segment >= 0 {
+			face.Data = &segments[f.Segment]
+		}
+	}
+	face := func(i int) (*dcel.Face, error) {
+		if i < -1 || i >= len(d.Faces) {
+			return nil, fmt.Errorf("face %d out of range", i)
+		}
+		if i < 0 {
+			return nil, nil
+		}
+		return d.Faces[i], nil
+	}
+	for i, s := range in.Sites {
+		f, err := face(s.Face)
+		if err != nil {
+			return err
+		}
+		sites[i] = Site{X: s.X, Y: s.Y, ID: s.ID, Face: f, Data: s.Data, Outside: s.Outside}
+	}
+	for i, s := range in.Segments {
+		f, err := face(s.Face)
+		if err != nil {
+			return err
+		}
+		segments[i] = Segment{
+			A:    image.Point{s.A[0], s.A[1]},
+			B:    image.Point{s.B[0], s.B[1]},
+			ID:   s.ID,
+			Face: f,
+			Data: s.Data,
+		}
+	}
+
+	*v = Voronoi{
+		Bounds:       image.Rect(in.Bounds[0], in.Bounds[1], in.Bounds[2], in.Bounds[3]),
+		Sites:        sites,
+		EventQueue:   EventQueue{},
+		SweepLine:    in.SweepLine,
+		DCEL:         d,
+		Metric:       in.Metric,
+		Farthest:     in.Farthest,
+		Wrap:         in.Wrap,
+		CurveSamples: in.CurveSamples,
+	}
+	if len(segments) > 0 {
+		v.Segments = segments
+	}
+	if in.Clip != nil {
+		v.Clip = &ClipPolygon{Outer: pairPoints(in.Clip.Outer), DropOutside: in.Clip.DropOutside}
+		for _, hole := range in.Clip.Holes {
+			v.Clip.Holes = append(v.Clip.Holes, pairPoints(hole))
+		}
+	}
+	return nil
+}
+
+func pointPairs(points []Point) [][2]float64 {
+	pairs := make([][2]float64, len(points))
+	for i, p := range points {
+		pairs[i] = [2]float64{p.X, p.Y}
+	}
+	return pairs
+}
+
+func pairPoints(pairs [][2]float64) []Point {
+	points := make([]Point, len(pairs))
+	for i, p := range pairs {
+		points[i] = Point{p[0], p[1]}
+	}
+	return points
+}
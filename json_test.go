@@ -0,0 +1,96 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	periodic := NewPeriodic(randomSites(30, 4, bounds), bounds, WrapX)
+	periodic.Generate()
+	manhattan := NewWithMetric(randomSites(30, 5, bounds), bounds, Manhattan)
+	manhattan.Generate()
+	diagrams := append(binaryTestDiagrams(), periodic, manhattan)
+
+	for k, want := range diagrams {
+		want.Sites[0].Data = map[string]interface{}{"name": "first"}
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Voronoi
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("diagram %d: %v", k, err)
+		}
+		if got.Bounds != want.Bounds || got.Metric != want.Metric || got.Wrap != want.Wrap || (got.Clip == nil) != (want.Clip == nil) {
+			t.Errorf("diagram %d: options differ", k)
+		}
+		if name := got.Sites[0].Data.(map[string]interface{})["name"]; name != "first" {
+			t.Errorf("diagram %d: data of the first site is %v", k, got.Sites[0].Data)
+		}
+		for i := range got.Sites {
+			if face := got.Sites[i].Face; face != nil && face.Data != &got.Sites[i] {
+				t.Fatalf("diagram %d: face of site %d belongs to %v", k, i, face.Data)
+			}
+		}
+
+		// The decoded diagram has the same cells, and encodes the same way.
+		wantCells, gotCells := want.Cells(), got.Cells()
+		if len(gotCells) != len(wantCells) {
+			t.Fatalf("diagram %d: got %d cells, want %d", k, len(gotCells), len(wantCells))
+		}
+		for i := range gotCells {
+			w, g := wantCells[i], gotCells[i]
+			if (g.Site == nil) != (w.Site == nil) || g.Site != nil && g.Site.ID != w.Site.ID || !sameVertices(g.Polygon, w.Polygon) {
+				t.Fatalf("diagram %d: cell %d has polygon %v, want %v", k, i, g.Polygon, w.Polygon)
+			}
+		}
+		again, err := json.Marshal(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("diagram %d: encodes differently after a round trip", k)
+		}
+	}
+
+	// Sites can be inserted into a decoded diagram.
+	var v Voronoi
+	data, _ := json.Marshal(diagrams[0])
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Insert(Site{X: 77, Y: 88, ID: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if area := cellsArea(v.Cells()); math.Abs(area-40000) > 1e-6 {
+		t.Errorf("cells cover %v after an insertion, want 40000", area)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	v := New(SiteSlice{{X: 10, Y: 10, ID: 1}, {X: 60, Y: 40, ID: 2}}, image.Rect(0, 0, 100, 100))
+	v.Generate()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, replace := range [][2]string{
+		{`"target":`, `"target":99,"x":`},
+		{`"twin":`, `"twin":-5,"x":`},
+		{`"halfEdge":`, `"halfEdge":99,"x":`},
+		{`"site":`, `"site":7,"x":`},
+		{`"face":`, `"face":42,"x":`},
+	} {
+		bad := strings.Replace(string(data), replace[0], replace[1], 1)
+		var w Voronoi
+		if err := json.Unmarshal([]byte(bad), &w); err == nil {
+			t.Errorf("diagram with %s decoded", replace[1])
+		}
+	}
+}
@@ -0,0 +1,1025 @@
+package voronoi
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// NeighborEvent reports a change of the neighbourhood of two sites: their
+// cells started or stopped sharing an edge, or the sites met at one location.
+// An edge flip of the Delaunay triangulation shows up as one pair of sites
+// separating and another joining at the same time.
+type NeighborEvent struct {
+	A, B   int64 // IDs of the sites, with A < B
+	Joined bool  // true if the cells became neighbours, false if they separated
+	// Collided is set, when the sites met at one location. The site later in
+	// Voronoi.Sites then shares the cell of the other one, and separates from
+	// all its neighbours, until the end of the step.
+	Collided bool
+	Time     float64 // time of the change since the start of the step
+}
+
+// Kinetic is a voronoi diagram of moving sites, which keeps the Delaunay
+// triangulation of their exact positions. Each edge of the triangulation has
+// a certificate - a polynomial in time, which stays negative as long as the
+// edge is Delaunay. The sites move along straight lines during a step, and
+// the edges are flipped in the order, in which their certificates fail,
+// each flip reporting the neighbour events at its time. The triangulation is
+// closed by triangles with a point at infinity outside the convex hull, so
+// sites joining or leaving the hull are flips too. At the end of the step,
+// only the cells of the moved sites and of their neighbours are computed
+// again, from the Delaunay neighbours of the sites.
+//
+// Cells follow the exact positions, while the sites of the diagram lie at
+// the positions rounded to integer coordinates, where several sites may meet.
+// Sites meeting at exactly one location are a degenerate event: the site
+// later in Voronoi.Sites shares the cell of the other one, as in Generate,
+// until the end of a step, at which they are apart again. While all
+// sites lie on one line, no triangulation exists, and the changes are
+// reported at the end of each step.
+// Has the same requirements as Voronoi.Insert. The sites keep their place in
+// Voronoi.Sites, and must not be inserted or deleted while they move.
+type Kinetic struct {
+	Voronoi *Voronoi
+
+	editor     *editor
+	ids        []int64       // IDs of the sites, by their place in Voronoi.Sites
+	index      map[int64]int // place of each site in Voronoi.Sites
+	positions  []Point       // exact positions at the start of the step
+	velocities map[int64]Point
+	hidden     map[int]int // sites sharing the cell of another site, to its place
+	tri        *triangulation
+	linked     map[[2]int]bool // pairs of neighbouring sites
+
+	// State of the current step.
+	motion   []Point // velocity of each site
+	length   float64
+	queue    certificateQueue
+	versions map[[2]int]int // stamp of the latest certificate of each edge
+	stamp    int
+	events   []NeighborEvent
+}
+
+// NewKinetic prepares a generated diagram for moving its sites.
+func NewKinetic(v *Voronoi) (*Kinetic, error) {
+	if err := v.initEditor(); err != nil {
+		return nil, err
+	}
+	k := &Kinetic{
+		Voronoi:    v,
+		editor:     v.editor,
+		index:      make(map[int64]int, len(v.Sites)),
+		velocities: make(map[int64]Point),
+		hidden:     make(map[int]int),
+		motion:     make([]Point, len(v.Sites)),
+	}
+	for i := range v.Sites {
+		k.ids = append(k.ids, v.Sites[i].ID)
+		k.index[v.Sites[i].ID] = i
+		k.positions = append(k.positions, pointOf(&v.Sites[i]))
+	}
+	k.triangulate(0)
+	k.linked = k.links(0)
+
+	// The neighbours found by the editor may include both diagonals of sites
+	// on one circle, of which the triangulation has one.
+	e := k.editor
+	for id, neighbors := range e.neighbors {
+		for other := range neighbors {
+			if !k.linked[k.pair(k.index[id], k.index[other])] {
+				e.setLink(id, other, false)
+			}
+		}
+	}
+	for pair := range k.linked {
+		e.setLink(k.ids[pair[0]], k.ids[pair[1]], true)
+	}
+	return k, nil
+}
+
+// SetVelocity sets the velocity of a site, in units per unit of time.
+func (k *Kinetic) SetVelocity(id int64, vx, vy float64) error {
+	if _, ok := k.index[id]; !ok {
+		return fmt.Errorf("no site with ID %d", id)
+	}
+	if vx == 0 && vy == 0 {
+		delete(k.velocities, id)
+	} else {
+		k.velocities[id] = Point{vx, vy}
+	}
+	return nil
+}
+
+// Position returns the exact position of a site. Sites of the diagram lie at
+// the position rounded to integer coordinates.
+func (k *Kinetic) Position(id int64) (Point, bool) {
+	i, ok := k.index[id]
+	if !ok {
+		return Point{}, false
+	}
+	return k.positions[i], true
+}
+
+// Advance moves the sites with a velocity for the given time and returns the
+// neighbour events in the order of their times, from 0 to dt.
+func (k *Kinetic) Advance(dt float64) ([]NeighborEvent, error) {
+	if dt < 0 {
+		return nil, errors.New("time step must not be negative")
+	}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	end := make([]Point, len(k.ids))
+	for i, id := range k.ids {
+		k.motion[i] = k.velocities[id]
+		end[i] = k.at(i, dt)
+	}
+	return k.step(dt, end), nil
+}
+
+// Move moves the sites to new positions along straight lines, during one
+// unit of time, and returns the neighbour events in the order of their
+// times, from 0 to 1. The other sites stay in place.
+func (k *Kinetic) Move(positions map[int64]Point) ([]NeighborEvent, error) {
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	end := make([]Point, len(k.ids))
+	copy(end, k.positions)
+	for i := range k.motion {
+		k.motion[i] = Point{}
+	}
+	for id, p := range positions {
+		i, ok := k.index[id]
+		if !ok {
+			return nil, fmt.Errorf("no site with ID %d", id)
+		}
+		k.motion[i] = Point{p.X - k.positions[i].X, p.Y - k.positions[i].Y}
+		end[i] = p
+	}
+	return k.step(1, end), nil
+}
+
+// check reports an error if the sites of the diagram changed since the
+// kinetic diagram was created.
+func (k *Kinetic) check() error {
+	v := k.Voronoi
+	if v.editor != k.editor || len(v.Sites) != len(k.ids) {
+		return errors.New("the sites of the diagram changed")
+	}
+	for i := range v.Sites {
+		if v.Sites[i].ID != k.ids[i] {
+			return errors.New("the sites of the diagram changed")
+		}
+	}
+	return nil
+}
+
+// at returns the position of a site at time t of the step.
+func (k *Kinetic) at(i int, t float64) Point {
+	p, m := k.positions[i], k.motion[i]
+	return Point{p.X + m.X*t, p.Y + m.Y*t}
+}
+
+// step processes the events of a step of the given length, after which the
+// sites are at the end positions, and updates the diagram.
+func (k *Kinetic) step(length float64, end []Point) []NeighborEvent {
+	k.length = length
+	k.events = nil
+	linked := make(map[[2]int]bool, len(k.linked))
+	for pair := range k.linked {
+		linked[pair] = true
+	}
+	hidden := make(map[int]int, len(k.hidden))
+	for i, j := range k.hidden {
+		hidden[i] = j
+	}
+
+	// Flips stop after too many events at a degenerate configuration, after
+	// which the sites are triangulated again at the end of the step.
+	stuck := false
+	if k.tri != nil {
+		k.scheduleAll(0)
+		for flips := 0; k.queue.Len() > 0; flips++ {
+			if flips > 64*len(k.ids)*len(k.ids)+1024 {
+				stuck = true
+				break
+			}
+			ev := heap.Pop(&k.queue).(certificateEvent)
+			if k.versions[ev.edge] != ev.version {
+				continue
+			}
+			if ev.collision {
+				k.collide(ev.edge, ev.time)
+				if k.tri == nil {
+					break
+				}
+				continue
+			}
+			k.flip(ev.edge, ev.time)
+		}
+	}
+	k.queue = k.queue[:0]
+
+	copy(k.positions, end)
+	for i := range k.motion {
+		k.motion[i] = Point{}
+	}
+	if stuck || k.tri == nil || k.collinear() {
+		k.tri = nil
+		for i, j := range k.hidden {
+			if !samePosition(k.positions[i], k.positions[k.host(j)]) {
+				delete(k.hidden, i)
+			}
+		}
+		k.triangulate(0)
+	} else {
+		// Sites, which moved away from the site they met, join again.
+		var apart []int
+		for i, j := range k.hidden {
+			if !samePosition(k.positions[i], k.positions[k.host(j)]) {
+				apart = append(apart, i)
+			}
+		}
+		sort.Ints(apart)
+		for _, i := range apart {
+			delete(k.hidden, i)
+			k.insert(i, 0)
+		}
+		k.openHull()
+	}
+	k.relink(length)
+
+	k.updateDiagram(linked, hidden)
+	return k.events
+}
+
+// collinear reports if the visible sites lie on one line at the start of
+// the step, where the triangulation has only flat triangles left.
+func (k *Kinetic) collinear() bool {
+	var visible []int
+	for i := range k.ids {
+		if _, ok := k.hidden[i]; !ok {
+			visible = append(visible, i)
+		}
+	}
+	if len(visible) < 3 {
+		return true
+	}
+	for _, i := range visible[2:] {
+		if o := k.orientation(visible[0], visible[1], i); math.Abs(o.f.at(0)) > o.tol {
+			return false
+		}
+	}
+	return true
+}
+
+// openHull flips the edges of the convex hull, on which a site stopped, so
+// that the site joins the hull. Their certificates stay at zero.
+func (k *Kinetic) openHull() {
+	for flipped := true; flipped; {
+		flipped = false
+		for e, r := range k.tri.third {
+			s := k.tri.third[[2]int{e[1], e[0]}]
+			if r != infinite || e[0] == infinite || e[1] == infinite || s == infinite {
+				continue
+			}
+			a, b := k.positions[e[0]], k.positions[e[1]]
+			o := k.orientation(e[0], e[1], s)
+			if d := project(a, b, k.positions[s]); math.Abs(o.f.at(0)) <= o.tol && d > 0 && d < 1 {
+				k.tri.flip(e[0], e[1])
+				flipped = true
+				break
+			}
+		}
+	}
+}
+
+// host returns the visible site, whose cell a hidden site shares.
+func (k *Kinetic) host(i int) int {
+	for {
+		j, ok := k.hidden[i]
+		if !ok {
+			return i
+		}
+		i = j
+	}
+}
+
+// flip flips an edge, whose certificate failed at time t.
+func (k *Kinetic) flip(edge [2]int, t float64) {
+	p, q := edge[0], edge[1]
+	r, s := k.tri.flip(p, q)
+	delete(k.versions, edge)
+	k.setLink(p, q, false, t)
+	k.setLink(r, s, true, t)
+
+	// The new edge is Delaunay right after t, the edges around it have
+	// new certificates.
+	k.schedule(k.pair(r, s), t, true)
+	for _, e := range [][2]int{{p, s}, {s, q}, {q, r}, {r, p}} {
+		k.schedule(k.pair(e[0], e[1]), t, false)
+	}
+}
+
+// collide hides the later one of two sites, which met at time t, in the cell
+// of the other one, and triangulates the sites again.
+func (k *Kinetic) collide(edge [2]int, t float64) {
+	i, j := edge[0], edge[1]
+	k.hidden[j] = i
+	a, b := k.ids[i], k.ids[j]
+	if a > b {
+		a, b = b, a
+	}
+	k.events = append(k.events, NeighborEvent{A: a, B: b, Collided: true, Time: t})
+
+	k.tri = nil
+	k.triangulate(t)
+	k.relink(t)
+	if k.tri != nil {
+		k.scheduleAll(t)
+	}
+}
+
+// setLink records a changed link between two sites as an event. Links to
+// the point at infinity are not reported.
+func (k *Kinetic) setLink(i, j int, joined bool, t float64) {
+	if i == infinite || j == infinite {
+		return
+	}
+	pair := k.pair(i, j)
+	if joined {
+		k.linked[pair] = true
+	} else {
+		delete(k.linked, pair)
+	}
+	a, b := k.ids[pair[0]], k.ids[pair[1]]
+	if a > b {
+		a, b = b, a
+	}
+	k.events = append(k.events, NeighborEvent{A: a, B: b, Joined: joined, Time: t})
+}
+
+// relink reports the differences between the recorded links and the links
+// of the current triangulation as events at time t.
+func (k *Kinetic) relink(t float64) {
+	links := k.links(t)
+	var changed [][2]int
+	for pair := range k.linked {
+		if !links[pair] {
+			changed = append(changed, pair)
+		}
+	}
+	for pair := range links {
+		if !k.linked[pair] {
+			changed = append(changed, pair)
+		}
+	}
+	sort.Slice(changed, func(a, b int) bool {
+		x, y := changed[a], changed[b]
+		if k.ids[x[0]] != k.ids[y[0]] {
+			return k.ids[x[0]] < k.ids[y[0]]
+		}
+		return k.ids[x[1]] < k.ids[y[1]]
+	})
+	for _, pair := range changed {
+		k.setLink(pair[0], pair[1], links[pair], t)
+	}
+}
+
+// links returns the pairs of neighbouring sites: the finite edges of the
+// triangulation, or the consecutive sites, while they lie on one line.
+func (k *Kinetic) links(t float64) map[[2]int]bool {
+	links := make(map[[2]int]bool)
+	if k.tri != nil {
+		for e := range k.tri.third {
+			if e[0] != infinite && e[1] != infinite && e[0] < e[1] {
+				links[e] = true
+			}
+		}
+		return links
+	}
+
+	var visible []int
+	for i := range k.ids {
+		if _, ok := k.hidden[i]; !ok {
+			visible = append(visible, i)
+		}
+	}
+	if len(visible) < 2 {
+		return links
+	}
+	origin := k.at(visible[0], t)
+	far := origin
+	for _, i := range visible {
+		if p := k.at(i, t); dist(p, origin) > dist(far, origin) {
+			far = p
+		}
+	}
+	along := func(i int) float64 {
+		p := k.at(i, t)
+		return (p.X-origin.X)*(far.X-origin.X) + (p.Y-origin.Y)*(far.Y-origin.Y)
+	}
+	sort.Slice(visible, func(a, b int) bool { return along(visible[a]) < along(visible[b]) })
+	for n := 1; n < len(visible); n++ {
+		links[k.pair(visible[n-1], visible[n])] = true
+	}
+	return links
+}
+
+// pair returns the key of the edge between two sites.
+func (k *Kinetic) pair(i, j int) [2]int {
+	if i > j {
+		i, j = j, i
+	}
+	return [2]int{i, j}
+}
+
+// updateDiagram updates the editor of the diagram after a step: the sites
+// move to their rounded positions, and the cells of the moved sites, and of
+// the sites whose neighbours changed, are computed again from the exact
+// positions of their neighbours.
+func (k *Kinetic) updateDiagram(linked map[[2]int]bool, hidden map[int]int) {
+	v, e := k.Voronoi, k.editor
+	touched := make(map[int]bool)
+	for pair := range linked {
+		if !k.linked[pair] {
+			touched[pair[0]], touched[pair[1]] = true, true
+		}
+	}
+	for pair := range k.linked {
+		if !linked[pair] {
+			touched[pair[0]], touched[pair[1]] = true, true
+		}
+	}
+	moved := make(map[int]bool)
+	for i := range k.ids {
+		_, was := hidden[i]
+		_, is := k.hidden[i]
+		if p := k.positions[i]; p != pointOf(&v.Sites[i]) || was != is {
+			moved[i] = true
+			touched[i] = true
+		}
+	}
+	for _, links := range []map[[2]int]bool{linked, k.linked} {
+		for pair := range links {
+			if moved[pair[0]] || moved[pair[1]] {
+				touched[pair[0]], touched[pair[1]] = true, true
+			}
+		}
+	}
+
+	for i := range moved {
+		site := &v.Sites[i]
+		if _, ok := hidden[i]; !ok {
+			e.grid.remove(site.ID, pointOf(site))
+		}
+		site.X = int(math.Round(k.positions[i].X))
+		site.Y = int(math.Round(k.positions[i].Y))
+		if _, ok := k.hidden[i]; !ok {
+			e.grid.add(site.ID, pointOf(site))
+		}
+	}
+	for pair := range linked {
+		if !k.linked[pair] {
+			e.setLink(k.ids[pair[0]], k.ids[pair[1]], false)
+		}
+	}
+	for pair := range k.linked {
+		e.setLink(k.ids[pair[0]], k.ids[pair[1]], true)
+	}
+	e.shared = make(map[int64][]int64)
+	var shared []int
+	for i := range k.hidden {
+		shared = append(shared, i)
+	}
+	sort.Ints(shared)
+	for _, i := range shared {
+		host := k.ids[k.host(i)]
+		e.shared[host] = append(e.shared[host], k.ids[i])
+	}
+
+	var ids []int64
+	for i := range touched {
+		id := k.ids[i]
+		ids = append(ids, id)
+		if _, ok := k.hidden[i]; ok {
+			delete(e.cells, id)
+			continue
+		}
+		cell := rectPolygon(e.box)
+		for other := range e.neighbors[id] {
+			cell = clipCloser(cell, k.positions[i], k.positions[k.index[other]])
+		}
+		e.cells[id] = cell
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	e.rebuild(v, e.clipToBounds(v.Bounds, ids))
+}
+
+// samePosition reports if two positions are equal up to rounding errors.
+func samePosition(a, b Point) bool {
+	return dist(a, b) <= 1e-9*(1+math.Abs(a.X)+math.Abs(a.Y))
+}
+
+// infinite is the point at infinity, which closes the triangulation.
+const infinite = -1
+
+// triangulation is a triangulation of the plane, in which the triangles
+// outside the convex hull of the points share the point at infinity. It
+// keeps the third vertex of the triangle to the left of each directed edge,
+// with the vertices of each triangle ordered counter-clockwise.
+type triangulation struct {
+	third map[[2]int]int
+	last  [2]int // an edge of a finite triangle, from which to search
+}
+
+func (tri *triangulation) add(a, b, c int) {
+	tri.third[[2]int{a, b}] = c
+	tri.third[[2]int{b, c}] = a
+	tri.third[[2]int{c, a}] = b
+	if a != infinite && b != infinite && c != infinite {
+		tri.last = [2]int{a, b}
+	}
+}
+
+func (tri *triangulation) remove(a, b, c int) {
+	delete(tri.third, [2]int{a, b})
+	delete(tri.third, [2]int{b, c})
+	delete(tri.third, [2]int{c, a})
+}
+
+// flip replaces the edge pq by the edge rs between the vertices opposite to
+// it, and returns r and s, where pqr was the triangle to the left of pq.
+func (tri *triangulation) flip(p, q int) (r, s int) {
+	r, s = tri.third[[2]int{p, q}], tri.third[[2]int{q, p}]
+	tri.remove(p, q, r)
+	tri.remove(q, p, s)
+	tri.add(r, p, s)
+	tri.add(s, q, r)
+	return r, s
+}
+
+// triangulate builds the Delaunay triangulation of the sites at time t,
+// hiding the sites at the location of another site. The triangulation is
+// nil, if the sites lie on one line.
+func (k *Kinetic) triangulate(t float64) {
+	k.tri = nil
+	var order []int
+	for i := range k.ids {
+		if _, ok := k.hidden[i]; !ok {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool {
+		p, q := k.at(order[a], t), k.at(order[b], t)
+		if p.X != q.X {
+			return p.X < q.X
+		}
+		if p.Y != q.Y {
+			return p.Y < q.Y
+		}
+		return order[a] < order[b]
+	})
+	var visible []int
+	for _, i := range order {
+		if n := len(visible); n > 0 && samePosition(k.at(i, t), k.at(visible[n-1], t)) {
+			k.hidden[i] = visible[n-1]
+			continue
+		}
+		visible = append(visible, i)
+	}
+	if len(visible) < 3 {
+		return
+	}
+
+	a, b, c := visible[0], visible[1], -1
+	for _, i := range visible[2:] {
+		if o := k.orientation(a, b, i); math.Abs(o.f.at(t)) > o.tol {
+			c = i
+			break
+		}
+	}
+	if c < 0 {
+		return
+	}
+	if cross(k.at(a, t), k.at(b, t), k.at(c, t)) < 0 {
+		b, c = c, b
+	}
+	k.tri = &triangulation{third: make(map[[2]int]int, 6*len(visible))}
+	k.tri.add(b, a, infinite)
+	k.tri.add(c, b, infinite)
+	k.tri.add(a, c, infinite)
+	k.tri.add(a, b, c)
+	for _, i := range visible[2:] {
+		if i != b && i != c {
+			k.insert(i, t)
+		}
+	}
+}
+
+// insert adds a site to the triangulation at time t, and flips the edges
+// around it until the triangulation is Delaunay again. A site at the
+// location of another site is hidden instead.
+func (k *Kinetic) insert(i int, t float64) {
+	tri := k.tri
+	p := k.at(i, t)
+	e := k.locate(p, t)
+	a, b, c := e[0], e[1], tri.third[e]
+	for _, j := range []int{a, b, c} {
+		if j != infinite && samePosition(p, k.at(j, t)) {
+			k.hidden[i] = k.host(j)
+			return
+		}
+	}
+
+	if c == infinite {
+		tri.remove(a, b, c)
+		tri.add(a, b, i)
+		tri.add(b, infinite, i)
+		tri.add(infinite, a, i)
+		k.legalize(i, t, [][2]int{{a, b}, {b, infinite}, {infinite, a}})
+		return
+	}
+	// A site on an edge splits both triangles along it.
+	for _, edge := range [][3]int{{a, b, c}, {b, c, a}, {c, a, b}} {
+		u, w, x := edge[0], edge[1], edge[2]
+		if o := k.orientation(u, w, i); math.Abs(o.f.at(t)) <= o.tol {
+			y := tri.third[[2]int{w, u}]
+			tri.remove(u, w, x)
+			tri.remove(w, u, y)
+			tri.add(u, i, x)
+			tri.add(i, w, x)
+			tri.add(w, i, y)
+			tri.add(i, u, y)
+			k.legalize(i, t, [][2]int{{x, u}, {w, x}, {y, w}, {u, y}})
+			return
+		}
+	}
+	tri.remove(a, b, c)
+	tri.add(a, b, i)
+	tri.add(b, c, i)
+	tri.add(c, a, i)
+	k.legalize(i, t, [][2]int{{a, b}, {b, c}, {c, a}})
+}
+
+// locate returns the triangle containing a point as the directed edge, which
+// has the triangle on its left. A point outside the convex hull is contained
+// by a triangle with the point at infinity beyond its finite edge. Walks
+// from the last finite triangle towards the point.
+func (k *Kinetic) locate(p Point, t float64) [2]int {
+	tri := k.tri
+	finite := func(e [2]int) bool {
+		c, ok := tri.third[e]
+		return ok && e[0] != infinite && e[1] != infinite && c != infinite
+	}
+	e := tri.last
+	if !finite(e) {
+		for other := range tri.third {
+			if finite(other) {
+				e = other
+				break
+			}
+		}
+	}
+
+	for steps := 0; steps <= len(tri.third); steps++ {
+		a, b, c := e[0], e[1], tri.third[e]
+		inside := true
+		for _, edge := range [][2]int{{a, b}, {b, c}, {c, a}} {
+			if cross(k.at(edge[0], t), k.at(edge[1], t), p) < 0 {
+				e = [2]int{edge[1], edge[0]}
+				inside = false
+				break
+			}
+		}
+		if inside {
+			return e
+		}
+		if tri.third[e] == infinite {
+			return e
+		}
+	}
+
+	// The walk only cycles for rounding errors, so the triangles are searched.
+	for e, c := range tri.third {
+		if c == infinite && e[0] != infinite && e[1] != infinite && cross(k.at(e[0], t), k.at(e[1], t), p) > 0 {
+			return e
+		}
+		if finite(e) && cross(k.at(e[0], t), k.at(e[1], t), p) >= 0 &&
+			cross(k.at(e[1], t), k.at(c, t), p) >= 0 && cross(k.at(c, t), k.at(e[0], t), p) >= 0 {
+			return e
+		}
+	}
+	return e
+}
+
+// legalize flips the edges opposite to a new site, which are not Delaunay at
+// time t, and the edges opposite to it after each flip.
+func (k *Kinetic) legalize(i int, t float64, edges [][2]int) {
+	for len(edges) > 0 {
+		e := edges[len(edges)-1]
+		edges = edges[:len(edges)-1]
+		s := k.tri.third[[2]int{e[1], e[0]}]
+		if c := k.certificate(e[0], e[1], i, s); c.f.at(t) > c.tol {
+			k.tri.flip(e[0], e[1])
+			edges = append(edges, [2]int{e[0], s}, [2]int{s, e[1]})
+		}
+	}
+}
+
+// certificate is a polynomial in time, which is positive while an edge is
+// not Delaunay.
+type certificate struct {
+	f   poly
+	tol float64 // values within the tolerance of zero are taken for zero
+}
+
+// certificate returns the certificate of the edge pq between the triangles
+// pqr and qps. For finite sites, it is positive when s lies inside the
+// circle through p, q and r. The circle through the point at infinity and
+// two sites is the line through them, so an edge to the point at infinity
+// fails when the site between its neighbours on the hull stops being convex,
+// and an edge of the hull fails when its triangle turns over.
+func (k *Kinetic) certificate(p, q, r, s int) certificate {
+	switch infinite {
+	case r:
+		return k.orientation(p, q, s)
+	case s:
+		return k.orientation(q, p, r)
+	case q:
+		return k.orientation(r, p, s)
+	case p:
+		return k.orientation(s, q, r)
+	}
+	return k.inCircle(p, q, r, s)
+}
+
+// orientation returns twice the signed area of the triangle abc, which is
+// positive if it is counter-clockwise.
+func (k *Kinetic) orientation(a, b, c int) certificate {
+	bx, by := k.relative(b, a)
+	cx, cy := k.relative(c, a)
+	l := k.extent(bx, by, cx, cy)
+	return certificate{bx.mul(cy).sub(by.mul(cx)), 1e-13 * l * l}
+}
+
+// inCircle returns the determinant, which is positive if d lies inside the
+// circle through the counter-clockwise triangle abc.
+func (k *Kinetic) inCircle(a, b, c, d int) certificate {
+	ax, ay := k.relative(a, d)
+	bx, by := k.relative(b, d)
+	cx, cy := k.relative(c, d)
+	az := ax.mul(ax).add(ay.mul(ay))
+	bz := bx.mul(bx).add(by.mul(by))
+	cz := cx.mul(cx).add(cy.mul(cy))
+	f := ax.mul(by.mul(cz).sub(cy.mul(bz))).
+		sub(ay.mul(bx.mul(cz).sub(cx.mul(bz)))).
+		add(az.mul(bx.mul(cy).sub(cx.mul(by))))
+	l := k.extent(ax, ay, bx, by, cx, cy)
+	return certificate{f, 1e-13 * l * l * l * l}
+}
+
+// relative returns the coordinates of site i relative to site j, as
+// polynomials in time.
+func (k *Kinetic) relative(i, j int) (x, y poly) {
+	p, q := k.positions[i], k.positions[j]
+	m, n := k.motion[i], k.motion[j]
+	return poly{p.X - q.X, m.X - n.X}, poly{p.Y - q.Y, m.Y - n.Y}
+}
+
+// extent returns the largest absolute value of the linear coordinates during
+// the step.
+func (k *Kinetic) extent(coordinates ...poly) float64 {
+	var l float64
+	for _, c := range coordinates {
+		l = math.Max(l, math.Max(math.Abs(c.at(0)), math.Abs(c.at(k.length))))
+	}
+	return l
+}
+
+// failure returns the first time from t0 until the end of the step, at which
+// the certificate becomes positive. A certificate, which is zero at t0, fails
+// then only if it grows from zero. The certificates of edges created by a
+// flip at t0 are zero there.
+func (k *Kinetic) failure(c certificate, t0 float64, fresh bool) (float64, bool) {
+	ends := append([]float64{t0}, polyRoots(c.f.derivative(), t0, k.length)...)
+	ends = append(ends, k.length)
+	// The certificate is monotonic between the ends.
+	for n := 0; n+1 < len(ends); n++ {
+		a, b := ends[n], ends[n+1]
+		fa, fb := c.f.at(a), c.f.at(b)
+		if n == 0 && (fresh || math.Abs(fa) <= c.tol) {
+			if fb > c.tol {
+				return a, true
+			}
+			continue
+		}
+		if fa > c.tol {
+			return a, true
+		}
+		if fb > c.tol {
+			if fa > 0 {
+				return a, true
+			}
+			return bisect(c.f, a, b), true
+		}
+	}
+	return 0, false
+}
+
+// collision returns the time from t0 until the end of the step, at which two
+// sites meet.
+func (k *Kinetic) collision(i, j int, t0 float64) (float64, bool) {
+	x, y := k.relative(i, j)
+	speed := x[1]*x[1] + y[1]*y[1]
+	if speed == 0 {
+		return 0, false
+	}
+	t := math.Max(t0, math.Min(k.length, -(x[0]*x[1]+y[0]*y[1])/speed))
+	if !samePosition(k.at(i, t), k.at(j, t)) {
+		return 0, false
+	}
+	return t, true
+}
+
+// schedule computes the certificate of an edge from time t0, and queues its
+// failure and the collision of its sites, if they happen during the step.
+func (k *Kinetic) schedule(edge [2]int, t0 float64, fresh bool) {
+	k.stamp++
+	k.versions[edge] = k.stamp
+	p, q := edge[0], edge[1]
+	r, s := k.tri.third[[2]int{p, q}], k.tri.third[[2]int{q, p}]
+	if t, ok := k.failure(k.certificate(p, q, r, s), t0, fresh); ok {
+		heap.Push(&k.queue, certificateEvent{t, edge, k.stamp, false})
+	}
+	if p != infinite {
+		if t, ok := k.collision(p, q, t0); ok {
+			heap.Push(&k.queue, certificateEvent{t, edge, k.stamp, true})
+		}
+	}
+}
+
+// scheduleAll schedules the edges, whose certificates change during the
+// step, from time t0.
+func (k *Kinetic) scheduleAll(t0 float64) {
+	k.versions = make(map[[2]int]int)
+	moving := func(i int) bool {
+		return i != infinite && k.motion[i] != Point{}
+	}
+	for e, r := range k.tri.third {
+		if e[0] > e[1] {
+			continue
+		}
+		s := k.tri.third[[2]int{e[1], e[0]}]
+		if moving(e[0]) || moving(e[1]) || moving(r) || moving(s) {
+			k.schedule(e, t0, false)
+		}
+	}
+}
+
+// certificateEvent is the failure of the certificate of an edge, or the
+// collision of its sites. Events of edges certified again are outdated.
+type certificateEvent struct {
+	time      float64
+	edge      [2]int
+	version   int
+	collision bool
+}
+
+// certificateQueue is a priority queue of certificate events by their time.
+type certificateQueue []certificateEvent
+
+func (q certificateQueue) Len() int { return len(q) }
+
+func (q certificateQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time < q[j].time
+	}
+	return q[i].collision && !q[j].collision
+}
+
+func (q certificateQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *certificateQueue) Push(x interface{}) { *q = append(*q, x.(certificateEvent)) }
+
+func (q *certificateQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+// poly is a polynomial, with the coefficients ordered from the constant term.
+type poly []float64
+
+// at evaluates the polynomial at t.
+func (f poly) at(t float64) float64 {
+	var v float64
+	for i := len(f) - 1; i >= 0; i-- {
+		v = v*t + f[i]
+	}
+	return v
+}
+
+func (f poly) add(g poly) poly {
+	if len(f) < len(g) {
+		f, g = g, f
+	}
+	h := append(poly(nil), f...)
+	for i, c := range g {
+		h[i] += c
+	}
+	return h
+}
+
+func (f poly) sub(g poly) poly {
+	h := make(poly, len(g))
+	for i, c := range g {
+		h[i] = -c
+	}
+	return f.add(h)
+}
+
+func (f poly) mul(g poly) poly {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+	h := make(poly, len(f)+len(g)-1)
+	for i, a := range f {
+		for j, b := range g {
+			h[i+j] += a * b
+		}
+	}
+	return h
+}
+
+func (f poly) derivative() poly {
+	if len(f) < 2 {
+		return nil
+	}
+	d := make(poly, len(f)-1)
+	for i := range d {
+		d[i] = float64(i+1) * f[i+1]
+	}
+	return d
+}
+
+// polyRoots returns the roots of a polynomial between a and b in ascending
+// order. The polynomial is monotonic between the roots of its derivative,
+// where each root is found by bisection.
+func polyRoots(f poly, a, b float64) []float64 {
+	for len(f) > 0 && f[len(f)-1] == 0 {
+		f = f[:len(f)-1]
+	}
+	if len(f) < 2 || a >= b {
+		return nil
+	}
+	if len(f) == 2 {
+		if t := -f[0] / f[1]; t > a && t < b {
+			return []float64{t}
+		}
+		return nil
+	}
+
+	ends := append([]float64{a}, polyRoots(f.derivative(), a, b)...)
+	ends = append(ends, b)
+	var roots []float64
+	for n := 0; n+1 < len(ends); n++ {
+		lo, hi := ends[n], ends[n+1]
+		flo, fhi := f.at(lo), f.at(hi)
+		switch {
+		case flo == 0:
+			if n > 0 {
+				roots = append(roots, lo)
+			}
+		case fhi != 0 && (flo < 0) != (fhi < 0):
+			g := f
+			if flo > 0 {
+				g = poly(nil).sub(f)
+			}
+			roots = append(roots, bisect(g, lo, hi))
+		}
+	}
+	return roots
+}
+
+// bisect returns the time, at which a polynomial becomes positive between a
+// and b, where it is positive at b but not at a.
+func bisect(f poly, a, b float64) float64 {
+	for n := 0; n < 200; n++ {
+		m := a + (b-a)/2
+		if m <= a || m >= b {
+			break
+		}
+		if f.at(m) > 0 {
+			b = m
+		} else {
+			a = m
+		}
+	}
+	return b
+}
@@ -0,0 +1,246 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestKinetic(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+	for seed := int64(1); seed <= 3; seed++ {
+		v := New(randomSites(20, seed, bounds), bounds)
+		v.Generate()
+		k, err := NewKinetic(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(seed))
+		for i := range v.Sites {
+			if err := k.SetVelocity(v.Sites[i].ID, r.Float64()*40-20, r.Float64()*40-20); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for step := 0; step < 20; step++ {
+			linked := kineticLinks(k)
+			events, err := k.Advance(0.5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Replaying the events on the neighbours before the step gives
+			// the neighbours after it.
+			last := 0.0
+			for _, ev := range events {
+				if ev.Time < last || ev.Time > 0.5 || ev.A >= ev.B {
+					t.Fatalf("seed %d, step %d: event %+v out of order", seed, step, ev)
+				}
+				last = ev.Time
+				pair := [2]int64{ev.A, ev.B}
+				if !ev.Collided && linked[pair] == ev.Joined {
+					t.Fatalf("seed %d, step %d: event %+v does not change the neighbours", seed, step, ev)
+				}
+				if !ev.Collided {
+					linked[pair] = ev.Joined
+				}
+			}
+			after := kineticLinks(k)
+			for pair, joined := range linked {
+				if joined != after[pair] {
+					t.Fatalf("seed %d, step %d: events do not lead to the neighbours of sites %v", seed, step, pair)
+				}
+			}
+
+			checkKinetic(t, k)
+			if area := cellsArea(v.Cells()); math.Abs(area-60000) > 1e-6 {
+				t.Fatalf("seed %d, step %d: cells cover %v, want 60000", seed, step, area)
+			}
+		}
+	}
+}
+
+// Sites moving onto one integer location are no error, and keep cells of
+// their own, while sites meeting exactly share a cell until they separate.
+func TestKineticCollision(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	v := New(SiteSlice{{X: 10, Y: 40, ID: 1}, {X: 90, Y: 60, ID: 2}, {X: 50, Y: 10, ID: 3}, {X: 45, Y: 85, ID: 4}}, bounds)
+	v.Generate()
+	k, err := NewKinetic(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.Move(map[int64]Point{1: {40.2, 50}, 2: {39.8, 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if v.Sites[k.index[1]].X != 40 || v.Sites[k.index[2]].X != 40 || len(v.Cells()) != 4 {
+		t.Fatalf("sites at one integer location have %d cells, want 4", len(v.Cells()))
+	}
+	checkKinetic(t, k)
+
+	events, err := k.Move(map[int64]Point{1: {60, 50}, 2: {60, 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collided := false
+	for _, ev := range events {
+		collided = collided || ev.Collided && ev.A == 1 && ev.B == 2 && ev.Time == 1
+	}
+	if !collided || len(v.Cells()) != 3 {
+		t.Fatalf("sites meeting at one location are not reported as collided: %+v", events)
+	}
+	if v.Sites[k.index[2]].Face != nil {
+		t.Error("the later of the collided sites has a cell of its own")
+	}
+	checkKinetic(t, k)
+
+	// Sites passing through each other collide on the way.
+	k.SetVelocity(1, -10, 0)
+	k.SetVelocity(2, 10, 0)
+	if _, err := k.Advance(1); err != nil {
+		t.Fatal(err)
+	}
+	k.SetVelocity(1, 10, 0)
+	k.SetVelocity(2, -10, 0)
+	events, err = k.Advance(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collided = false
+	for _, ev := range events {
+		collided = collided || ev.Collided && ev.Time == 1
+	}
+	if !collided || len(v.Cells()) != 4 {
+		t.Fatalf("sites passing through each other are not reported as collided: %+v", events)
+	}
+	checkKinetic(t, k)
+	if area := cellsArea(v.Cells()); math.Abs(area-10000) > 1e-6 {
+		t.Errorf("cells cover %v, want 10000", area)
+	}
+}
+
+func TestKineticCollinear(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	v := New(SiteSlice{{X: 10, Y: 10, ID: 1}, {X: 20, Y: 20, ID: 2}, {X: 30, Y: 30, ID: 3}}, bounds)
+	v.Generate()
+	k, err := NewKinetic(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []Point{{40, 40}, {40, 60}, {90, 90}, {-500, 20}} {
+		if _, err := k.Move(map[int64]Point{1: to}); err != nil {
+			t.Fatal(err)
+		}
+		checkKinetic(t, k)
+		if area := cellsArea(v.Cells()); math.Abs(area-10000) > 1e-6 {
+			t.Errorf("after moving to %v, cells cover %v, want 10000", to, area)
+		}
+	}
+
+	one := New(SiteSlice{{X: 10, Y: 10, ID: 1}}, bounds)
+	one.Generate()
+	k, err = NewKinetic(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events, err := k.Move(map[int64]Point{1: {60, 60}}); err != nil || len(events) != 0 {
+		t.Fatalf("got events %v and error %v", events, err)
+	}
+	if cells := one.Cells(); len(cells) != 1 || math.Abs(cellsArea(cells)-10000) > 1e-6 {
+		t.Error("single site does not cover the bounds")
+	}
+}
+
+func TestKineticErrors(t *testing.T) {
+	v := New(SiteSlice{{X: 10, Y: 10, ID: 1}, {X: 60, Y: 40, ID: 2}, {X: 30, Y: 80, ID: 3}}, image.Rect(0, 0, 100, 100))
+	v.Generate()
+	k, err := NewKinetic(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetVelocity(4, 1, 1); err == nil {
+		t.Error("velocity of an unknown site set")
+	}
+	if _, err := k.Move(map[int64]Point{4: {5, 5}}); err == nil {
+		t.Error("unknown site moved")
+	}
+	if _, err := k.Advance(-1); err == nil {
+		t.Error("negative time step accepted")
+	}
+	if _, err := v.Insert(Site{X: 50, Y: 50, ID: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Advance(1); err == nil {
+		t.Error("sites moved after a site was inserted")
+	}
+}
+
+// kineticLinks returns the pairs of neighbouring sites of a kinetic diagram.
+func kineticLinks(k *Kinetic) map[[2]int64]bool {
+	links := make(map[[2]int64]bool)
+	for a, neighbors := range k.editor.neighbors {
+		for b := range neighbors {
+			if a < b {
+				links[[2]int64{a, b}] = true
+			}
+		}
+	}
+	return links
+}
+
+// checkKinetic compares the cells and neighbours of a kinetic diagram with
+// the cells of the exact positions of its sites, computed from scratch.
+// Sites sharing the cell of another one are left out.
+func checkKinetic(t *testing.T, k *Kinetic) {
+	t.Helper()
+	var ids []int64
+	var positions []Point
+	for i, id := range k.ids {
+		if _, ok := k.hidden[i]; !ok {
+			ids = append(ids, id)
+			positions = append(positions, k.positions[i])
+		}
+	}
+	// Neighbours share an edge of the cells without bounds. Sites on one
+	// circle may be linked through a vertex only.
+	far := []Point{{-1e6, -1e6}, {1e6, -1e6}, {1e6, 1e6}, {-1e6, 1e6}}
+	rect := rectPolygon(k.Voronoi.Bounds)
+	links := kineticLinks(k)
+	for n, id := range ids {
+		want := Euclidean.metricCell(positions, n, rect)
+		if got := k.editor.polygons[id]; math.Abs(signedArea(got)-signedArea(want)) > 1e-6 {
+			t.Fatalf("cell of site %d has area %v, want %v", id, signedArea(got), signedArea(want))
+		}
+		cell := Euclidean.metricCell(positions, n, far)
+		for m, other := range ids {
+			if other <= id {
+				continue
+			}
+			length, touch := sharedEdge(cell, positions[n], positions[m])
+			if pair := [2]int64{id, other}; length > 1e-6 && !links[pair] || links[pair] && !touch {
+				t.Fatalf("sites %d and %d are linked: %v, but share an edge of length %v", id, other, links[pair], length)
+			}
+		}
+	}
+}
+
+// sharedEdge returns the length of the edge of a cell on the bisector of two
+// sites, and reports if the cell has a vertex on the bisector.
+func sharedEdge(cell []Point, a, b Point) (float64, bool) {
+	l := dist(a, b)
+	on := func(p Point) bool {
+		d := ((p.X-(a.X+b.X)/2)*(b.X-a.X) + (p.Y-(a.Y+b.Y)/2)*(b.Y-a.Y)) / l
+		return math.Abs(d) <= 1e-9*(1+math.Abs(p.X)+math.Abs(p.Y))
+	}
+	var length float64
+	touch := false
+	for i, p := range cell {
+		q := cell[(i+1)%len(cell)]
+		touch = touch || on(p)
+		if on(p) && on(q) {
+			length += dist(p, q)
+		}
+	}
+	return length, touch
+}
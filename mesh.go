@@ -0,0 +1,275 @@
+package voronoi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// MeshOptions configures the export of a diagram as a polygon mesh.
+type MeshOptions struct {
+	// Colors adds the colour of each cell, from the palette used by Plotter.
+	// PLY files store it as properties of the faces, while OBJ files refer
+	// to the materials written by WritePaletteMTL.
+	Colors bool
+	// MaterialLib is the name of the material library referred to by OBJ
+	// files with colours. Defaults to "palette.mtl".
+	MaterialLib string
+	// Height returns the height, to which the cell of a site is extruded,
+	// making a prism with a bottom face, a top face and a side face for each
+	// edge. Negative heights extrude the cell below the plane. Cells are
+	// flat polygons at zero height when nil, or when the height is zero.
+	// Cells of segments are always flat.
+	Height func(site *Site) float64
+	// Binary writes PLY files in the binary little-endian format, instead of
+	// ASCII. Ignored by OBJ.
+	Binary bool
+}
+
+// mesh is a polygon mesh with shared vertices.
+type mesh struct {
+	vertices [][3]float64
+	faces    [][]int
+	colors   []int // palette index of each face
+	index    map[[3]float64]int
+}
+
+func (m *mesh) vertex(p Point, z float64) int {
+	key := [3]float64{p.X, p.Y, z}
+	if i, ok := m.index[key]; ok {
+		return i
+	}
+	m.index[key] = len(m.vertices)
+	m.vertices = append(m.vertices, key)
+	return len(m.vertices) - 1
+}
+
+func (m *mesh) face(color int, indices ...int) {
+	m.faces = append(m.faces, indices)
+	m.colors = append(m.colors, color)
+}
+
+// mesh builds the mesh of the cells, as returned by Cells. Vertices shared
+// by several cells in the DCEL are shared in the mesh too. Faces are ordered
+// counter-clockwise, as seen from the outside of the extruded cells.
+func (v *Voronoi) mesh(opts MeshOptions) *mesh {
+	m := &mesh{index: make(map[[3]float64]int)}
+	order := v.cellOrder()
+	cells := v.Cells()
+	for i := range cells {
+		cell := &cells[i]
+		color := order(cell) % len(colors)
+		var height float64
+		if opts.Height != nil && cell.Site != nil {
+			height = opts.Height(cell.Site)
+		}
+
+		// Faces have a single boundary, so cells with holes are capped with
+		// triangles instead.
+		caps := [][]Point{cell.Polygon}
+		if len(cell.Holes) > 0 {
+			ring := cell.Polygon
+			for _, hole := range cell.Holes {
+				ring = bridgeHole(ring, hole, cell.Holes)
+			}
+			caps = nil
+			for _, t := range triangulate(ring) {
+				caps = append(caps, []Point{ring[t[0]], ring[t[1]], ring[t[2]]})
+			}
+		}
+
+		// Prisms span from the lower to the upper of zero and the height.
+		lo, hi := math.Min(0, height), math.Max(0, height)
+		for _, poly := range caps {
+			top := make([]int, len(poly))
+			for i, p := range poly {
+				top[i] = m.vertex(p, hi)
+			}
+			m.face(color, top...)
+		}
+		if height == 0 {
+			continue
+		}
+
+		for _, poly := range caps {
+			n := len(poly)
+			reversed := make([]int, n)
+			for i, p := range poly {
+				reversed[n-1-i] = m.vertex(p, lo)
+			}
+			m.face(color, reversed...)
+		}
+		// Holes are clockwise, so their sides face into the holes.
+		for _, ring := range append([][]Point{cell.Polygon}, cell.Holes...) {
+			n := len(ring)
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%n]
+				m.face(color, m.vertex(a, lo), m.vertex(b, lo), m.vertex(b, hi), m.vertex(a, hi))
+			}
+		}
+	}
+	return m
+}
+
+// triangulate splits a counter-clockwise polygon into triangles by cutting
+// off ears, returning the indices of their vertices. Vertices may repeat, as
+// in polygons whose holes are bridged to the outer boundary.
+func triangulate(poly []Point) [][3]int {
+	remaining := make([]int, len(poly))
+	for i := range remaining {
+		remaining[i] = i
+	}
+	var triangles [][3]int
+	for len(remaining) > 3 {
+		n := len(remaining)
+		found := false
+		for k := 0; k < n && !found; k++ {
+			ia, ib, ic := remaining[(k+n-1)%n], remaining[k], remaining[(k+1)%n]
+			a, b, c := poly[ia], poly[ib], poly[ic]
+			if cross(a, b, c) <= 0 {
+				continue
+			}
+			ear := true
+			for _, j := range remaining {
+				p := poly[j]
+				if p == a || p == b || p == c {
+					continue
+				}
+				if cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0 {
+					ear = false
+					break
+				}
+			}
+			if ear {
+				triangles = append(triangles, [3]int{ia, ib, ic})
+				remaining = append(remaining[:k], remaining[k+1:]...)
+				found = true
+			}
+		}
+		if !found {
+			// Drop a vertex in the middle of a straight edge, or give up if
+			// there is none, which only happens for degenerate polygons.
+			for k := 0; k < n && !found; k++ {
+				a, b, c := poly[remaining[(k+n-1)%n]], poly[remaining[k]], poly[remaining[(k+1)%n]]
+				if cross(a, b, c) == 0 {
+					remaining = append(remaining[:k], remaining[k+1:]...)
+					found = true
+				}
+			}
+			if !found {
+				break
+			}
+		}
+	}
+	if len(remaining) == 3 && cross(poly[remaining[0]], poly[remaining[1]], poly[remaining[2]]) > 0 {
+		triangles = append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+	}
+	return triangles
+}
+
+// WriteOBJ writes the cells of the diagram as a Wavefront OBJ mesh, with a
+// polygon face for each cell, or a prism for each extruded cell.
+func (v *Voronoi) WriteOBJ(w io.Writer, opts MeshOptions) error {
+	m := v.mesh(opts)
+	bw := bufio.NewWriter(w)
+	num := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+
+	if opts.Colors {
+		lib := opts.MaterialLib
+		if lib == "" {
+			lib = "palette.mtl"
+		}
+		fmt.Fprintf(bw, "mtllib %s\n", lib)
+	}
+	for _, p := range m.vertices {
+		fmt.Fprintf(bw, "v %s %s %s\n", num(p[0]), num(p[1]), num(p[2]))
+	}
+	material := -1
+	for i, face := range m.faces {
+		if opts.Colors && m.colors[i] != material {
+			material = m.colors[i]
+			fmt.Fprintf(bw, "usemtl color%d\n", material)
+		}
+		bw.WriteString("f")
+		for _, k := range face {
+			fmt.Fprintf(bw, " %d", k+1)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// WritePaletteMTL writes the colours of the palette used by Plotter as a
+// Wavefront material library, for OBJ files written with colours.
+func WritePaletteMTL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range colors {
+		r, g, b := rgb(c)
+		fmt.Fprintf(bw, "newmtl color%d\nKd %.4f %.4f %.4f\n\n", i, float64(r)/255, float64(g)/255, float64(b)/255)
+	}
+	return bw.Flush()
+}
+
+// WritePLY writes the cells of the diagram as a PLY mesh, in the ASCII or the
+// binary format, with a polygon face for each cell, or a prism for each
+// extruded cell. The vertex counts of faces are written as uint, since
+// clipped cells can have more vertices than a uchar holds.
+func (v *Voronoi) WritePLY(w io.Writer, opts MeshOptions) error {
+	m := v.mesh(opts)
+	bw := bufio.NewWriter(w)
+	format := "ascii"
+	if opts.Binary {
+		format = "binary_little_endian"
+	}
+	fmt.Fprintf(bw, "ply\nformat %s 1.0\n", format)
+	fmt.Fprintf(bw, "element vertex %d\nproperty double x\nproperty double y\nproperty double z\n", len(m.vertices))
+	fmt.Fprintf(bw, "element face %d\nproperty list uint int vertex_indices\n", len(m.faces))
+	if opts.Colors {
+		bw.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	}
+	bw.WriteString("end_header\n")
+
+	if opts.Binary {
+		for _, p := range m.vertices {
+			binary.Write(bw, binary.LittleEndian, p)
+		}
+		for i, face := range m.faces {
+			binary.Write(bw, binary.LittleEndian, uint32(len(face)))
+			for _, k := range face {
+				binary.Write(bw, binary.LittleEndian, int32(k))
+			}
+			if opts.Colors {
+				r, g, b := rgb(colors[m.colors[i]])
+				bw.Write([]byte{r, g, b})
+			}
+		}
+		return bw.Flush()
+	}
+
+	num := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	for _, p := range m.vertices {
+		fmt.Fprintf(bw, "%s %s %s\n", num(p[0]), num(p[1]), num(p[2]))
+	}
+	for i, face := range m.faces {
+		fmt.Fprintf(bw, "%d", len(face))
+		for _, k := range face {
+			fmt.Fprintf(bw, " %d", k)
+		}
+		if opts.Colors {
+			r, g, b := rgb(colors[m.colors[i]])
+			fmt.Fprintf(bw, " %d %d %d", r, g, b)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// rgb returns the 8-bit red, green and blue components of a colour.
+func rgb(c color.Color) (r, g, b uint8) {
+	cr, cg, cb, _ := c.RGBA()
+	return uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8)
+}
@@ -0,0 +1,311 @@
+package voronoi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testMesh is a mesh decoded from a PLY or OBJ file.
+type testMesh struct {
+	vertices [][3]float64
+	faces    [][]int
+	colors   [][3]uint8 // colour of each face in PLY files
+	mtllib   string     // material library of OBJ files
+	usemtl   []string   // material of each face in OBJ files
+}
+
+// readPLY decodes a PLY file in the layout written by WritePLY.
+func readPLY(data []byte) (*testMesh, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	var vertices, faces int
+	binaryFormat, colored := false, false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "end_header" {
+			break
+		}
+		fmt.Sscanf(line, "element vertex %d", &vertices)
+		fmt.Sscanf(line, "element face %d", &faces)
+		binaryFormat = binaryFormat || line == "format binary_little_endian 1.0"
+		colored = colored || line == "property uchar red"
+	}
+
+	m := &testMesh{vertices: make([][3]float64, vertices), faces: make([][]int, faces)}
+	if colored {
+		m.colors = make([][3]uint8, faces)
+	}
+	if binaryFormat {
+		for i := range m.vertices {
+			if err := binary.Read(r, binary.LittleEndian, &m.vertices[i]); err != nil {
+				return nil, err
+			}
+		}
+		for i := range m.faces {
+			var n uint32
+			if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+				return nil, err
+			}
+			indices := make([]int32, n)
+			if err := binary.Read(r, binary.LittleEndian, indices); err != nil {
+				return nil, err
+			}
+			for _, k := range indices {
+				m.faces[i] = append(m.faces[i], int(k))
+			}
+			if colored {
+				if err := binary.Read(r, binary.LittleEndian, &m.colors[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else {
+		for i := range m.vertices {
+			if _, err := fmt.Fscan(r, &m.vertices[i][0], &m.vertices[i][1], &m.vertices[i][2]); err != nil {
+				return nil, err
+			}
+		}
+		for i := range m.faces {
+			var n int
+			if _, err := fmt.Fscan(r, &n); err != nil {
+				return nil, err
+			}
+			m.faces[i] = make([]int, n)
+			for k := range m.faces[i] {
+				if _, err := fmt.Fscan(r, &m.faces[i][k]); err != nil {
+					return nil, err
+				}
+			}
+			if colored {
+				if _, err := fmt.Fscan(r, &m.colors[i][0], &m.colors[i][1], &m.colors[i][2]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		fmt.Fscanln(r)
+	}
+	if r.Buffered() > 0 {
+		return nil, fmt.Errorf("%d bytes after the faces", r.Buffered())
+	}
+	return m, nil
+}
+
+// readOBJ decodes an OBJ file in the layout written by WriteOBJ.
+func readOBJ(data []byte) (*testMesh, error) {
+	m := &testMesh{}
+	material := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "mtllib":
+			m.mtllib = fields[1]
+		case "usemtl":
+			material = fields[1]
+		case "v":
+			var p [3]float64
+			if _, err := fmt.Sscan(strings.Join(fields[1:], " "), &p[0], &p[1], &p[2]); err != nil {
+				return nil, err
+			}
+			m.vertices = append(m.vertices, p)
+		case "f":
+			var face []int
+			for _, f := range fields[1:] {
+				var k int
+				if _, err := fmt.Sscan(f, &k); err != nil {
+					return nil, err
+				}
+				face = append(face, k-1)
+			}
+			m.faces = append(m.faces, face)
+			m.usemtl = append(m.usemtl, material)
+		default:
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+	return m, nil
+}
+
+// area returns the area of the faces, projected onto the plane.
+func (m *testMesh) area() float64 {
+	var area float64
+	for _, face := range m.faces {
+		for k, i := range face {
+			a, b := m.vertices[i], m.vertices[face[(k+1)%len(face)]]
+			area += (a[0]*b[1] - b[0]*a[1]) / 2
+		}
+	}
+	return area
+}
+
+// volume returns the volume enclosed by the faces, by the divergence theorem.
+func (m *testMesh) volume() float64 {
+	var volume float64
+	for _, face := range m.faces {
+		for k := 1; k+1 < len(face); k++ {
+			a, b, c := m.vertices[face[0]], m.vertices[face[k]], m.vertices[face[k+1]]
+			volume += (a[0]*(b[1]*c[2]-b[2]*c[1]) - a[1]*(b[0]*c[2]-b[2]*c[0]) + a[2]*(b[0]*c[1]-b[1]*c[0])) / 6
+		}
+	}
+	return volume
+}
+
+// meshFormats writes a diagram as ASCII PLY, binary PLY and OBJ, and decodes
+// the meshes.
+func meshFormats(t *testing.T, v *Voronoi, opts MeshOptions) map[string]*testMesh {
+	t.Helper()
+	meshes := make(map[string]*testMesh)
+	for _, format := range []string{"PLY", "binary PLY", "OBJ"} {
+		var buf bytes.Buffer
+		var err error
+		var m *testMesh
+		if format == "OBJ" {
+			if err = v.WriteOBJ(&buf, opts); err == nil {
+				m, err = readOBJ(buf.Bytes())
+			}
+		} else {
+			opts.Binary = format == "binary PLY"
+			if err = v.WritePLY(&buf, opts); err == nil {
+				m, err = readPLY(buf.Bytes())
+			}
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		meshes[format] = m
+	}
+	return meshes
+}
+
+func TestMesh(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+	v := New(randomSites(30, 3, bounds), bounds)
+	v.Generate()
+	cells := v.Cells()
+	for format, m := range meshFormats(t, v, MeshOptions{Colors: true}) {
+		// Each cell is one counter-clockwise face, and neighbouring cells
+		// share their vertices, so each edge is used once in each direction.
+		if len(m.faces) != len(cells) {
+			t.Fatalf("%s: got %d faces for %d cells", format, len(m.faces), len(cells))
+		}
+		if area := m.area(); math.Abs(area-60000) > 1e-6 {
+			t.Errorf("%s: faces cover %v, want 60000", format, area)
+		}
+		edges := make(map[[2]int]int)
+		for _, face := range m.faces {
+			for k, i := range face {
+				edges[[2]int{i, face[(k+1)%len(face)]}]++
+			}
+		}
+		shared := 0
+		for e, n := range edges {
+			if n != 1 {
+				t.Fatalf("%s: edge %v is used %d times in one direction", format, e, n)
+			}
+			if edges[[2]int{e[1], e[0]}] == 1 {
+				shared++
+			}
+		}
+		if shared == 0 {
+			t.Errorf("%s: cells share no edges", format)
+		}
+
+		for i, cell := range cells {
+			c := colors[i%len(colors)]
+			if m.colors != nil {
+				r, g, b := rgb(c)
+				if m.colors[i] != [3]uint8{r, g, b} {
+					t.Errorf("%s: face %d has colour %v, want %v", format, i, m.colors[i], c)
+				}
+			}
+			if m.usemtl != nil && m.usemtl[i] != fmt.Sprint("color", i%len(colors)) {
+				t.Errorf("%s: face of site %d has material %s", format, cell.Site.ID, m.usemtl[i])
+			}
+		}
+		if format == "OBJ" && m.mtllib != "palette.mtl" {
+			t.Errorf("got material library %q", m.mtllib)
+		}
+	}
+
+	var mtl bytes.Buffer
+	if err := WritePaletteMTL(&mtl); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(mtl.String(), "newmtl color"); n != len(colors) {
+		t.Errorf("material library has %d materials, want %d", n, len(colors))
+	}
+}
+
+// Extruded cells are closed prisms, holding the area of the cell times its
+// height, below the plane for negative heights.
+func TestMeshExtruded(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+	v := New(randomSites(30, 4, bounds), bounds)
+	v.Clip = NewClipPolygon(rectPolygon(bounds), []Point{{100, 50}, {100, 150}, {200, 150}, {200, 50}})
+	v.Generate()
+	for _, sign := range []float64{1, -1} {
+		height := func(site *Site) float64 { return sign * float64(site.ID%5+1) }
+		var want float64
+		for _, cell := range v.Cells() {
+			area := signedArea(cell.Polygon)
+			for _, hole := range cell.Holes {
+				area += signedArea(hole)
+			}
+			want += area * math.Abs(height(cell.Site))
+		}
+		for format, m := range meshFormats(t, v, MeshOptions{Height: height}) {
+			if got := m.volume(); math.Abs(got-want) > 1e-6*want {
+				t.Errorf("%s, sign %v: prisms hold %v, want %v", format, sign, got, want)
+			}
+			for _, p := range m.vertices {
+				if p[2]*sign < 0 {
+					t.Fatalf("%s, sign %v: vertex %v lies on the other side of the plane", format, sign, p)
+				}
+			}
+		}
+	}
+}
+
+// Clipped cells can have more vertices than the uchar vertex count of most
+// PLY files holds.
+func TestPLYLargeFace(t *testing.T) {
+	var circle []Point
+	for i := 0; i < 400; i++ {
+		a := 2 * math.Pi * float64(i) / 400
+		circle = append(circle, Point{500 + 400*math.Cos(a), 500 + 400*math.Sin(a)})
+	}
+	v := New(SiteSlice{{X: 500, Y: 500, ID: 1}}, image.Rect(0, 0, 1000, 1000))
+	v.Clip = NewClipPolygon(circle)
+	v.Generate()
+	for format, m := range meshFormats(t, v, MeshOptions{}) {
+		if len(m.faces) != 1 || len(m.faces[0]) < 300 {
+			t.Errorf("%s: got %d faces, want one with at least 300 vertices", format, len(m.faces))
+		}
+	}
+}
+
+func TestTriangulate(t *testing.T) {
+	// A square with a bridged square hole, whose bridge repeats vertices.
+	outer := []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	hole := []Point{{3, 3}, {3, 6}, {6, 6}, {6, 3}}
+	ring := bridgeHole(outer, hole, [][]Point{hole})
+	var area float64
+	for _, tri := range triangulate(ring) {
+		a, b, c := ring[tri[0]], ring[tri[1]], ring[tri[2]]
+		if cross(a, b, c) <= 0 {
+			t.Fatalf("triangle %v %v %v is not counter-clockwise", a, b, c)
+		}
+		area += cross(a, b, c) / 2
+	}
+	if math.Abs(area-91) > 1e-9 {
+		t.Errorf("triangles cover %v, want 91", area)
+	}
+}
@@ -0,0 +1,304 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Metric is the distance function used to decide which site is nearest
+// to a point of the plane.
+type Metric int
+
+const (
+	// Euclidean is the ordinary straight line (L2) distance. Diagrams with
+	// this metric are generated with Fortune's algorithm.
+	Euclidean Metric = 0
+	// Manhattan is the taxicab (L1) distance: |dx| + |dy|.
+	Manhattan Metric = 1
+	// Chebyshev is the chessboard (L∞) distance: max(|dx|, |dy|).
+	Chebyshev Metric = 2
+)
+
+// String returns the name of the metric.
+func (m Metric) String() string {
+	switch m {
+	case Euclidean:
+		return "euclidean"
+	case Manhattan:
+		return "manhattan"
+	case Chebyshev:
+		return "chebyshev"
+	}
+	return "unknown"
+}
+
+// Distance returns the distance between two points in this metric.
+func (m Metric) Distance(a, b Point) float64 {
+	dx, dy := math.Abs(b.X-a.X), math.Abs(b.Y-a.Y)
+	switch m {
+	case Manhattan:
+		return dx + dy
+	case Chebyshev:
+		return math.Max(dx, dy)
+	}
+	return math.Hypot(dx, dy)
+}
+
+// closer reports if p is at least as close to a as it is to b.
+// The L1 and L∞ bisectors of some pairs of sites contain whole regions of
+// points that are equally distant from both sites. Such ties are broken by
+// the euclidean distance, so that every bisector is a simple polyline.
+func (m Metric) closer(p, a, b Point) bool {
+	da, db := m.Distance(p, a), m.Distance(p, b)
+	if math.Abs(da-db) > 1e-9*(1+da+db) {
+		return da < db
+	}
+	return Euclidean.Distance(p, a) <= Euclidean.Distance(p, b)
+}
+
+// bisector returns the polyline separating the points closer to a from
+// those closer to b. The polyline has four points - the two ends of the
+// middle segment, extended by rays of the given length on both sides.
+func (m Metric) bisector(a, b Point, length float64) []Point {
+	switch m {
+	case Chebyshev:
+		return chebyshevBisector(a, b, length)
+	case Manhattan:
+		// The L1 distance is the L∞ distance after rotating the plane
+		// by 45 degrees: |x| + |y| = max(|x + y|, |x - y|).
+		rotate := func(p Point) Point { return Point{p.X + p.Y, p.X - p.Y} }
+		bis := chebyshevBisector(rotate(a), rotate(b), 2*length)
+		for i, p := range bis {
+			bis[i] = Point{(p.X + p.Y) / 2, (p.X - p.Y) / 2}
+		}
+		return bis
+	}
+
+	mid := lerp(a, b, 0.5)
+	d := dist(a, b)
+	nx, ny := -(b.Y-a.Y)/d, (b.X-a.X)/d
+	return []Point{
+		{mid.X - nx*length, mid.Y - ny*length},
+		mid,
+		mid,
+		{mid.X + nx*length, mid.Y + ny*length},
+	}
+}
+
+// chebyshevBisector returns the L∞ bisector of two points. The middle
+// segment is vertical (or horizontal) and the rays run at 45 degrees.
+// When the points lie on the same horizontal or vertical line, the rays
+// are axis-aligned instead, which splits the equidistant regions in half.
+func chebyshevBisector(a, b Point, length float64) []Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	mid := lerp(a, b, 0.5)
+	s := sign(dx) * sign(dy)
+
+	if math.Abs(dx) >= math.Abs(dy) {
+		h := (math.Abs(dx) - math.Abs(dy)) / 2
+		top := Point{mid.X, mid.Y + h}
+		bottom := Point{mid.X, mid.Y - h}
+		return []Point{
+			{top.X - s*length, top.Y + length},
+			top,
+			bottom,
+			{bottom.X + s*length, bottom.Y - length},
+		}
+	}
+
+	h := (math.Abs(dy) - math.Abs(dx)) / 2
+	left := Point{mid.X - h, mid.Y}
+	right := Point{mid.X + h, mid.Y}
+	return []Point{
+		{left.X - length, left.Y + s*length},
+		left,
+		right,
+		{right.X + length, right.Y - s*length},
+	}
+}
+
+func sign(x float64) float64 {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+// clipByBisector returns the part of a polygon, which is closer to site a
+// than to site b. Both the polygon and the result must be star-shaped with
+// respect to a, which is true for all voronoi cells of a normed metric.
+func (m Metric) clipByBisector(poly []Point, a, b Point, bis []Point) []Point {
+	const eps = 1e-9
+
+	// Split edges of the polygon where they cross the bisector,
+	// so that each piece lies on just one side of it.
+	type piece struct {
+		from, to Point
+		inside   bool
+	}
+	var pieces []piece
+	for i := range poly {
+		p, q := poly[i], poly[(i+1)%len(poly)]
+		cuts := []float64{0, 1}
+		for j := 0; j+1 < len(bis); j++ {
+			if t, _, ok := segmentIntersection(p, q, bis[j], bis[j+1]); ok {
+				cuts = append(cuts, t)
+			}
+		}
+		// Edges overlapping a bisector segment are cut at its bends.
+		for j := 1; j+1 < len(bis); j++ {
+			t := project(p, q, bis[j])
+			if dist(lerp(p, q, t), bis[j]) < eps {
+				cuts = append(cuts, t)
+			}
+		}
+		sort.Float64s(cuts)
+		for j := 0; j+1 < len(cuts); j++ {
+			if cuts[j+1]-cuts[j] < eps {
+				continue
+			}
+			from, to := lerp(p, q, cuts[j]), lerp(p, q, cuts[j+1])
+			inside := m.closer(lerp(from, to, 0.5), a, b)
+			pieces = append(pieces, piece{from, to, inside})
+		}
+	}
+
+	// Find a piece, where the polygon enters the region of a.
+	start := -1
+	allInside := true
+	for i := range pieces {
+		prev := pieces[(i+len(pieces)-1)%len(pieces)]
+		if pieces[i].inside && !prev.inside {
+			start = i
+		}
+		allInside = allInside && pieces[i].inside
+	}
+	if allInside {
+		return poly
+	}
+	if start < 0 {
+		return nil
+	}
+
+	// Walk the pieces inside the region and connect the point where the
+	// polygon leaves the region with the point where it enters it again,
+	// following the bends of the bisector.
+	var result []Point
+	var exit float64
+	for i := 0; i < len(pieces); i++ {
+		cur := pieces[(start+i)%len(pieces)]
+		if !cur.inside {
+			continue
+		}
+		prev := pieces[(start+i+len(pieces)-1)%len(pieces)]
+		if !prev.inside && i > 0 {
+			result = append(result, bendsBetween(bis, exit, polylineParam(bis, cur.from))...)
+		}
+		result = append(result, cur.from)
+		next := pieces[(start+i+1)%len(pieces)]
+		if !next.inside {
+			result = append(result, cur.to)
+			exit = polylineParam(bis, cur.to)
+		}
+	}
+	result = append(result, bendsBetween(bis, exit, polylineParam(bis, pieces[start].from))...)
+
+	return cleanPolygon(result)
+}
+
+// polylineParam returns the position of a point on a polyline, as the index
+// of the segment it lies on plus the parameter along that segment.
+func polylineParam(line []Point, p Point) float64 {
+	best, bestDist := 0.0, math.Inf(1)
+	for i := 0; i+1 < len(line); i++ {
+		t := project(line[i], line[i+1], p)
+		if d := dist(lerp(line[i], line[i+1], t), p); d < bestDist {
+			best, bestDist = float64(i)+t, d
+		}
+	}
+	return best
+}
+
+// bendsBetween returns the inner points of a polyline, which lie between
+// the two positions, ordered from the first position to the second.
+func bendsBetween(line []Point, from, to float64) []Point {
+	const eps = 1e-9
+	var points []Point
+	if from < to {
+		for i := 1; i+1 < len(line); i++ {
+			if float64(i) > from+eps && float64(i) < to-eps {
+				points = append(points, line[i])
+			}
+		}
+	} else {
+		for i := len(line) - 2; i >= 1; i-- {
+			if float64(i) < from-eps && float64(i) > to+eps {
+				points = append(points, line[i])
+			}
+		}
+	}
+	return points
+}
+
+// metricCell computes the cell of the site with the given index by clipping
+// the bounds polygon with the bisectors to all other sites.
+func (m Metric) metricCell(sites []Point, index int, bounds []Point) []Point {
+	a := sites[index]
+	length := 0.0
+	for _, p := range bounds {
+		length = math.Max(length, m.Distance(a, p))
+	}
+	length = 4 * (length + 1)
+
+	cell := bounds
+	for i, b := range sites {
+		if i == index {
+			continue
+		}
+		if b == a {
+			// Of several sites at the same location only the first gets a cell.
+			if i < index {
+				return nil
+			}
+			continue
+		}
+		if m == Euclidean {
+			// Euclidean cells are convex, so clipping by half-planes suffices.
+			cell = clipCloser(cell, a, b)
+		} else {
+			cell = m.clipByBisector(cell, a, b, m.bisector(a, b, length))
+		}
+		if cell == nil {
+			return nil
+		}
+	}
+	return cell
+}
+
+// metricPolygons computes the cells of the sites, which lie within the
+// bounds, by clipping the bounds polygon separately for each site.
+// Takes O(n^2) time.
+func (m Metric) metricPolygons(sites []*Site, bounds image.Rectangle) ([]*Site, [][]Point) {
+	points := make([]Point, len(sites))
+	for i, site := range sites {
+		points[i] = pointOf(site)
+	}
+
+	var owners []*Site
+	var polygons [][]Point
+	for i, site := range sites {
+		if !pointInRect(points[i], bounds) {
+			continue
+		}
+		cell := m.metricCell(points, i, rectPolygon(bounds))
+		if cell == nil {
+			continue
+		}
+		owners = append(owners, site)
+		polygons = append(polygons, cell)
+	}
+	return owners, polygons
+}
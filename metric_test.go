@@ -0,0 +1,85 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMetricDistance(t *testing.T) {
+	a, b := Point{1, 2}, Point{4, -2}
+	for m, want := range map[Metric]float64{Euclidean: 5, Manhattan: 7, Chebyshev: 4} {
+		if got := m.Distance(a, b); got != want {
+			t.Errorf("%v distance is %v, want %v", m, got, want)
+		}
+	}
+}
+
+func TestMetricCells(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 150)
+	for _, m := range []Metric{Euclidean, Manhattan, Chebyshev} {
+		for seed := int64(1); seed <= 3; seed++ {
+			v := NewWithMetric(randomSites(30, seed, bounds), bounds, m)
+			v.Generate()
+			cells := v.Cells()
+			if area := cellsArea(cells); math.Abs(area-30000) > 1e-6*30000 {
+				t.Errorf("%v, seed %d: cells cover %v, want 30000", m, seed, area)
+			}
+
+			// Points inside a cell are nearest to its site.
+			r := rand.New(rand.NewSource(seed))
+			for k := 0; k < 200; k++ {
+				p := Point{r.Float64() * 200, r.Float64() * 150}
+				nearest := math.Inf(1)
+				for i := range v.Sites {
+					nearest = math.Min(nearest, m.Distance(p, pointOf(&v.Sites[i])))
+				}
+				for _, cell := range cells {
+					// Vertices are rounded to integers in the DCEL.
+					if pointInPolygon(p, cell.Polygon) && boundaryDistance(cell.Polygon, p) > 1 {
+						if d := m.Distance(p, pointOf(cell.Site)); d > nearest+1e-9 {
+							t.Fatalf("%v, seed %d: %v lies in the cell of a site at %v, want %v", m, seed, p, d, nearest)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Sites on a coarse grid have bisectors with diagonal and two-dimensional
+// parts in the Manhattan and Chebyshev metrics.
+func TestMetricCellsOnGrid(t *testing.T) {
+	bounds := image.Rect(0, 0, 130, 130)
+	for _, m := range []Metric{Manhattan, Chebyshev} {
+		for seed := int64(1); seed <= 10; seed++ {
+			r := rand.New(rand.NewSource(seed))
+			var sites SiteSlice
+			seen := make(map[image.Point]bool)
+			for len(sites) < 15 {
+				p := image.Point{r.Intn(6)*20 + 10, r.Intn(6)*20 + 10}
+				if !seen[p] {
+					seen[p] = true
+					sites = append(sites, Site{X: p.X, Y: p.Y, ID: int64(len(sites))})
+				}
+			}
+			v := NewWithMetric(sites, bounds, m)
+			v.Generate()
+			if area := cellsArea(v.Cells()); math.Abs(area-130*130) > 1e-6 {
+				t.Errorf("%v, seed %d: cells cover %v, want %v", m, seed, area, 130*130)
+			}
+		}
+	}
+}
+
+// boundaryDistance returns the distance from a point to the boundary of a
+// polygon.
+func boundaryDistance(poly []Point, p Point) float64 {
+	d := math.Inf(1)
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		d = math.Min(d, dist(p, lerp(a, b, project(a, b, p))))
+	}
+	return d
+}
@@ -0,0 +1,174 @@
+package voronoi
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quasoft/dcel"
+)
+
+// KCell is a region of an order-k voronoi diagram - the set of points, which
+// share the same k nearest sites.
+type KCell struct {
+	Sites   []*Site    // The k nearest sites, sorted by ID.
+	Polygon []Point    // Boundary of the region, ordered counter-clockwise.
+	Face    *dcel.Face // DCEL face of the region. Face.Data points back to the KCell.
+}
+
+// IDs returns the IDs of the k nearest sites.
+func (c *KCell) IDs() []int64 {
+	ids := make([]int64, len(c.Sites))
+	for i, site := range c.Sites {
+		ids[i] = site.ID
+	}
+	return ids
+}
+
+// kRegion is a region of the order-k diagram during refinement.
+type kRegion struct {
+	members []int // indices of the k nearest sites, sorted
+	polygon []Point
+}
+
+// OrderK creates the order-k voronoi diagram for a list of sites within the
+// specified bounds. The regions are found by iterative refinement of the
+// order-1 diagram: each region of order k-1 is split by the nearest-point
+// diagram of the sites not yet assigned to it. Pieces of the split regions
+// with the same k nearest sites are merged. Meant for small values of k.
+func OrderK(sites SiteSlice, bounds image.Rectangle, k int) ([]*KCell, *dcel.DCEL, error) {
+	if k < 1 || k > len(sites) {
+		return nil, nil, fmt.Errorf("k must be between 1 and the number of sites (%d), got %d", len(sites), k)
+	}
+
+	// Work with a copy, so that faces of the order-k diagram do not replace
+	// the faces of the sites in the caller's slice.
+	own := make(SiteSlice, len(sites))
+	copy(own, sites)
+	points := make([]Point, len(own))
+	for i := range own {
+		points[i] = pointOf(&own[i])
+	}
+
+	regions := []kRegion{{polygon: rectPolygon(bounds)}}
+	for level := 1; level <= k; level++ {
+		regions = refineRegions(points, regions)
+	}
+	if len(regions) == 0 {
+		return nil, nil, errors.New("no order-k regions within the bounds")
+	}
+
+	cells := make([]*KCell, len(regions))
+	polygons := make([][]Point, len(regions))
+	for i, region := range regions {
+		cell := &KCell{Polygon: region.polygon}
+		for _, m := range region.members {
+			cell.Sites = append(cell.Sites, &own[m])
+		}
+		sort.Slice(cell.Sites, func(a, b int) bool { return cell.Sites[a].ID < cell.Sites[b].ID })
+		cells[i] = cell
+		polygons[i] = region.polygon
+	}
+
+	d := dcel.NewDCEL()
+	faces := addPolygonFaces(d, polygons, torus{})
+	for i, face := range faces {
+		if face == nil {
+			continue
+		}
+		face.ID = int64(i)
+		face.Data = cells[i]
+		cells[i].Face = face
+	}
+
+	return cells, d, nil
+}
+
+// refineRegions splits each region by the nearest of the sites, which are not
+// members of the region yet, producing the regions of the next order.
+func refineRegions(points []Point, regions []kRegion) []kRegion {
+	var merged []*kRegion
+	pieces := make(map[string]*kRegion)
+	duplicate := make([]bool, len(points))
+	for i := range points {
+		duplicate[i] = isDuplicate(points, i)
+	}
+
+	for _, region := range regions {
+		isMember := make(map[int]bool)
+		for _, m := range region.members {
+			isMember[m] = true
+		}
+
+		for b := range points {
+			if isMember[b] || duplicate[b] {
+				continue
+			}
+
+			piece := region.polygon
+			for c := range points {
+				if c == b || isMember[c] || duplicate[c] {
+					continue
+				}
+				piece = clipCloser(piece, points[b], points[c])
+				if piece == nil {
+					break
+				}
+			}
+			if piece == nil || signedArea(piece) < 1e-9 {
+				continue
+			}
+
+			members := append(append([]int{}, region.members...), b)
+			sort.Ints(members)
+			key := membersKey(members)
+			target := pieces[key]
+			if target == nil {
+				target = &kRegion{members: members}
+				pieces[key] = target
+				merged = append(merged, target)
+			}
+			target.polygon = append(target.polygon, piece...)
+		}
+	}
+
+	// Regions of an order-k diagram are convex, so the pieces of a region
+	// are merged by taking the convex hull of their vertices.
+	var refined []kRegion
+	for _, region := range merged {
+		var hull []Point
+		for _, i := range convexHull(region.polygon) {
+			hull = append(hull, region.polygon[i])
+		}
+		if hull = cleanPolygon(hull); hull != nil {
+			refined = append(refined, kRegion{region.members, hull})
+		}
+	}
+	return refined
+}
+
+// membersKey joins sorted member indices into a key of the region.
+func membersKey(members []int) string {
+	var b strings.Builder
+	for i, m := range members {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(m))
+	}
+	return b.String()
+}
+
+// isDuplicate reports if an earlier point has the same location as the
+// point with the given index.
+func isDuplicate(points []Point, index int) bool {
+	for i := 0; i < index; i++ {
+		if points[i] == points[index] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestOrderK(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 300)
+	sites := randomSites(20, 1, bounds)
+	for k := 1; k <= 3; k++ {
+		cells, d, err := OrderK(sites, bounds, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var area float64
+		for _, cell := range cells {
+			area += signedArea(cell.Polygon)
+			if len(cell.Sites) != k {
+				t.Fatalf("k=%d: region has %d sites", k, len(cell.Sites))
+			}
+			if cell.Face == nil || cell.Face.Data != cell {
+				t.Fatalf("k=%d: region is not linked to its face", k)
+			}
+		}
+		if math.Abs(area-90000) > 1e-6*90000 {
+			t.Errorf("k=%d: regions cover %v, want 90000", k, area)
+		}
+		if len(d.Faces) != len(cells) {
+			t.Errorf("k=%d: got %d faces for %d regions", k, len(d.Faces), len(cells))
+		}
+
+		// Points of a region have its sites as their k nearest sites.
+		r := rand.New(rand.NewSource(int64(k)))
+		for n := 0; n < 300; n++ {
+			p := Point{r.Float64() * 300, r.Float64() * 300}
+			order := make([]int, len(sites))
+			for i := range order {
+				order[i] = i
+			}
+			sort.Slice(order, func(a, b int) bool {
+				return dist(p, pointOf(&sites[order[a]])) < dist(p, pointOf(&sites[order[b]]))
+			})
+			var want []int64
+			for _, i := range order[:k] {
+				want = append(want, sites[i].ID)
+			}
+			sort.Slice(want, func(a, b int) bool { return want[a] < want[b] })
+
+			found := 0
+			for _, cell := range cells {
+				if !pointInPolygon(p, cell.Polygon) {
+					continue
+				}
+				found++
+				for i, id := range cell.IDs() {
+					if id != want[i] {
+						t.Fatalf("k=%d: %v lies in the region of %v, want %v", k, p, cell.IDs(), want)
+					}
+				}
+			}
+			if found != 1 {
+				t.Fatalf("k=%d: %v lies in %d regions", k, p, found)
+			}
+		}
+	}
+}
+
+func TestOrderKInvalid(t *testing.T) {
+	sites := randomSites(3, 1, image.Rect(0, 0, 10, 10))
+	for _, k := range []int{0, 4} {
+		if _, _, err := OrderK(sites, image.Rect(0, 0, 10, 10), k); err == nil {
+			t.Errorf("k=%d accepted for 3 sites", k)
+		}
+	}
+}
+
+func TestMembersKey(t *testing.T) {
+	keys := make(map[string]bool)
+	for _, members := range [][]int{{1, 23}, {12, 3}, {1, 2, 3}, {123}, {}} {
+		key := membersKey(members)
+		if keys[key] {
+			t.Errorf("members %v share the key %q", members, key)
+		}
+		keys[key] = true
+	}
+}
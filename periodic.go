@@ -0,0 +1,125 @@
+package voronoi
+
+import (
+	"image"
+)
+
+// Wrap selects the axes, along which the bounds of a periodic diagram wrap
+// around, making the domain a cylinder or a torus.
+type Wrap int
+
+const (
+	// WrapNone disables periodic boundary conditions.
+	WrapNone Wrap = 0
+	// WrapX connects the left and the right side of the bounds.
+	WrapX Wrap = 1
+	// WrapY connects the top and the bottom side of the bounds.
+	WrapY Wrap = 2
+	// WrapXY connects both pairs of opposite sides of the bounds.
+	WrapXY Wrap = WrapX | WrapY
+)
+
+// NewPeriodic creates a voronoi diagram generator for a periodic domain,
+// where the bounds wrap around along the given axes. The generated diagram
+// has exactly one cell per site and its vertices lie within the bounds.
+// Edges of cells crossing a wrapped side of the bounds connect to vertices
+// on the opposite side.
+func NewPeriodic(sites SiteSlice, bounds image.Rectangle, wrap Wrap) *Voronoi {
+	voronoi := New(sites, bounds)
+	voronoi.Wrap = wrap
+	return voronoi
+}
+
+// torus describes a periodic domain. The period along axes, which do not
+// wrap around, is zero.
+type torus struct {
+	min    image.Point
+	period image.Point
+}
+
+// newTorus returns the periodic domain for the given bounds and wrapped axes.
+func newTorus(bounds image.Rectangle, wrap Wrap) torus {
+	t := torus{min: bounds.Min}
+	if wrap&WrapX != 0 {
+		t.period.X = bounds.Dx()
+	}
+	if wrap&WrapY != 0 {
+		t.period.Y = bounds.Dy()
+	}
+	return t
+}
+
+// wrap moves a point into the unit cell of the domain.
+func (t torus) wrap(p image.Point) image.Point {
+	if t.period.X > 0 {
+		p.X = t.min.X + mod(p.X-t.min.X, t.period.X)
+	}
+	if t.period.Y > 0 {
+		p.Y = t.min.Y + mod(p.Y-t.min.Y, t.period.Y)
+	}
+	return p
+}
+
+// shifts returns the offsets to the neighbouring copies of the unit cell,
+// starting with the zero offset of the unit cell itself.
+func (t torus) shifts() []Point {
+	shifts := []Point{{0, 0}}
+	for _, dx := range []int{0, -1, 1} {
+		for _, dy := range []int{0, -1, 1} {
+			if (dx == 0 && dy == 0) || (dx != 0 && t.period.X == 0) || (dy != 0 && t.period.Y == 0) {
+				continue
+			}
+			shifts = append(shifts, Point{float64(dx * t.period.X), float64(dy * t.period.Y)})
+		}
+	}
+	return shifts
+}
+
+// images returns the point and its copies in the neighbouring unit cells.
+func (t torus) images(p image.Point) []image.Point {
+	var images []image.Point
+	for _, s := range t.shifts() {
+		images = append(images, image.Point{p.X + int(s.X), p.Y + int(s.Y)})
+	}
+	return images
+}
+
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// periodicPolygons computes the cells of the sites on a periodic domain.
+// Each site is wrapped into the unit cell and surrounded by ghost copies
+// of all sites in the neighbouring unit cells. The cell of the site is then
+// computed against both the other sites and the ghosts. Cells are returned
+// unwrapped, so they may extend beyond the bounds.
+func (v *Voronoi) periodicPolygons(sites []*Site, domain torus) ([]*Site, [][]Point) {
+	var points []Point
+	for _, shift := range domain.shifts() {
+		for _, site := range sites {
+			p := domain.wrap(image.Point{site.X, site.Y})
+			points = append(points, Point{float64(p.X) + shift.X, float64(p.Y) + shift.Y})
+		}
+	}
+
+	// Cells can extend up to half a period beyond the bounds.
+	bounds := v.Bounds
+	bounds.Min = bounds.Min.Sub(domain.period)
+	bounds.Max = bounds.Max.Add(domain.period)
+
+	var owners []*Site
+	var polygons [][]Point
+	for i, site := range sites {
+		cell := v.Metric.metricCell(points, i, rectPolygon(bounds))
+		if cell == nil {
+			continue
+		}
+		owners = append(owners, site)
+		polygons = append(polygons, cell)
+	}
+	return owners, polygons
+}
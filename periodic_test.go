@@ -0,0 +1,77 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPeriodic(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	for _, wrap := range []Wrap{WrapX, WrapY, WrapXY} {
+		for seed := int64(1); seed <= 3; seed++ {
+			v := NewPeriodic(randomSites(15, seed, bounds), bounds, wrap)
+			v.Generate()
+			if len(v.DCEL.Faces) != len(v.Sites) {
+				t.Fatalf("wrap %d, seed %d: got %d faces for %d sites", wrap, seed, len(v.DCEL.Faces), len(v.Sites))
+			}
+			cells := v.Cells()
+			if area := cellsArea(cells); math.Abs(area-20000) > 1e-6*20000 {
+				t.Errorf("wrap %d, seed %d: cells cover %v, want 20000", wrap, seed, area)
+			}
+			for _, vertex := range v.DCEL.Vertices {
+				if wrap&WrapX != 0 && (vertex.X < 0 || vertex.X >= 200) || wrap&WrapY != 0 && (vertex.Y < 0 || vertex.Y >= 100) {
+					t.Fatalf("wrap %d, seed %d: vertex (%d, %d) lies outside the bounds", wrap, seed, vertex.X, vertex.Y)
+				}
+			}
+
+			// Every edge of a torus borders on another cell.
+			if wrap == WrapXY {
+				for _, cell := range cells {
+					for _, edge := range cell.Edges {
+						if edge.Neighbor == nil {
+							t.Fatalf("seed %d: cell of site %d has a border edge", seed, cell.Site.ID)
+						}
+					}
+				}
+			}
+
+			// Points inside a cell, or its copies, are nearest to its site.
+			r := rand.New(rand.NewSource(seed))
+			domain := newTorus(bounds, wrap)
+			for k := 0; k < 300; k++ {
+				p := Point{r.Float64() * 200, r.Float64() * 100}
+				nearest := math.Inf(1)
+				for i := range v.Sites {
+					nearest = math.Min(nearest, torusDistance(domain, p, pointOf(&v.Sites[i])))
+				}
+				found := 0
+				for _, cell := range cells {
+					for _, s := range domain.shifts() {
+						q := Point{p.X + s.X, p.Y + s.Y}
+						if !pointInPolygon(q, cell.Polygon) {
+							continue
+						}
+						found++
+						if boundaryDistance(cell.Polygon, q) > 1 && torusDistance(domain, p, pointOf(cell.Site)) > nearest+1e-9 {
+							t.Fatalf("wrap %d, seed %d: %v lies in the cell of a site, which is not the nearest", wrap, seed, p)
+						}
+					}
+				}
+				if found == 0 {
+					t.Fatalf("wrap %d, seed %d: %v lies in no cell", wrap, seed, p)
+				}
+			}
+		}
+	}
+}
+
+// torusDistance returns the distance between two points on a periodic domain.
+func torusDistance(domain torus, p, q Point) float64 {
+	d := math.Inf(1)
+	for _, s := range domain.shifts() {
+		d = math.Min(d, dist(p, Point{q.X + s.X, q.Y + s.Y}))
+	}
+	return d
+}
@@ -112,6 +112,15 @@ func (p *Plotter) colorOfSite(site *Site) color.Color {
 	return p.colorOfSiteIdx(siteIdx)
 }
 
+func (p *Plotter) colorOfSegment(segment *Segment) color.Color {
+	for i := range p.voronoi.Segments {
+		if &p.voronoi.Segments[i] == segment {
+			return p.colorOfSiteIdx(len(p.voronoi.Sites) + i)
+		}
+	}
+	return p.colorOfSiteIdx(0)
+}
+
 func (p *Plotter) colorOfSiteIdx(index int) color.Color {
 	return colors[index%len(colors)]
 }
@@ -175,7 +184,13 @@ func (p *Plotter) Faces() {
 			points = append(points, image.Point{edge.Target.X, edge.Target.Y})
 		}
 
-		cr, cg, cb, _ := p.colorOfSite(face.Data.(*Site)).RGBA()
+		var cr, cg, cb uint32
+		switch data := face.Data.(type) {
+		case *Site:
+			cr, cg, cb, _ = p.colorOfSite(data).RGBA()
+		case *Segment:
+			cr, cg, cb, _ = p.colorOfSegment(data).RGBA()
+		}
 		clr := color.RGBA{uint8(cr), uint8(cg), uint8(cb), 75}
 		p.ctx.SetPen(color.Transparent)
 		p.ctx.SetFill(clr)
@@ -217,6 +232,15 @@ func (p *Plotter) Sites() {
 	}
 }
 
+// Segments draws segment sites
+func (p *Plotter) Segments() {
+	for i := range p.voronoi.Segments {
+		segment := &p.voronoi.Segments[i]
+		p.ctx.SetPen(p.colorOfSegment(segment))
+		p.ctx.Line(segment.A.X, segment.A.Y, segment.B.X, segment.B.Y)
+	}
+}
+
 // Plot paints the voronoi diagram over the given image.
 func (p *Plotter) Plot() {
 	// Draw border and fill with background color
@@ -239,6 +263,9 @@ func (p *Plotter) Plot() {
 	// Draw sites and their labels
 	p.Sites()
 
+	// Draw segment sites
+	p.Segments()
+
 	// Draw sweep line with label
 	p.SweepLine(p.voronoi.SweepLine)
 }
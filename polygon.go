@@ -0,0 +1,227 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Point is a location in the plane with floating point coordinates.
+// Used for cell polygons, which are computed with more precision than
+// the integer coordinates stored in the DCEL.
+type Point struct {
+	X, Y float64
+}
+
+// pointOf converts a site to a floating point location.
+func pointOf(site *Site) Point {
+	return Point{float64(site.X), float64(site.Y)}
+}
+
+// rectPolygon returns the corners of a rectangle as a counter-clockwise polygon.
+func rectPolygon(r image.Rectangle) []Point {
+	x0, y0 := float64(r.Min.X), float64(r.Min.Y)
+	x1, y1 := float64(r.Max.X), float64(r.Max.Y)
+	return []Point{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// signedArea returns the signed area of a polygon. The area is positive
+// when the vertices are ordered counter-clockwise (with Y pointing up).
+func signedArea(poly []Point) float64 {
+	var area float64
+	for i := range poly {
+		j := (i + 1) % len(poly)
+		area += poly[i].X*poly[j].Y - poly[j].X*poly[i].Y
+	}
+	return area / 2
+}
+
+// cross returns the z component of the cross product of (b - a) and (c - a).
+func cross(a, b, c Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// dist returns the euclidean distance between two points.
+func dist(a, b Point) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// lerp returns the point at parameter t of the segment from a to b.
+func lerp(a, b Point, t float64) Point {
+	return Point{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}
+}
+
+// project returns the parameter of the point on segment ab nearest to p,
+// clamped to the [0, 1] range.
+func project(a, b, p Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return 0
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / l
+	return math.Max(0, math.Min(1, t))
+}
+
+// segmentIntersection returns the parameters t and u at which segment ab
+// crosses segment cd. Parallel segments never intersect.
+func segmentIntersection(a, b, c, d Point) (t, u float64, ok bool) {
+	rx, ry := b.X-a.X, b.Y-a.Y
+	sx, sy := d.X-c.X, d.Y-c.Y
+	denom := rx*sy - ry*sx
+	if denom == 0 {
+		return 0, 0, false
+	}
+	qx, qy := c.X-a.X, c.Y-a.Y
+	t = (qx*sy - qy*sx) / denom
+	u = (qx*ry - qy*rx) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, 0, false
+	}
+	return t, u, true
+}
+
+// cleanPolygon removes repeated and collinear vertices from a polygon.
+func cleanPolygon(poly []Point) []Point {
+	const eps = 1e-9
+	var out []Point
+	for _, p := range poly {
+		if len(out) > 0 && dist(out[len(out)-1], p) < eps {
+			continue
+		}
+		out = append(out, p)
+	}
+	for len(out) > 1 && dist(out[0], out[len(out)-1]) < eps {
+		out = out[:len(out)-1]
+	}
+
+	for changed := true; changed && len(out) >= 3; {
+		changed = false
+		for i := 0; i < len(out); i++ {
+			prev := out[(i+len(out)-1)%len(out)]
+			next := out[(i+1)%len(out)]
+			if math.Abs(cross(prev, out[i], next)) <= eps*(1+dist(prev, next)) {
+				out = append(out[:i], out[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
+	if len(out) < 3 {
+		return nil
+	}
+	return out
+}
+
+// pointInRect reports if the point lies inside the rectangle or on its border.
+func pointInRect(p Point, r image.Rectangle) bool {
+	return p.X >= float64(r.Min.X) && p.X <= float64(r.Max.X) &&
+		p.Y >= float64(r.Min.Y) && p.Y <= float64(r.Max.Y)
+}
+
+// reversePolygon returns a copy of the polygon with the order of vertices reversed.
+func reversePolygon(poly []Point) []Point {
+	out := make([]Point, len(poly))
+	for i, p := range poly {
+		out[len(poly)-1-i] = p
+	}
+	return out
+}
+
+// clipHalfPlane returns the part of a convex polygon, where n·p <= c.
+// Implements the Sutherland–Hodgman algorithm for a single clip edge.
+func clipHalfPlane(poly []Point, n Point, c float64) []Point {
+	const eps = 1e-9
+	side := func(p Point) float64 { return n.X*p.X + n.Y*p.Y - c }
+
+	var out []Point
+	for i := range poly {
+		p, q := poly[i], poly[(i+1)%len(poly)]
+		sp, sq := side(p), side(q)
+		if sp <= eps {
+			out = append(out, p)
+		}
+		if (sp < -eps && sq > eps) || (sp > eps && sq < -eps) {
+			out = append(out, lerp(p, q, sp/(sp-sq)))
+		}
+	}
+	return cleanPolygon(out)
+}
+
+// convexHull returns the indices of the points forming the convex hull,
+// in counter-clockwise order. Uses Andrew's monotone chain algorithm.
+func convexHull(points []Point) []int {
+	idx := make([]int, len(points))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		a, b := points[idx[i]], points[idx[j]]
+		return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+	})
+	if len(idx) < 3 {
+		return idx
+	}
+
+	hull := make([]int, 0, 2*len(idx))
+	for _, i := range idx {
+		for len(hull) >= 2 && cross(points[hull[len(hull)-2]], points[hull[len(hull)-1]], points[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, i)
+	}
+	lower := len(hull) + 1
+	for k := len(idx) - 2; k >= 0; k-- {
+		i := idx[k]
+		for len(hull) >= lower && cross(points[hull[len(hull)-2]], points[hull[len(hull)-1]], points[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, i)
+	}
+	return hull[:len(hull)-1]
+}
+
+// clipCloser returns the part of a convex polygon, which is at least as
+// close to point a as to point b in the euclidean metric.
+func clipCloser(poly []Point, a, b Point) []Point {
+	// |p - a|^2 <= |p - b|^2 simplifies to 2p·(b - a) <= |b|^2 - |a|^2
+	n := Point{b.X - a.X, b.Y - a.Y}
+	c := (b.X*b.X + b.Y*b.Y - a.X*a.X - a.Y*a.Y) / 2
+	return clipHalfPlane(poly, n, c)
+}
+
+// pointInPolygon reports if the point lies inside the polygon, using the
+// even-odd rule. Points on the border may be reported either way.
+func pointInPolygon(p Point, poly []Point) bool {
+	inside := false
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// polygonBox returns the minimum and maximum corners of the bounding box of a polygon.
+func polygonBox(poly []Point) (min, max Point) {
+	min = Point{math.Inf(1), math.Inf(1)}
+	max = Point{math.Inf(-1), math.Inf(-1)}
+	for _, p := range poly {
+		min = Point{math.Min(min.X, p.X), math.Min(min.Y, p.Y)}
+		max = Point{math.Max(max.X, p.X), math.Max(max.Y, p.Y)}
+	}
+	return min, max
+}
+
+// isConvexPolygon reports if all turns of the polygon are in the same direction.
+func isConvexPolygon(poly []Point) bool {
+	var positive, negative bool
+	for i := range poly {
+		c := cross(poly[i], poly[(i+1)%len(poly)], poly[(i+2)%len(poly)])
+		positive = positive || c > 0
+		negative = negative || c < 0
+	}
+	return !(positive && negative)
+}
@@ -0,0 +1,97 @@
+package voronoi
+
+// GabrielGraph returns the edges of the Gabriel graph of the sites: pairs of
+// sites, whose cells share an edge crossing the segment between the sites.
+// Equivalently, no other site lies in the closed circle, whose diameter is
+// that segment. The edges are taken from the shared edges of the cells, as
+// described in gabrielEdges, in O(n) time after the cells.
+// Edges are sorted by the IDs of the sites. Sites at the same location as an
+// earlier site are left out.
+func (v *Voronoi) GabrielGraph() []SiteEdge {
+	d := v.dual()
+	return siteEdges(d.sites, gabrielEdges(d))
+}
+
+// RelativeNeighborhoodGraph returns the edges of the relative neighbourhood
+// graph of the sites: the edge between sites a and b is kept, unless some
+// other site c lies in its lune, with max(|ca|, |cb|) < |ab|. The graph is a
+// subset of the Gabriel graph. Every site other than a has a neighbour closer
+// to a - their cells share an edge - so the sites in the lune are found by
+// following neighbours from a, without leaving the circle around a through
+// b. Edges are sorted by the IDs of the sites. Sites at the same location as
+// an earlier site are left out.
+func (v *Voronoi) RelativeNeighborhoodGraph() []SiteEdge {
+	d := v.dual()
+	points := d.points
+
+	adjacent := make([][]int, len(points))
+	for _, e := range d.edges {
+		adjacent[e[0]] = append(adjacent[e[0]], e[1])
+		adjacent[e[1]] = append(adjacent[e[1]], e[0])
+	}
+
+	var edges [][2]int
+	for _, e := range gabrielEdges(d) {
+		a, b := points[e[0]], points[e[1]]
+		length := dist(a, b)
+		empty := true
+		visited := map[int]bool{e[0]: true}
+		queue := []int{e[0]}
+		for len(queue) > 0 && empty {
+			c := queue[0]
+			queue = queue[1:]
+			for _, n := range adjacent[c] {
+				if visited[n] || dist(a, points[n]) >= length {
+					continue
+				}
+				visited[n] = true
+				if dist(b, points[n]) < length {
+					empty = false
+					break
+				}
+				queue = append(queue, n)
+			}
+		}
+		if empty {
+			edges = append(edges, e)
+		}
+	}
+	return siteEdges(d.sites, edges)
+}
+
+// gabrielEdges returns the pairs of sites, whose shared voronoi edge crosses
+// the segment between them. The edge between the cells of a and b ends at
+// the circumcenters of a, b and the neighbours c across the adjacent edges of
+// the cell of a. Such an end lies on the side of the segment, on which c lies,
+// if the angle at c is acute, so the edge crosses the segment if both angles
+// are. Ends on the border of the box, within which the cells are computed,
+// lie beyond the segment.
+func gabrielEdges(d *delaunayDual) [][2]int {
+	acute := func(a, b, c int) bool {
+		if c < 0 {
+			return true
+		}
+		pa, pb, pc := d.points[a], d.points[b], d.points[c]
+		return (pa.X-pc.X)*(pb.X-pc.X)+(pa.Y-pc.Y)*(pb.Y-pc.Y) > 0
+	}
+
+	var edges [][2]int
+	seen := make(map[[2]int]bool)
+	for a, around := range d.around {
+		for k, b := range around {
+			if b < 0 {
+				continue
+			}
+			e := [2]int{a, b}
+			if b < a {
+				e = [2]int{b, a}
+			}
+			prev, next := around[(k+len(around)-1)%len(around)], around[(k+1)%len(around)]
+			if !seen[e] && acute(a, b, prev) && acute(a, b, next) {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+	return edges
+}
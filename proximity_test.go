@@ -0,0 +1,71 @@
+package voronoi
+
+import (
+	"image"
+	"testing"
+)
+
+func TestProximityGraphs(t *testing.T) {
+	var grid SiteSlice
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			grid = append(grid, Site{X: x * 10, Y: y * 10, ID: int64(len(grid))})
+		}
+	}
+	inputs := []SiteSlice{grid, append(grid[:10:10], grid[3], grid[7])}
+	for seed := int64(1); seed <= 20; seed++ {
+		inputs = append(inputs, randomSites(120, seed, image.Rect(0, 0, 300, 200)))
+	}
+
+	for n, sites := range inputs {
+		v := New(sites, image.Rect(0, 0, 300, 200))
+
+		// Brute force over the sites, without the ones at the location of
+		// an earlier site.
+		var unique []*Site
+		var points []Point
+		seen := make(map[Point]bool)
+		for i := range v.Sites {
+			if p := pointOf(&v.Sites[i]); !seen[p] {
+				seen[p] = true
+				unique = append(unique, &v.Sites[i])
+				points = append(points, p)
+			}
+		}
+		var gabriel, rng [][2]int
+		for i, a := range points {
+			for j := i + 1; j < len(points); j++ {
+				b, l := points[j], dist(a, points[j])
+				inCircle, inLune := false, false
+				for k, c := range points {
+					if k == i || k == j {
+						continue
+					}
+					inCircle = inCircle || (a.X-c.X)*(b.X-c.X)+(a.Y-c.Y)*(b.Y-c.Y) <= 0
+					inLune = inLune || dist(a, c) < l && dist(b, c) < l
+				}
+				if !inCircle {
+					gabriel = append(gabriel, [2]int{i, j})
+				}
+				if !inLune {
+					rng = append(rng, [2]int{i, j})
+				}
+			}
+		}
+
+		for name, graphs := range map[string][2][]SiteEdge{
+			"Gabriel graph":                {v.GabrielGraph(), siteEdges(unique, gabriel)},
+			"relative neighbourhood graph": {v.RelativeNeighborhoodGraph(), siteEdges(unique, rng)},
+		} {
+			got, want := graphs[0], graphs[1]
+			if len(got) != len(want) {
+				t.Fatalf("input %d: %s has %d edges, want %d", n, name, len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("input %d: %s has edge %v, want %v", n, name, got[i], want[i])
+				}
+			}
+		}
+	}
+}
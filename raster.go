@@ -0,0 +1,320 @@
+package voronoi
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// RasterOptions configures the rasterization of a diagram.
+type RasterOptions struct {
+	// JumpFlooding computes the labels with the jump flooding algorithm on
+	// the pixel grid, instead of filling the exact cell polygons. It takes
+	// O(w*h*log(max(w, h))) time, independent of the number of sites, but
+	// may mislabel some pixels near the edges of the cells. Sites outside
+	// the rasterized bounds are ignored. Only used for nearest-point diagrams,
+	// which are not periodic.
+	JumpFlooding bool
+}
+
+// LabelGrid holds a label for each pixel of a raster, row by row.
+type LabelGrid struct {
+	Width, Height int
+	// Labels holds the index in Voronoi.Sites of the site, whose cell covers
+	// the center of each pixel, or -1 for pixels outside of all cells.
+	Labels []int32
+}
+
+// At returns the label of a pixel.
+func (g *LabelGrid) At(x, y int) int32 {
+	return g.Labels[y*g.Width+x]
+}
+
+// raster maps the pixels of a raster to the plane. Pixel (x, y) covers the
+// square of size 1/scale at bounds.Min + (x, y)/scale.
+type raster struct {
+	min           Point
+	scale         float64
+	width, height int
+}
+
+func newRaster(bounds image.Rectangle, scale float64) raster {
+	if scale <= 0 {
+		scale = 1
+	}
+	return raster{
+		min:    Point{float64(bounds.Min.X), float64(bounds.Min.Y)},
+		scale:  scale,
+		width:  int(math.Ceil(float64(bounds.Dx()) * scale)),
+		height: int(math.Ceil(float64(bounds.Dy()) * scale)),
+	}
+}
+
+// center returns the center of a pixel.
+func (r raster) center(x, y int) Point {
+	return Point{r.min.X + (float64(x)+0.5)/r.scale, r.min.Y + (float64(y)+0.5)/r.scale}
+}
+
+// fillRings calls fill for each pixel, whose center lies inside the polygon
+// bounded by the rings, an outer boundary followed by its holes. Pixel
+// centers on the left and top edges of a polygon belong to it, while the ones
+// on the right and bottom edges do not, so polygons sharing an edge cover
+// each pixel once.
+func (r raster) fillRings(rings [][]Point, fill func(x, y int)) {
+	if len(rings) == 0 || len(rings[0]) < 3 {
+		return
+	}
+	lo, hi := polygonBox(rings[0])
+	y0 := int(math.Max(0, math.Ceil((lo.Y-r.min.Y)*r.scale-0.5)))
+	y1 := int(math.Min(float64(r.height-1), math.Ceil((hi.Y-r.min.Y)*r.scale-0.5)))
+	var xs []float64
+	for y := y0; y <= y1; y++ {
+		cy := r.center(0, y).Y
+		xs = xs[:0]
+		for _, ring := range rings {
+			for i, a := range ring {
+				b := ring[(i+1)%len(ring)]
+				if (a.Y <= cy) != (b.Y <= cy) {
+					// Both cells along an edge compute the same crossing.
+					if b.Y < a.Y || (b.Y == a.Y && b.X < a.X) {
+						a, b = b, a
+					}
+					xs = append(xs, a.X+(cy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0 := int(math.Max(0, math.Ceil((xs[i]-r.min.X)*r.scale-0.5)))
+			x1 := int(math.Min(float64(r.width), math.Ceil((xs[i+1]-r.min.X)*r.scale-0.5)))
+			for x := x0; x < x1; x++ {
+				fill(x, y)
+			}
+		}
+	}
+}
+
+// band calls visit for each pixel, whose center lies within the given
+// distance of the segment from a to b.
+func (r raster) band(a, b Point, distance float64, visit func(x, y int)) {
+	y0 := int(math.Max(0, math.Ceil((math.Min(a.Y, b.Y)-distance-r.min.Y)*r.scale-0.5)))
+	y1 := int(math.Min(float64(r.height-1), math.Floor((math.Max(a.Y, b.Y)+distance-r.min.Y)*r.scale-0.5)))
+	for y := y0; y <= y1; y++ {
+		// The part of the segment within the distance of the row.
+		cy := r.center(0, y).Y
+		t0, t1 := 0.0, 1.0
+		if a.Y != b.Y {
+			t0 = (cy - distance - a.Y) / (b.Y - a.Y)
+			t1 = (cy + distance - a.Y) / (b.Y - a.Y)
+			if t0 > t1 {
+				t0, t1 = t1, t0
+			}
+			t0, t1 = math.Min(math.Max(t0, 0), 1), math.Min(math.Max(t1, 0), 1)
+		}
+		xa, xb := a.X+t0*(b.X-a.X), a.X+t1*(b.X-a.X)
+		x0 := int(math.Max(0, math.Ceil((math.Min(xa, xb)-distance-r.min.X)*r.scale-0.5)))
+		x1 := int(math.Min(float64(r.width-1), math.Floor((math.Max(xa, xb)+distance-r.min.X)*r.scale-0.5)))
+		for x := x0; x <= x1; x++ {
+			c := r.center(x, y)
+			if dist(c, lerp(a, b, project(a, b, c))) <= distance {
+				visit(x, y)
+			}
+		}
+	}
+}
+
+// Labels rasterizes the diagram within the given bounds, with scale pixels
+// per unit, into a grid holding the site of each pixel. The labels are
+// computed by scanline filling of the cell polygons, unless jump flooding is
+// requested. The vertices of the cells are rounded to integers in the DCEL,
+// so the pixels near the edges of the cells are labelled by comparing the
+// distances to the sites of the cells meeting there, which makes the labels
+// exact. Not supported for diagrams with segment sites.
+func (v *Voronoi) Labels(bounds image.Rectangle, scale float64, opts RasterOptions) (*LabelGrid, error) {
+	if len(v.Segments) > 0 {
+		return nil, errors.New("rasterization is not supported for segment sites")
+	}
+	r := newRaster(bounds, scale)
+	grid := &LabelGrid{Width: r.width, Height: r.height, Labels: make([]int32, r.width*r.height)}
+	for i := range grid.Labels {
+		grid.Labels[i] = -1
+	}
+
+	if opts.JumpFlooding && !v.Farthest && v.Wrap == WrapNone {
+		v.jumpFlood(r, grid)
+		return grid, nil
+	}
+	v.labelCells(r, v.Cells(), grid.Labels)
+	return grid, nil
+}
+
+// labelCells labels the pixels covered by the cells with the index of their
+// site in Voronoi.Sites. The pixels within a unit of the edges of a cell,
+// which may lie on the wrong side of an edge after rounding, are labelled
+// with the nearest (or farthest) of the sites of the cell, its neighbours and
+// the current label. Each pixel lies near the cell of its site, so the site
+// wins once that cell is visited.
+func (v *Voronoi) labelCells(r raster, cells []Cell, labels []int32) {
+	index := make(map[*Site]int32, len(v.Sites))
+	for i := range v.Sites {
+		index[&v.Sites[i]] = int32(i)
+	}
+	shifts := newTorus(v.Bounds, v.Wrap).shifts()
+
+	// Distances on a periodic domain are measured to the nearest copy of
+	// the site.
+	distance := func(c Point, label int32) float64 {
+		p := pointOf(&v.Sites[label])
+		d := math.Inf(1)
+		for _, s := range shifts {
+			d = math.Min(d, v.Metric.Distance(c, Point{p.X + s.X, p.Y + s.Y}))
+		}
+		return d
+	}
+	better := func(c Point, label, than int32) bool {
+		if than < 0 {
+			return true
+		}
+		d, e := distance(c, label), distance(c, than)
+		if d == e {
+			return label < than
+		}
+		return (d < e) != v.Farthest
+	}
+	inside := func(c Point) bool {
+		return v.Wrap != WrapNone || (pointInRect(c, v.Bounds) && (v.Clip == nil || v.Clip.Contains(c)))
+	}
+
+	for _, cell := range cells {
+		label := index[cell.Site]
+		for _, s := range shifts {
+			r.fillRings(shiftRings(append([][]Point{cell.Polygon}, cell.Holes...), s), func(x, y int) {
+				labels[y*r.width+x] = label
+			})
+		}
+	}
+
+	for _, cell := range cells {
+		candidates := []int32{index[cell.Site]}
+		for _, edge := range cell.Edges {
+			if edge.Neighbor != nil {
+				candidates = append(candidates, index[edge.Neighbor])
+			}
+		}
+		for _, s := range shifts {
+			for _, edge := range cell.Edges {
+				a, b := Point{edge.A.X + s.X, edge.A.Y + s.Y}, Point{edge.B.X + s.X, edge.B.Y + s.Y}
+				r.band(a, b, 1, func(x, y int) {
+					c := r.center(x, y)
+					if !inside(c) {
+						labels[y*r.width+x] = -1
+						return
+					}
+					best := labels[y*r.width+x]
+					for _, label := range candidates {
+						if label != best && better(c, label, best) {
+							best = label
+						}
+					}
+					labels[y*r.width+x] = best
+				})
+			}
+		}
+	}
+}
+
+// shiftRings returns a copy of the rings moved by an offset.
+func shiftRings(rings [][]Point, offset Point) [][]Point {
+	shifted := make([][]Point, len(rings))
+	for i, ring := range rings {
+		shifted[i] = make([]Point, len(ring))
+		for k, p := range ring {
+			shifted[i][k] = Point{p.X + offset.X, p.Y + offset.Y}
+		}
+	}
+	return shifted
+}
+
+// jumpFlood labels the pixels with the jump flooding algorithm. Each pixel
+// holding a site spreads its label to pixels at decreasing steps, which keep
+// the site if it is nearer than their current one.
+func (v *Voronoi) jumpFlood(r raster, grid *LabelGrid) {
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		p := pointOf(site)
+		x := int(math.Floor((p.X - r.min.X) * r.scale))
+		y := int(math.Floor((p.Y - r.min.Y) * r.scale))
+		if x >= 0 && x < r.width && y >= 0 && y < r.height && grid.Labels[y*r.width+x] < 0 {
+			grid.Labels[y*r.width+x] = int32(i)
+		}
+	}
+
+	step := 1
+	for step*2 < r.width || step*2 < r.height {
+		step *= 2
+	}
+	next := make([]int32, len(grid.Labels))
+	for ; step >= 1; step /= 2 {
+		for y := 0; y < r.height; y++ {
+			for x := 0; x < r.width; x++ {
+				c := r.center(x, y)
+				best := grid.Labels[y*r.width+x]
+				bestDistance := math.Inf(1)
+				if best >= 0 {
+					bestDistance = v.Metric.Distance(c, pointOf(&v.Sites[best]))
+				}
+				for dy := -step; dy <= step; dy += step {
+					for dx := -step; dx <= step; dx += step {
+						nx, ny := x+dx, y+dy
+						if nx < 0 || nx >= r.width || ny < 0 || ny >= r.height {
+							continue
+						}
+						label := grid.Labels[ny*r.width+nx]
+						if label < 0 || label == best {
+							continue
+						}
+						if d := v.Metric.Distance(c, pointOf(&v.Sites[label])); d < bestDistance {
+							best, bestDistance = label, d
+						}
+					}
+				}
+				next[y*r.width+x] = best
+			}
+		}
+		grid.Labels, next = next, grid.Labels
+	}
+
+	// Cells cover only the bounds of the diagram and the clip polygon.
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			c := r.center(x, y)
+			if !pointInRect(c, v.Bounds) || (v.Clip != nil && !v.Clip.Contains(c)) {
+				grid.Labels[y*r.width+x] = -1
+			}
+		}
+	}
+}
+
+// Rasterize rasterizes the diagram within the given bounds, with scale
+// pixels per unit, into a lossless label image. Each pixel holds the index in
+// Voronoi.Sites of the site, whose cell covers its center, plus one. Pixels
+// outside of all cells are zero. Diagrams with more than 65534 sites need
+// Labels instead.
+func (v *Voronoi) Rasterize(bounds image.Rectangle, scale float64) (*image.Gray16, error) {
+	if len(v.Sites) > math.MaxUint16-1 {
+		return nil, errors.New("too many sites for a 16-bit label image")
+	}
+	grid, err := v.Labels(bounds, scale, RasterOptions{})
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewGray16(image.Rect(0, 0, grid.Width, grid.Height))
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			img.SetGray16(x, y, color.Gray16{Y: uint16(grid.At(x, y) + 1)})
+		}
+	}
+	return img, nil
+}
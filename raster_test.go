@@ -0,0 +1,128 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// nearestLabel returns the index of the site nearest to a point, or the
+// farthest one, by brute force. Ties go to the first site.
+func nearestLabel(v *Voronoi, p Point) int32 {
+	best := int32(-1)
+	for i := range v.Sites {
+		label := int32(i)
+		if best < 0 {
+			best = label
+			continue
+		}
+		d, e := labelDistance(v, p, label), labelDistance(v, p, best)
+		if (!v.Farthest && d < e) || (v.Farthest && d > e) {
+			best = label
+		}
+	}
+	return best
+}
+
+// labelDistance returns the distance from a point to the nearest copy of the
+// site with the given label.
+func labelDistance(v *Voronoi, p Point, label int32) float64 {
+	q := pointOf(&v.Sites[label])
+	d := math.Inf(1)
+	for _, s := range newTorus(v.Bounds, v.Wrap).shifts() {
+		d = math.Min(d, v.Metric.Distance(p, Point{q.X + s.X, q.Y + s.Y}))
+	}
+	return d
+}
+
+func TestLabelsMatchNearestSite(t *testing.T) {
+	bounds := image.Rect(0, 0, 120, 80)
+	sites := randomSites(40, 1, bounds)
+	diagrams := map[string]*Voronoi{
+		"euclidean": New(sites, bounds),
+		"manhattan": NewWithMetric(sites, bounds, Manhattan),
+		"farthest":  New(sites[:10], bounds),
+		"clipped":   New(sites, bounds),
+		"periodic":  New(sites, bounds),
+	}
+	diagrams["farthest"].Farthest = true
+	diagrams["clipped"].Clip = NewClipPolygon(
+		[]Point{{0, 0}, {120, 0}, {60, 80.5}},
+		[]Point{{50, 20}, {70, 20}, {60, 35}},
+	)
+	diagrams["periodic"].Wrap = WrapX | WrapY
+
+	for name, v := range diagrams {
+		v.Generate()
+		raster := image.Rect(-5, -5, 125, 85)
+		grid, err := v.Labels(raster, 0.8, RasterOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := newRaster(raster, 0.8)
+		for y := 0; y < grid.Height; y++ {
+			for x := 0; x < grid.Width; x++ {
+				c := r.center(x, y)
+				want := int32(-1)
+				if v.Wrap != WrapNone || (pointInRect(c, v.Bounds) && (v.Clip == nil || v.Clip.Contains(c))) {
+					want = nearestLabel(v, c)
+				}
+				// Sites may be equally near to a region of pixels, which
+				// belongs to either of them.
+				if got := grid.At(x, y); got != want && (got < 0 || want < 0 || labelDistance(v, c, got) != labelDistance(v, c, want)) {
+					t.Fatalf("%s: pixel (%d, %d) at %v has label %d, want %d", name, x, y, c, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestLabelsJumpFlooding(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	v := New(randomSites(60, 2, bounds), bounds)
+	v.Generate()
+	grid, err := v.Labels(bounds, 1, RasterOptions{JumpFlooding: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRaster(bounds, 1)
+	wrong := 0
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			got := grid.At(x, y)
+			if got < 0 {
+				t.Fatalf("pixel (%d, %d) has no label", x, y)
+			}
+			c := r.center(x, y)
+			want := nearestLabel(v, c)
+			if got != want && dist(c, pointOf(&v.Sites[got])) > dist(c, pointOf(&v.Sites[want])) {
+				wrong++
+			}
+		}
+	}
+	if wrong*100 > len(grid.Labels) {
+		t.Errorf("%d of %d pixels are mislabelled", wrong, len(grid.Labels))
+	}
+}
+
+func TestRasterize(t *testing.T) {
+	bounds := image.Rect(0, 0, 50, 40)
+	v := New(randomSites(20, 3, bounds), bounds)
+	v.Generate()
+	raster := image.Rect(-2, 0, 50, 40)
+	img, err := v.Rasterize(raster, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid, _ := v.Labels(raster, 1, RasterOptions{})
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			if got, want := int32(img.Gray16At(x, y).Y)-1, grid.At(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) holds %d, want %d", x, y, got, want)
+			}
+		}
+	}
+	if grid.At(0, 0) != -1 || grid.At(2, 0) < 0 {
+		t.Error("pixels outside the diagram must be unlabelled")
+	}
+}
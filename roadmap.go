@@ -0,0 +1,387 @@
+package voronoi
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/quasoft/dcel"
+)
+
+// Roadmap is a graph made of the edges and vertices of a voronoi diagram,
+// whose sites are samples of obstacles. Paths along the roadmap keep as far
+// from the obstacles as possible. Edges along the bounds and the clip
+// polygon are not part of the roadmap.
+type Roadmap struct {
+	Vertices []Point
+	Edges    []RoadmapEdge
+
+	adjacent [][]int    // indices of the edges at each vertex
+	sites    [][2]Point // sites on both sides of each edge
+	cells    []roadmapCell
+	border   [][2]Point // edges along the bounds and the clip polygon
+}
+
+// RoadmapEdge is an edge of a roadmap between two vertices.
+type RoadmapEdge struct {
+	A, B      int      // indices of the vertices
+	Sites     [2]int64 // IDs of the sites on both sides of the edge
+	Length    float64
+	Clearance float64 // smallest distance from a point of the edge to the sites
+}
+
+// roadmapCell is a polygon of a cell, with the roadmap vertices on its boundary.
+type roadmapCell struct {
+	site     Point
+	polygon  []Point
+	holes    [][]Point
+	vertices []int
+	edges    []roadmapCellEdge
+}
+
+// roadmapCellEdge is an edge of a cell polygon, with the index of the
+// roadmap edge along it, or -1 if it is not part of the roadmap.
+type roadmapCellEdge struct {
+	a, b Point
+	edge int
+}
+
+// Roadmap builds the roadmap of a generated diagram from the DCEL of its
+// cells, as returned by Cells. Vertices of the roadmap are the vertices of
+// the DCEL, and its edges are the half-edges, whose twin belongs to the cell
+// of another site.
+// Only supported for euclidean diagrams of point sites, which are not
+// farthest-point or periodic diagrams.
+func (v *Voronoi) Roadmap() (*Roadmap, error) {
+	if v.Farthest || v.Metric != Euclidean || v.Wrap != WrapNone || len(v.Segments) > 0 {
+		return nil, errors.New("roadmaps are only supported for euclidean diagrams of point sites")
+	}
+	if v.DCEL == nil {
+		return nil, errors.New("the diagram is not generated")
+	}
+
+	r := &Roadmap{}
+	vertexIndex := make(map[*dcel.Vertex]int)
+	vertex := func(dv *dcel.Vertex) int {
+		if i, ok := vertexIndex[dv]; ok {
+			return i
+		}
+		vertexIndex[dv] = len(r.Vertices)
+		r.Vertices = append(r.Vertices, Point{float64(dv.X), float64(dv.Y)})
+		r.adjacent = append(r.adjacent, nil)
+		return len(r.Vertices) - 1
+	}
+
+	// Edges of the cells are matched with the roadmap edges along them, once
+	// the edges of all cells are known.
+	edgeIndex := make(map[*dcel.HalfEdge]int)
+	var halfEdges [][]*dcel.HalfEdge
+	for _, c := range v.Cells() {
+		cell := roadmapCell{site: pointOf(c.Site), polygon: c.Polygon, holes: c.Holes}
+		var cellHalfEdges []*dcel.HalfEdge
+		for _, edge := range c.Edges {
+			he := edge.HalfEdge
+			if he.Prev == nil || he.Prev.Target == nil || he.Target == nil {
+				continue
+			}
+			a, b := vertex(he.Prev.Target), vertex(he.Target)
+			cell.vertices = append(cell.vertices, a)
+			cell.edges = append(cell.edges, roadmapCellEdge{a: edge.A, b: edge.B})
+			cellHalfEdges = append(cellHalfEdges, he)
+			if edge.Neighbor == nil {
+				r.border = append(r.border, [2]Point{edge.A, edge.B})
+				continue
+			}
+			if _, ok := edgeIndex[he.Twin]; a == b || edge.Neighbor == c.Site || ok {
+				continue
+			}
+			edgeIndex[he] = len(r.Edges)
+			// Vertices are rounded, so the edge is not exactly equidistant
+			// from both sites.
+			sites := [2]Point{pointOf(c.Site), pointOf(edge.Neighbor)}
+			r.adjacent[a] = append(r.adjacent[a], len(r.Edges))
+			r.adjacent[b] = append(r.adjacent[b], len(r.Edges))
+			r.sites = append(r.sites, sites)
+			r.Edges = append(r.Edges, RoadmapEdge{
+				A:         a,
+				B:         b,
+				Sites:     [2]int64{c.Site.ID, edge.Neighbor.ID},
+				Length:    dist(edge.A, edge.B),
+				Clearance: segmentClearance(edge.A, edge.B, sites),
+			})
+		}
+		r.cells = append(r.cells, cell)
+		halfEdges = append(halfEdges, cellHalfEdges)
+	}
+	for i, cell := range r.cells {
+		for k, he := range halfEdges[i] {
+			cell.edges[k].edge = -1
+			if e, ok := edgeIndex[he]; ok {
+				cell.edges[k].edge = e
+			} else if e, ok := edgeIndex[he.Twin]; ok {
+				cell.edges[k].edge = e
+			}
+		}
+	}
+	return r, nil
+}
+
+// segmentClearance returns the smallest distance from a point of the segment
+// ab to the sites.
+func segmentClearance(a, b Point, sites [2]Point) float64 {
+	return math.Min(
+		dist(sites[0], lerp(a, b, project(a, b, sites[0]))),
+		dist(sites[1], lerp(a, b, project(a, b, sites[1]))),
+	)
+}
+
+// PlanPath returns the shortest path from start to goal along the roadmap,
+// using only the edges with a clearance of at least the given one. The start
+// and the goal are retracted onto the roadmap: each moves straight away from
+// the site of the cell, in which it lies, which only increases its distance
+// to the sites, until it meets an edge of the roadmap, along which it joins
+// the vertices of the edge. A point, which meets the bounds or the clip
+// polygon first, is connected by straight lines to the roadmap vertices of
+// its cell instead. Lines and parts of edges, which come closer to the sites
+// than the clearance, are not used, and an error is returned if the start or
+// the goal lie closer. The returned path begins with the start and ends with
+// the goal.
+func (r *Roadmap) PlanPath(start, goal Point, clearance float64) ([]Point, error) {
+	from, to := r.cellAt(start), r.cellAt(goal)
+	if from < 0 {
+		return nil, errors.New("start lies outside of the diagram")
+	}
+	if to < 0 {
+		return nil, errors.New("goal lies outside of the diagram")
+	}
+	if dist(start, r.cells[from].site) < clearance {
+		return nil, errors.New("start lies closer to a site than the clearance")
+	}
+	if dist(goal, r.cells[to].site) < clearance {
+		return nil, errors.New("goal lies closer to a site than the clearance")
+	}
+	startLegs, startEdge, startVia := r.retract(start, from, clearance)
+	goalLegs, goalEdge, goalVia := r.retract(goal, to, clearance)
+
+	// Points retracted onto the same edge are connected along it.
+	best, bestDistance := -1, math.Inf(1)
+	if startEdge >= 0 && startEdge == goalEdge && segmentClearance(startVia, goalVia, r.sites[startEdge]) >= clearance {
+		bestDistance = dist(start, startVia) + dist(startVia, goalVia) + dist(goalVia, goal)
+	}
+
+	// Dijkstra's algorithm over the roadmap vertices, starting from the
+	// vertices joined by the start.
+	n := len(r.Vertices)
+	distance := make([]float64, n)
+	previous := make([]int, n)
+	for i := range distance {
+		distance[i] = math.Inf(1)
+		previous[i] = -1
+	}
+	queue := &pathQueue{}
+	for _, leg := range startLegs {
+		if leg.length < distance[leg.vertex] {
+			distance[leg.vertex] = leg.length
+			heap.Push(queue, pathItem{leg.vertex, leg.length})
+		}
+	}
+	goalLeg := make(map[int]roadmapLeg)
+	for _, leg := range goalLegs {
+		if other, ok := goalLeg[leg.vertex]; !ok || leg.length < other.length {
+			goalLeg[leg.vertex] = leg
+		}
+	}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(pathItem)
+		if item.distance > distance[item.vertex] {
+			continue
+		}
+		if item.distance >= bestDistance {
+			break
+		}
+		if leg, ok := goalLeg[item.vertex]; ok {
+			if d := item.distance + leg.length; d < bestDistance {
+				best, bestDistance = item.vertex, d
+			}
+		}
+		for _, e := range r.adjacent[item.vertex] {
+			edge := r.Edges[e]
+			if edge.Clearance < clearance {
+				continue
+			}
+			next := edge.A
+			if next == item.vertex {
+				next = edge.B
+			}
+			if d := item.distance + edge.Length; d < distance[next] {
+				distance[next] = d
+				previous[next] = item.vertex
+				heap.Push(queue, pathItem{next, d})
+			}
+		}
+	}
+	if math.IsInf(bestDistance, 1) {
+		return nil, errors.New("no path with the required clearance")
+	}
+
+	path := []Point{goal, goalVia}
+	for i := best; i >= 0; i = previous[i] {
+		path = append(path, r.Vertices[i])
+	}
+	path = append(path, startVia, start)
+
+	// The start and the goal may lie on the roadmap already.
+	var reversed []Point
+	for i := len(path) - 1; i >= 0; i-- {
+		if len(reversed) == 0 || path[i] != reversed[len(reversed)-1] {
+			reversed = append(reversed, path[i])
+		}
+	}
+	return reversed, nil
+}
+
+// roadmapLeg is the length of the way from a point to a roadmap vertex.
+type roadmapLeg struct {
+	vertex int
+	length float64
+}
+
+// retract returns the legs from a point in a cell to the roadmap, which keep
+// the clearance. The point moves away from the site of the cell. If it meets
+// an edge of the roadmap, the legs run through the returned meeting point and
+// the index of the edge is returned too. Otherwise the edge index is -1, the
+// legs are straight lines, and the point itself is returned.
+func (r *Roadmap) retract(p Point, i int, clearance float64) ([]roadmapLeg, int, Point) {
+	cell := r.cells[i]
+	var legs []roadmapLeg
+	direction := Point{p.X - cell.site.X, p.Y - cell.site.Y}
+	if direction == (Point{}) && len(cell.vertices) > 0 {
+		// A point at the site may leave in any direction.
+		v := r.Vertices[cell.vertices[0]]
+		direction = Point{v.X - p.X, v.Y - p.Y}
+	}
+
+	// The ray leaves the cell through the first edge it crosses, or through a
+	// roadmap edge, where it crosses a vertex shared with the border.
+	reach := 1.0
+	for _, e := range cell.edges {
+		reach = math.Max(reach, math.Max(dist(p, e.a), dist(p, e.b)))
+	}
+	l := math.Hypot(direction.X, direction.Y)
+	far := Point{p.X + 2*reach*direction.X/l, p.Y + 2*reach*direction.Y/l}
+	edge, at := -1, math.Inf(1)
+	if l > 0 {
+		for _, e := range cell.edges {
+			t, _, ok := segmentIntersection(p, far, e.a, e.b)
+			if !ok {
+				continue
+			}
+			if t < at-1e-9 || t <= at+1e-9 && e.edge >= 0 && edge < 0 {
+				edge, at = e.edge, t
+			}
+		}
+	}
+
+	if edge >= 0 {
+		via := lerp(p, far, at)
+		for _, v := range []int{r.Edges[edge].A, r.Edges[edge].B} {
+			if segmentClearance(via, r.Vertices[v], r.sites[edge]) >= clearance {
+				legs = append(legs, roadmapLeg{v, dist(p, via) + dist(via, r.Vertices[v])})
+			}
+		}
+		return legs, edge, via
+	}
+	for _, v := range cell.vertices {
+		q := r.Vertices[v]
+		if len(r.adjacent[v]) > 0 && r.inside(p, q) && dist(cell.site, lerp(p, q, project(p, q, cell.site))) >= clearance {
+			legs = append(legs, roadmapLeg{v, dist(p, q)})
+		}
+	}
+	return legs, -1, p
+}
+
+// cellAt returns the index of the cell polygon containing the point, or -1.
+// A point on the boundary of several cells belongs to the one with the
+// nearest site.
+func (r *Roadmap) cellAt(p Point) int {
+	found := -1
+	for i, cell := range r.cells {
+		if !pointInPolygon(p, cell.polygon) && !onPolygonBoundary(p, cell.polygon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range cell.holes {
+			if pointInPolygon(p, hole) && !onPolygonBoundary(p, hole) {
+				inHole = true
+				break
+			}
+		}
+		if inHole {
+			continue
+		}
+		if found < 0 || dist(p, cell.site) < dist(p, r.cells[found].site) {
+			found = i
+		}
+	}
+	return found
+}
+
+// inside reports if the straight line from a to b stays within the cells.
+// The line is split where it meets the border of the diagram, and the
+// middle of each piece has to lie in a cell.
+func (r *Roadmap) inside(a, b Point) bool {
+	ts := []float64{0, 1}
+	for _, edge := range r.border {
+		if t, _, ok := segmentIntersection(a, b, edge[0], edge[1]); ok {
+			ts = append(ts, t)
+		}
+		for _, p := range edge {
+			if onSegment(a, b, p) {
+				ts = append(ts, project(a, b, p))
+			}
+		}
+	}
+	sort.Float64s(ts)
+	for i := 0; i+1 < len(ts); i++ {
+		if ts[i+1]-ts[i] < 1e-9 {
+			continue
+		}
+		if r.cellAt(lerp(a, b, (ts[i]+ts[i+1])/2)) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// onPolygonBoundary reports if the point lies on an edge of the polygon.
+func onPolygonBoundary(p Point, poly []Point) bool {
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		if onSegment(a, b, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathItem is a vertex of a roadmap waiting in the queue of Dijkstra's algorithm.
+type pathItem struct {
+	vertex   int
+	distance float64
+}
+
+// pathQueue is a priority queue of vertices, which implements heap.Interface.
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].distance < q[j].distance }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,120 @@
+package voronoi
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPlanPath(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 300)
+	for seed := int64(1); seed <= 5; seed++ {
+		v := New(randomSites(150, seed, bounds), bounds)
+		v.Generate()
+		r, err := v.Roadmap()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Paths keep the clearance from all sites, up to the rounding of the
+		// vertices, and get longer as it grows.
+		start, goal := Point{2, 2}, Point{397, 297}
+		shortest := 0.0
+		for _, clearance := range []float64{0, 2, 5, 10, 15} {
+			path, err := r.PlanPath(start, goal, clearance)
+			if err != nil {
+				if clearance == 0 {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				break
+			}
+			if path[0] != start || path[len(path)-1] != goal {
+				t.Fatalf("seed %d: path %v does not lead from start to goal", seed, path)
+			}
+			var length float64
+			for i := 0; i+1 < len(path); i++ {
+				a, b := path[i], path[i+1]
+				length += dist(a, b)
+				for k := range v.Sites {
+					p := pointOf(&v.Sites[k])
+					if d := dist(p, lerp(a, b, project(a, b, p))); d < clearance-1 {
+						t.Fatalf("seed %d: path %v comes within %v of site %v, want %v", seed, path, d, p, clearance)
+					}
+				}
+			}
+			if length < shortest-1e-9 {
+				t.Errorf("seed %d: path with clearance %v is shorter than with less", seed, clearance)
+			}
+			shortest = length
+		}
+	}
+}
+
+// The start and the goal move away from the nearest site onto the roadmap,
+// rather than taking the straight line across their cell.
+func TestPlanPathRetraction(t *testing.T) {
+	v := New(SiteSlice{{X: 100, Y: 100, ID: 1}, {X: 0, Y: 0, ID: 2}, {X: 200, Y: 0, ID: 3}, {X: 0, Y: 200, ID: 4}, {X: 200, Y: 200, ID: 5}}, image.Rect(0, 0, 200, 200))
+	v.Generate()
+	r, err := v.Roadmap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := r.PlanPath(Point{70, 100}, Point{130, 100}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := Point{100, 100}
+	for i := 0; i+1 < len(path); i++ {
+		a, b := path[i], path[i+1]
+		if d := dist(site, lerp(a, b, project(a, b, site))); d < 30-1e-9 {
+			t.Fatalf("path %v comes within %v of the site", path, d)
+		}
+	}
+	if _, err := r.PlanPath(Point{70, 100}, Point{130, 100}, 40); err == nil {
+		t.Error("path from a start closer to a site than the clearance found")
+	}
+	if _, err := r.PlanPath(Point{70, 100}, Point{-10, 100}, 0); err == nil {
+		t.Error("path to a goal outside of the diagram found")
+	}
+}
+
+func TestPlanPathConcave(t *testing.T) {
+	outer := []Point{{0, 0}, {300, 0}, {300, 300}, {200, 300}, {200, 100}, {100, 100}, {100, 300}, {0, 300}}
+	clip := NewClipPolygon(outer)
+	for seed := int64(1); seed <= 5; seed++ {
+		v := New(randomSites(80, seed, image.Rect(0, 0, 300, 300)), image.Rect(0, 0, 300, 300))
+		v.Clip = clip
+		v.Generate()
+		r, err := v.Roadmap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range [][2]Point{{{50, 290}, {250, 290}}, {{20, 280}, {180, 20}}} {
+			path, err := r.PlanPath(q[0], q[1], 0)
+			if err != nil {
+				t.Fatalf("seed %d: %v", seed, err)
+			}
+			// The path stays within the clip polygon.
+			for i := 0; i+1 < len(path); i++ {
+				for s := 0.0; s <= 1; s += 0.01 {
+					if p := lerp(path[i], path[i+1], s); !clip.Contains(p) && boundaryDistance(outer, p) > 1 {
+						t.Fatalf("seed %d: path %v leaves the clip polygon at %v", seed, path, p)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRoadmapInvalid(t *testing.T) {
+	sites := SiteSlice{{X: 10, Y: 10, ID: 1}, {X: 60, Y: 40, ID: 2}, {X: 30, Y: 80, ID: 3}}
+	v := NewWithMetric(sites, image.Rect(0, 0, 100, 100), Manhattan)
+	v.Generate()
+	if _, err := v.Roadmap(); err == nil {
+		t.Error("roadmap of a manhattan diagram built")
+	}
+	w := NewPeriodic(sites, image.Rect(0, 0, 100, 100), WrapX)
+	w.Generate()
+	if _, err := w.Roadmap(); err == nil {
+		t.Error("roadmap of a periodic diagram built")
+	}
+}
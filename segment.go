@@ -0,0 +1,244 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/quasoft/dcel"
+)
+
+// DefaultCurveSamples is the number of samples taken around each site, when
+// the curved edges of a segment voronoi diagram are turned into polylines.
+const DefaultCurveSamples = 128
+
+// Segment is a line segment, used as a site of a voronoi diagram. The cell of
+// a segment contains the points, which are closer to it than to any other
+// site. Cells of segments and point sites meet along parabolic arcs.
+type Segment struct {
+	A, B image.Point
+	ID   int64
+	Face *dcel.Face // Pointer to the DCEL face corresponding to this segment
+	Data interface{}
+}
+
+// Polyline splits a polyline into segments, numbered with consecutive IDs
+// starting from firstID.
+func Polyline(points []image.Point, firstID int64) []Segment {
+	var segments []Segment
+	for i := 0; i+1 < len(points); i++ {
+		segments = append(segments, Segment{
+			A:  points[i],
+			B:  points[i+1],
+			ID: firstID + int64(i),
+		})
+	}
+	return segments
+}
+
+// NewWithSegments creates a voronoi diagram generator for a list of point
+// sites and segments within the specified bounds. Segments may share end
+// points, like the segments of a polyline, but must not cross each other
+// or pass through point sites.
+func NewWithSegments(sites SiteSlice, segments []Segment, bounds image.Rectangle) *Voronoi {
+	voronoi := New(sites, bounds)
+	voronoi.Segments = make([]Segment, len(segments))
+	copy(voronoi.Segments, segments)
+	return voronoi
+}
+
+// generator is a point or a segment site, reduced to its geometry.
+// Point sites have both ends at the same location.
+type generator struct {
+	a, b Point
+}
+
+// distance returns the distance from p to the nearest point of the generator.
+func (g generator) distance(p Point) float64 {
+	return dist(p, lerp(g.a, g.b, project(g.a, g.b, p)))
+}
+
+// boundary returns points on the boundary of the generator, together with
+// the outward normals at those points, ordered counter-clockwise. Points
+// have a circle of normals around them, while segments have parallel
+// normals along their two sides and half circles around their ends.
+func (g generator) boundary(samples int) (origins, normals []Point) {
+	arc := func(center Point, from float64, count int) {
+		for i := 0; i < count; i++ {
+			angle := from + math.Pi*float64(i)/float64(count)
+			origins = append(origins, center)
+			normals = append(normals, Point{math.Cos(angle), math.Sin(angle)})
+		}
+	}
+
+	if g.a == g.b {
+		for i := 0; i < samples; i++ {
+			angle := 2 * math.Pi * float64(i) / float64(samples)
+			origins = append(origins, g.a)
+			normals = append(normals, Point{math.Cos(angle), math.Sin(angle)})
+		}
+		return origins, normals
+	}
+
+	l := dist(g.a, g.b)
+	d := Point{(g.b.X - g.a.X) / l, (g.b.Y - g.a.Y) / l}
+	right := Point{d.Y, -d.X}
+	left := Point{-d.Y, d.X}
+	count := samples / 4
+	for i := 0; i < count; i++ {
+		origins = append(origins, lerp(g.a, g.b, float64(i)/float64(count)))
+		normals = append(normals, right)
+	}
+	arc(g.b, math.Atan2(right.Y, right.X), count)
+	for i := 0; i < count; i++ {
+		origins = append(origins, lerp(g.b, g.a, float64(i)/float64(count)))
+		normals = append(normals, left)
+	}
+	arc(g.a, math.Atan2(left.Y, left.X), count)
+	return origins, normals
+}
+
+// dominance returns a polygon around g, which approximates the region of
+// points closer to g than to h, up to the given distance from g.
+// Along each outward normal of g, the point equally distant from g and h is
+// found by bisection. The points on the normals lie on the bisector of the
+// generators, which is made of straight lines and parabolic arcs.
+func (g generator) dominance(h generator, samples int, far float64) []Point {
+	eps := 1e-9 * far
+	origins, normals := g.boundary(samples)
+	poly := make([]Point, 0, len(origins))
+	for i, q := range origins {
+		n := normals[i]
+		at := func(t float64) Point { return Point{q.X + n.X*t, q.Y + n.Y*t} }
+
+		// The difference t - h.distance(at(t)) never decreases with t,
+		// so it crosses zero at most once. Points at nearly equal distance
+		// are left to h, as segments sharing an end point are equally distant
+		// from all points of the wedge beyond that end point.
+		closer := func(t float64) bool { return t-h.distance(at(t)) < -eps }
+		lo, hi := 0.0, far
+		if closer(hi) {
+			poly = append(poly, at(far))
+			continue
+		}
+		for k := 0; k < 60; k++ {
+			mid := (lo + hi) / 2
+			if closer(mid) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		poly = append(poly, at(lo))
+	}
+	return cleanPolygon(poly)
+}
+
+// generateSegmentCells creates a diagram for point sites and segments.
+// The cell of each site is computed by intersecting the bounds with the
+// regions dominated by the site over each of its neighbours. The bisector
+// between two sites is sampled once and shared by both of their cells.
+func (v *Voronoi) generateSegmentCells() {
+	var generators []generator
+	for i := range v.Sites {
+		v.Sites[i].Face = nil
+		p := pointOf(&v.Sites[i])
+		generators = append(generators, generator{p, p})
+	}
+	for i := range v.Segments {
+		s := &v.Segments[i]
+		s.Face = nil
+		a := Point{float64(s.A.X), float64(s.A.Y)}
+		b := Point{float64(s.B.X), float64(s.B.Y)}
+		generators = append(generators, generator{a, b})
+	}
+
+	samples := v.CurveSamples
+	if samples <= 0 {
+		samples = DefaultCurveSamples
+	}
+	bounds := rectPolygon(v.Bounds)
+	far := 2 * (1 + math.Hypot(float64(v.Bounds.Dx()), float64(v.Bounds.Dy())))
+
+	regions := make(map[[2]int][]Point)
+	var dominance func(i, j int) ([]Point, bool)
+	dominance = func(i, j int) ([]Point, bool) {
+		if i > j {
+			region, _ := dominance(j, i)
+			return region, true
+		}
+		key := [2]int{i, j}
+		if regions[key] == nil {
+			regions[key] = generators[i].dominance(generators[j], samples, far)
+		}
+		return regions[key], false
+	}
+
+	var polygons [][]Point
+	for i, g := range generators {
+		// Visit the nearest generators first, as they shrink the cell most.
+		order := make([]int, 0, len(generators))
+		gap := make([]float64, len(generators))
+		for j, h := range generators {
+			if j != i {
+				order = append(order, j)
+				gap[j] = math.Min(math.Min(h.distance(g.a), h.distance(g.b)),
+					math.Min(g.distance(h.a), g.distance(h.b)))
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool { return gap[order[a]] < gap[order[b]] })
+
+		cell := bounds
+		for _, j := range order {
+			if cell == nil {
+				break
+			}
+			// Skip generators too far to affect any point of the cell.
+			var radius float64
+			for _, p := range cell {
+				radius = math.Max(radius, g.distance(p))
+			}
+			if gap[j] >= 2*radius {
+				break
+			}
+
+			region, complement := dominance(i, j)
+			if region == nil {
+				continue
+			}
+			ring := region
+			contains := func(p Point) bool { return pointInPolygon(p, region) }
+			if complement {
+				ring = reversePolygon(region)
+				contains = func(p Point) bool { return !pointInPolygon(p, region) }
+			}
+			cell = largestPolygon(intersectPolygon(cell, [][]Point{ring}, contains))
+		}
+		polygons = append(polygons, cell)
+	}
+
+	faces := addPolygonFaces(v.DCEL, polygons, torus{})
+	for i, face := range faces {
+		if face == nil {
+			continue
+		}
+		if i < len(v.Sites) {
+			site := &v.Sites[i]
+			face.ID, face.Data, site.Face = site.ID, site, face
+		} else {
+			segment := &v.Segments[i-len(v.Sites)]
+			face.ID, face.Data, segment.Face = segment.ID, segment, face
+		}
+	}
+}
+
+// largestPolygon returns the polygon with the largest area.
+func largestPolygon(polygons [][]Point) []Point {
+	var largest []Point
+	for _, poly := range polygons {
+		if largest == nil || math.Abs(signedArea(poly)) > math.Abs(signedArea(largest)) {
+			largest = poly
+		}
+	}
+	return largest
+}
@@ -0,0 +1,83 @@
+package voronoi
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSegmentCells(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 300)
+	sites := SiteSlice{{X: 50, Y: 50, ID: 1}, {X: 250, Y: 60, ID: 2}, {X: 150, Y: 250, ID: 3}, {X: 150, Y: 150, ID: 4}}
+	segments := append(
+		Polyline([]image.Point{{100, 100}, {200, 120}, {220, 200}}, 10),
+		Segment{A: image.Point{30, 200}, B: image.Point{80, 280}, ID: 20},
+	)
+	v := NewWithSegments(sites, segments, bounds)
+	v.Generate()
+	cells := v.Cells()
+	if len(cells) != len(sites)+len(segments) {
+		t.Fatalf("got %d cells for %d sites", len(cells), len(sites)+len(segments))
+	}
+	if area := cellsArea(cells); math.Abs(area-90000) > 1e-6*90000 {
+		t.Errorf("cells cover %v, want 90000", area)
+	}
+
+	// Points inside a cell are nearest to its site, up to the error of the
+	// sampled curves and of the rounded vertices.
+	var generators []generator
+	for _, cell := range cells {
+		if (cell.Site == nil) == (cell.Segment == nil) {
+			t.Fatal("cell must belong to either a site or a segment")
+		}
+		if cell.Site != nil {
+			p := pointOf(cell.Site)
+			generators = append(generators, generator{p, p})
+		} else {
+			generators = append(generators, segmentGenerator(cell.Segment))
+		}
+	}
+	r := rand.New(rand.NewSource(1))
+	for k := 0; k < 1000; k++ {
+		p := Point{r.Float64() * 300, r.Float64() * 300}
+		nearest := math.Inf(1)
+		for _, g := range generators {
+			nearest = math.Min(nearest, g.distance(p))
+		}
+		for i, cell := range cells {
+			if pointInPolygon(p, cell.Polygon) && boundaryDistance(cell.Polygon, p) > 1.5 && generators[i].distance(p) > nearest+1e-9 {
+				t.Fatalf("%v lies in a cell, whose site is not the nearest", p)
+			}
+		}
+	}
+
+	// Consecutive segments of the polyline are neighbours.
+	for _, cell := range cells {
+		if cell.Segment == nil || cell.Segment.ID != 10 {
+			continue
+		}
+		found := false
+		for _, edge := range cell.Edges {
+			found = found || edge.NeighborSegment != nil && edge.NeighborSegment.ID == 11
+		}
+		if !found {
+			t.Error("consecutive segments of a polyline are not neighbours")
+		}
+	}
+}
+
+func TestPolyline(t *testing.T) {
+	segments := Polyline([]image.Point{{0, 0}, {10, 0}, {10, 10}}, 5)
+	if len(segments) != 2 || segments[0].ID != 5 || segments[1].ID != 6 || segments[0].B != segments[1].A {
+		t.Errorf("got %v", segments)
+	}
+	if Polyline([]image.Point{{0, 0}}, 0) != nil {
+		t.Error("polyline of a single point has segments")
+	}
+}
+
+// segmentGenerator returns the geometry of a segment.
+func segmentGenerator(s *Segment) generator {
+	return generator{Point{float64(s.A.X), float64(s.A.Y)}, Point{float64(s.B.X), float64(s.B.Y)}}
+}
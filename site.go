@@ -15,6 +15,8 @@ type Site struct {
 	ID   int64
 	Face *dcel.Face // Pointer to the DCEL face corresponding to this site
 	Data interface{}
+	// Outside is set for sites lying outside the clip polygon of the diagram.
+	Outside bool
 }
 
 func (s Site) String() string { return fmt.Sprintf("%d,%d", s.X, s.Y) }
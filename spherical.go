@@ -0,0 +1,340 @@
+package voronoi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GeoSite is a site on the surface of a sphere, given by its latitude and
+// longitude in degrees.
+type GeoSite struct {
+	Lat, Lon float64
+	ID       int64
+	Data     interface{}
+}
+
+// LatLon is a location on the surface of a sphere in degrees.
+type LatLon struct {
+	Lat, Lon float64
+}
+
+// SphericalCell is a cell of a spherical voronoi diagram - the part of the
+// sphere, which is closer to the site than to any other site, measuring
+// the distance along great circles.
+type SphericalCell struct {
+	Site *GeoSite
+	// Ring is the boundary of the cell, ordered counter-clockwise as seen from
+	// outside the sphere. Longitudes are unwrapped from the longitude of the
+	// site along the ring, so rings crossing the antimeridian have no jumps,
+	// but may go beyond the [-180, 180] range. The ring of a cell containing
+	// a pole winds once around it and is closed through the pole: it goes on
+	// to the first vertex shifted by 360 degrees, then to the pole at both
+	// longitudes. The rings then tile the map without gaps.
+	Ring []LatLon
+	// Area of the cell on the unit sphere, in steradians.
+	// Multiply by the square of the radius to get the area on a real sphere.
+	Area float64
+}
+
+// vec3 is a point or a direction in three dimensions.
+type vec3 struct {
+	X, Y, Z float64
+}
+
+func (a vec3) sub(b vec3) vec3    { return vec3{a.X - b.X, a.Y - b.Y, a.Z - b.Z} }
+func (a vec3) dot(b vec3) float64 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a.Y*b.Z - a.Z*b.Y, a.Z*b.X - a.X*b.Z, a.X*b.Y - a.Y*b.X}
+}
+func (a vec3) normalize() vec3 {
+	l := math.Sqrt(a.dot(a))
+	return vec3{a.X / l, a.Y / l, a.Z / l}
+}
+
+// toVec3 converts latitude and longitude in degrees to a point on the unit sphere.
+func toVec3(lat, lon float64) vec3 {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	return vec3{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+}
+
+// toLatLon converts a point on the unit sphere to latitude and longitude in degrees.
+func toLatLon(v vec3) LatLon {
+	return LatLon{
+		Lat: math.Asin(math.Max(-1, math.Min(1, v.Z))) * 180 / math.Pi,
+		Lon: math.Atan2(v.Y, v.X) * 180 / math.Pi,
+	}
+}
+
+// Spherical computes the voronoi diagram of sites on the unit sphere.
+// The Delaunay triangulation of the sites is found as the convex hull of
+// their points in three dimensions, and the vertices of the cells are the
+// centers of the circles passing through the vertices of its triangles.
+// Of several sites at the same location only the first gets a cell. Requires at least four sites,
+// which do not all lie on one circle, and returns an error for sites nearly
+// coincident with another one. Takes O(n^2) time.
+func Spherical(sites []GeoSite) ([]SphericalCell, error) {
+	points := make([]vec3, 0, len(sites))
+	owners := make([]*GeoSite, 0, len(sites))
+	seen := make(map[vec3]bool)
+	for i := range sites {
+		p := toVec3(sites[i].Lat, sites[i].Lon)
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		points = append(points, p)
+		owners = append(owners, &sites[i])
+	}
+
+	hull, err := newHull3(points)
+	if err == errCoplanar {
+		// Points of the sphere on one plane lie on one circle, which need
+		// not be a great circle.
+		return nil, errors.New("all sites lie on one circle")
+	}
+	if e, ok := err.(hullPointError); ok {
+		return nil, fmt.Errorf("site %d is too close to the other sites", owners[e.index].ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]SphericalCell, 0, len(points))
+	for i, p := range points {
+		fan := hull.facesAround(i)
+		if len(fan) < 3 {
+			continue
+		}
+		centers := make([]vec3, len(fan))
+		for k, f := range fan {
+			centers[k] = hull.normal(f)
+		}
+
+		cell := SphericalCell{Site: owners[i]}
+		lon := owners[i].Lon
+		for k, c := range centers {
+			ll := toLatLon(c)
+			ll.Lon = unwrapLon(ll.Lon, lon)
+			lon = ll.Lon
+			cell.Ring = append(cell.Ring, ll)
+			cell.Area += sphericalTriangleArea(p, c, centers[(k+1)%len(centers)])
+		}
+		// Going around a pole counter-clockwise turns east around the north
+		// pole and west around the south pole.
+		first := cell.Ring[0]
+		if end := unwrapLon(first.Lon, lon); end != first.Lon {
+			pole := 90.0
+			if end < first.Lon {
+				pole = -90
+			}
+			cell.Ring = append(cell.Ring, LatLon{first.Lat, end}, LatLon{pole, end}, LatLon{pole, first.Lon})
+		}
+		cells = append(cells, cell)
+	}
+	return cells, nil
+}
+
+// unwrapLon shifts a longitude by whole turns to within 180 degrees of ref.
+func unwrapLon(lon, ref float64) float64 {
+	for lon-ref > 180 {
+		lon -= 360
+	}
+	for lon-ref < -180 {
+		lon += 360
+	}
+	return lon
+}
+
+// sphericalTriangleArea returns the signed area of a triangle on the unit
+// sphere, using the formula of Van Oosterom and Strackee.
+func sphericalTriangleArea(a, b, c vec3) float64 {
+	numerator := a.dot(b.cross(c))
+	denominator := 1 + a.dot(b) + b.dot(c) + c.dot(a)
+	return 2 * math.Atan2(numerator, denominator)
+}
+
+// hull3 is a convex hull in three dimensions, made of triangular faces.
+// Vertices of each face are ordered counter-clockwise, as seen from outside.
+type hull3 struct {
+	points []vec3
+	faces  [][3]int
+	alive  []bool
+	// edges maps each directed edge to the face, where it appears.
+	edges map[[2]int]int
+}
+
+// errCoplanar is returned by newHull3 for points, which lie on one plane.
+var errCoplanar = errors.New("all points lie on one plane")
+
+// hullPointError is returned by newHull3 for a point, which is not visible
+// from any face of the hull of the points before it, as happens for points
+// nearly coincident with another one.
+type hullPointError struct {
+	index int
+}
+
+func (e hullPointError) Error() string {
+	return fmt.Sprintf("point %d lies inside the hull", e.index)
+}
+
+// newHull3 builds the convex hull of the points incrementally. Each point is
+// added by removing the faces visible from it and connecting the point to
+// the horizon - the edges between visible and invisible faces.
+func newHull3(points []vec3) (*hull3, error) {
+	const eps = 1e-12
+	h := &hull3{points: points, edges: make(map[[2]int]int)}
+	if len(points) < 4 {
+		return nil, errors.New("at least four distinct sites are needed")
+	}
+
+	// Find four points, which do not lie on one plane.
+	a, b := 0, 1
+	c := -1
+	for i := 2; i < len(points); i++ {
+		n := points[b].sub(points[a]).cross(points[i].sub(points[a]))
+		if n.dot(n) > eps {
+			c = i
+			break
+		}
+	}
+	if c < 0 {
+		return nil, errCoplanar
+	}
+	d := -1
+	normal := points[b].sub(points[a]).cross(points[c].sub(points[a]))
+	for i := 2; i < len(points); i++ {
+		if i != c && math.Abs(normal.dot(points[i].sub(points[a]))) > eps {
+			d = i
+			break
+		}
+	}
+	if d < 0 {
+		return nil, errCoplanar
+	}
+
+	// Orient the first face away from the fourth point.
+	if normal.dot(points[d].sub(points[a])) > 0 {
+		b, c = c, b
+	}
+	h.addFace(a, b, c)
+	h.addFace(a, c, d)
+	h.addFace(c, b, d)
+	h.addFace(b, a, d)
+
+	for i := range points {
+		if i == a || i == b || i == c || i == d {
+			continue
+		}
+		if !h.addPoint(i) {
+			return nil, hullPointError{i}
+		}
+	}
+	return h, nil
+}
+
+func (h *hull3) addFace(a, b, c int) {
+	f := len(h.faces)
+	h.faces = append(h.faces, [3]int{a, b, c})
+	h.alive = append(h.alive, true)
+	h.edges[[2]int{a, b}] = f
+	h.edges[[2]int{b, c}] = f
+	h.edges[[2]int{c, a}] = f
+}
+
+func (h *hull3) removeFace(f int) {
+	h.alive[f] = false
+	face := h.faces[f]
+	for k := 0; k < 3; k++ {
+		edge := [2]int{face[k], face[(k+1)%3]}
+		if h.edges[edge] == f {
+			delete(h.edges, edge)
+		}
+	}
+}
+
+// normal returns the outward unit normal of a face. For points on the unit
+// sphere, this is also the center of the circle through the face vertices.
+func (h *hull3) normal(f int) vec3 {
+	face := h.faces[f]
+	a, b, c := h.points[face[0]], h.points[face[1]], h.points[face[2]]
+	return b.sub(a).cross(c.sub(a)).normalize()
+}
+
+// addPoint adds a point to the hull. Returns false, leaving the hull as it
+// is, if the point is not outside of it.
+func (h *hull3) addPoint(i int) bool {
+	const eps = 1e-12
+	p := h.points[i]
+
+	visible := make(map[int]bool)
+	for f := range h.faces {
+		if !h.alive[f] {
+			continue
+		}
+		face := h.faces[f]
+		a, b, c := h.points[face[0]], h.points[face[1]], h.points[face[2]]
+		if b.sub(a).cross(c.sub(a)).dot(p.sub(a)) > eps {
+			visible[f] = true
+		}
+	}
+	if len(visible) == 0 {
+		return false
+	}
+
+	var horizon [][2]int
+	for f := range h.faces {
+		if !visible[f] {
+			continue
+		}
+		face := h.faces[f]
+		for k := 0; k < 3; k++ {
+			edge := [2]int{face[k], face[(k+1)%3]}
+			if other, ok := h.edges[[2]int{edge[1], edge[0]}]; ok && !visible[other] {
+				horizon = append(horizon, edge)
+			}
+		}
+	}
+	for f := range visible {
+		h.removeFace(f)
+	}
+	for _, edge := range horizon {
+		h.addFace(edge[0], edge[1], i)
+	}
+	return true
+}
+
+// facesAround returns the faces incident to a vertex, ordered
+// counter-clockwise around it, as seen from outside.
+func (h *hull3) facesAround(v int) []int {
+	start := -1
+	for f, face := range h.faces {
+		if h.alive[f] && (face[0] == v || face[1] == v || face[2] == v) {
+			start = f
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	var fan []int
+	f := start
+	for {
+		fan = append(fan, f)
+		// The next face around v shares the edge from the last vertex of
+		// this face (in the order starting with v) back to v.
+		face := h.faces[f]
+		k := 0
+		for face[k] != v {
+			k++
+		}
+		last := face[(k+2)%3]
+		next, ok := h.edges[[2]int{v, last}]
+		if !ok || next == start || len(fan) > len(h.faces) {
+			break
+		}
+		f = next
+	}
+	return fan
+}
@@ -0,0 +1,109 @@
+package voronoi
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomGeoSites returns n sites spread uniformly over the sphere.
+func randomGeoSites(n int, seed int64) []GeoSite {
+	r := rand.New(rand.NewSource(seed))
+	sites := make([]GeoSite, n)
+	for i := range sites {
+		sites[i] = GeoSite{
+			Lat: math.Asin(2*r.Float64()-1) * 180 / math.Pi,
+			Lon: r.Float64()*360 - 180,
+			ID:  int64(i),
+		}
+	}
+	return sites
+}
+
+func TestSpherical(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		sites := randomGeoSites(200, seed)
+		cells, err := Spherical(sites)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cells) != len(sites) {
+			t.Fatalf("seed %d: got %d cells for %d sites", seed, len(cells), len(sites))
+		}
+
+		var area, mapArea float64
+		for _, cell := range cells {
+			if cell.Area <= 0 {
+				t.Fatalf("seed %d: cell of site %d has area %v", seed, cell.Site.ID, cell.Area)
+			}
+			area += cell.Area
+
+			// The rings, with the longitude as x, tile the map.
+			ring := make([]Point, len(cell.Ring))
+			for k, ll := range cell.Ring {
+				ring[k] = Point{ll.Lon, ll.Lat}
+			}
+			mapArea += signedArea(ring)
+
+			// Vertices are equally near to the site and the nearest other sites.
+			site := toVec3(cell.Site.Lat, cell.Site.Lon)
+			for _, ll := range cell.Ring {
+				if math.Abs(ll.Lat) == 90 {
+					continue
+				}
+				v := toVec3(ll.Lat, ll.Lon)
+				nearest := -1.0
+				for _, other := range sites {
+					nearest = math.Max(nearest, toVec3(other.Lat, other.Lon).dot(v))
+				}
+				if math.Abs(nearest-site.dot(v)) > 1e-9 {
+					t.Fatalf("seed %d: vertex %v of site %d is not nearest to it", seed, ll, cell.Site.ID)
+				}
+			}
+		}
+		if math.Abs(area-4*math.Pi) > 1e-9 {
+			t.Errorf("seed %d: cells cover %v, want 4π", seed, area)
+		}
+		if math.Abs(mapArea-360*180) > 1e-6 {
+			t.Errorf("seed %d: rings cover %v of the map, want %v", seed, mapArea, 360*180)
+		}
+	}
+}
+
+func TestSphericalPoles(t *testing.T) {
+	sites := []GeoSite{{Lat: 80, Lon: 170}, {Lat: -80, Lon: -10}, {Lat: 0, Lon: 0}, {Lat: 0, Lon: 120}, {Lat: 0, Lon: -120}}
+	cells, err := Spherical(sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range cells[:2] {
+		n := len(cell.Ring)
+		pole := cell.Ring[n-1].Lat
+		if math.Abs(pole) != 90 || pole*cell.Site.Lat < 0 || cell.Ring[n-2].Lat != pole {
+			t.Fatalf("ring of the cell of the site at %v is not closed through its pole", *cell.Site)
+		}
+		if d := cell.Ring[n-3].Lon - cell.Ring[0].Lon; math.Abs(d) != 360 {
+			t.Errorf("ring of the cell of the site at %v turns by %v degrees", *cell.Site, d)
+		}
+	}
+	for _, cell := range cells[2:] {
+		for _, ll := range cell.Ring {
+			if math.Abs(ll.Lat) == 90 {
+				t.Errorf("ring of the cell of the site at %v goes through a pole", *cell.Site)
+			}
+		}
+	}
+}
+
+func TestSphericalInvalid(t *testing.T) {
+	var sites []GeoSite
+	for i := 0; i < 8; i++ {
+		sites = append(sites, GeoSite{Lat: 30, Lon: float64(i * 45), ID: int64(i)})
+	}
+	if _, err := Spherical(sites); err == nil {
+		t.Error("sites on one circle accepted")
+	}
+	if _, err := Spherical(sites[:3]); err == nil {
+		t.Error("three sites accepted")
+	}
+}
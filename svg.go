@@ -0,0 +1,172 @@
+package voronoi
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/quasoft/dcel"
+)
+
+// SVGOptions configures the SVG output of a diagram.
+type SVGOptions struct {
+	Edges    bool // draw the edges between cells as separate lines
+	Vertices bool // draw the vertices of the cells
+	Sites    bool // draw the sites
+	Labels   bool // write the ID of each site next to it
+	// Width and height of the document, which default to the size of the
+	// bounds. The view box always covers the bounds.
+	Width, Height int
+	// Style returns the style of a cell. Cells are filled with the colours
+	// used by Plotter when nil, or when the returned fill is empty.
+	Style func(cell *Cell) SVGStyle
+}
+
+// SVGStyle is the style of a cell. Values are written as SVG attributes.
+type SVGStyle struct {
+	Fill        string
+	Stroke      string
+	StrokeWidth float64
+	Class       string // added to the classes of the path
+}
+
+// WriteSVG writes the diagram as an SVG document. Each cell, as returned by
+// Cells, is a path with the ID "cell-<site ID>" and the classes "cell" and
+// "site-<site ID>", with a subpath for each hole. Cells of segments have the
+// ID "segment-<segment ID>" and the classes "cell" and
+// "segment-<segment ID>". Cells split into several pieces get an ID suffix
+// for each piece after the first. Edges, vertices, sites and labels are
+// written in separate groups after the cells, in that order.
+func (v *Voronoi) WriteSVG(w io.Writer, opts SVGOptions) error {
+	bw := bufio.NewWriter(w)
+	b := v.Bounds
+	width, height := opts.Width, opts.Height
+	if width <= 0 {
+		width = b.Dx()
+	}
+	if height <= 0 {
+		height = b.Dy()
+	}
+	num := func(x float64) string { return strconv.FormatFloat(x, 'f', -1, 64) }
+	attr := html.EscapeString
+
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"%d %d %d %d\">\n",
+		width, height, b.Min.X, b.Min.Y, b.Dx(), b.Dy())
+
+	order := v.cellOrder()
+	cells := v.Cells()
+	pieces := make(map[string]int)
+	fmt.Fprintln(bw, "<g class=\"cells\">")
+	for i := range cells {
+		cell := &cells[i]
+		var style SVGStyle
+		if opts.Style != nil {
+			style = opts.Style(cell)
+		}
+		if style.Fill == "" {
+			style.Fill = svgColor(colors[order(cell)%len(colors)])
+		}
+		var id, class string
+		if cell.Site != nil {
+			id = fmt.Sprintf("cell-%d", cell.Site.ID)
+			class = fmt.Sprintf("cell site-%d", cell.Site.ID)
+		} else {
+			id = fmt.Sprintf("segment-%d", cell.Segment.ID)
+			class = fmt.Sprintf("cell segment-%d", cell.Segment.ID)
+		}
+		n := pieces[id]
+		pieces[id]++
+		if n > 0 {
+			id = fmt.Sprintf("%s-%d", id, n)
+		}
+		if style.Class != "" {
+			class += " " + style.Class
+		}
+
+		// Holes are clockwise, so the nonzero fill rule leaves them empty.
+		var d strings.Builder
+		for _, ring := range append([][]Point{cell.Polygon}, cell.Holes...) {
+			for k, p := range ring {
+				if k == 0 {
+					if d.Len() > 0 {
+						d.WriteString(" ")
+					}
+					d.WriteString("M")
+				} else {
+					d.WriteString(" L")
+				}
+				d.WriteString(num(p.X) + " " + num(p.Y))
+			}
+			d.WriteString(" Z")
+		}
+
+		fmt.Fprintf(bw, "<path id=\"%s\" class=\"%s\" d=\"%s\" fill=\"%s\"", id, attr(class), d.String(), attr(style.Fill))
+		if style.Stroke != "" {
+			fmt.Fprintf(bw, " stroke=\"%s\"", attr(style.Stroke))
+		}
+		if style.StrokeWidth > 0 {
+			fmt.Fprintf(bw, " stroke-width=\"%s\"", num(style.StrokeWidth))
+		}
+		fmt.Fprintln(bw, "/>")
+	}
+	fmt.Fprintln(bw, "</g>")
+
+	if opts.Edges {
+		fmt.Fprintln(bw, "<g class=\"edges\" stroke=\"black\">")
+		written := make(map[*dcel.HalfEdge]bool)
+		for _, cell := range cells {
+			for _, edge := range cell.Edges {
+				if edge.HalfEdge.Twin != nil && written[edge.HalfEdge.Twin] {
+					continue
+				}
+				written[edge.HalfEdge] = true
+				fmt.Fprintf(bw, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\"/>\n",
+					num(edge.A.X), num(edge.A.Y), num(edge.B.X), num(edge.B.Y))
+			}
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+
+	if opts.Vertices {
+		fmt.Fprintln(bw, "<g class=\"vertices\" fill=\"black\">")
+		written := make(map[Point]bool)
+		for _, cell := range cells {
+			for _, edge := range cell.Edges {
+				if p := edge.A; !written[p] {
+					written[p] = true
+					fmt.Fprintf(bw, "<circle cx=\"%s\" cy=\"%s\" r=\"1.5\"/>\n", num(p.X), num(p.Y))
+				}
+			}
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+
+	if opts.Sites {
+		fmt.Fprintln(bw, "<g class=\"sites\" fill=\"black\">")
+		for _, site := range v.Sites {
+			fmt.Fprintf(bw, "<circle class=\"site-%d\" cx=\"%d\" cy=\"%d\" r=\"2\"/>\n", site.ID, site.X, site.Y)
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+
+	if opts.Labels {
+		fmt.Fprintln(bw, "<g class=\"labels\" font-size=\"10\" fill=\"black\">")
+		for _, site := range v.Sites {
+			fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\">%d</text>\n", site.X+3, site.Y-3, site.ID)
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// svgColor formats a colour as an SVG colour value.
+func svgColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
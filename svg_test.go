@@ -0,0 +1,169 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// svgDocument is a decoded SVG document written by WriteSVG.
+type svgDocument struct {
+	Width   int    `xml:"width,attr"`
+	Height  int    `xml:"height,attr"`
+	ViewBox string `xml:"viewBox,attr"`
+	Groups  []struct {
+		Class string `xml:"class,attr"`
+		Paths []struct {
+			ID     string `xml:"id,attr"`
+			Class  string `xml:"class,attr"`
+			D      string `xml:"d,attr"`
+			Fill   string `xml:"fill,attr"`
+			Stroke string `xml:"stroke,attr"`
+		} `xml:"path"`
+		Lines   []struct{} `xml:"line"`
+		Circles []struct{} `xml:"circle"`
+		Texts   []string   `xml:"text"`
+	} `xml:"g"`
+}
+
+// svgRings parses the path data written by WriteSVG into its rings.
+func svgRings(d string) ([][]Point, error) {
+	var rings [][]Point
+	fields := strings.Fields(d)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "Z":
+			continue
+		case strings.HasPrefix(f, "M"):
+			rings = append(rings, nil)
+		case !strings.HasPrefix(f, "L"):
+			return nil, strconv.ErrSyntax
+		}
+		if i+1 >= len(fields) || len(rings) == 0 {
+			return nil, strconv.ErrSyntax
+		}
+		x, err := strconv.ParseFloat(f[1:], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		rings[len(rings)-1] = append(rings[len(rings)-1], Point{x, y})
+		i++
+	}
+	return rings, nil
+}
+
+func TestSVG(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	v := New(randomSites(30, 1, bounds), bounds)
+	v.Clip = NewClipPolygon(
+		[]Point{{0, 0}, {200, 0}, {200, 200}, {120, 200}, {120, 60}, {80, 60}, {80, 200}, {0, 200}},
+		[]Point{{30, 20}, {50, 20}, {50, 40}, {30, 40}},
+	)
+	v.Generate()
+	var buf bytes.Buffer
+	err := v.WriteSVG(&buf, SVGOptions{Edges: true, Vertices: true, Sites: true, Labels: true, Width: 400,
+		Style: func(cell *Cell) SVGStyle {
+			return SVGStyle{Stroke: `"red"&`, Class: "custom"}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc svgDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Width != 400 || doc.Height != 200 || doc.ViewBox != "0 0 200 200" {
+		t.Errorf("got size %dx%d and view box %q", doc.Width, doc.Height, doc.ViewBox)
+	}
+	var classes []string
+	for _, g := range doc.Groups {
+		classes = append(classes, g.Class)
+	}
+	if strings.Join(classes, " ") != "cells edges vertices sites labels" {
+		t.Fatalf("got groups %v", classes)
+	}
+
+	// Paths round-trip to the polygons and holes of the cells, in order.
+	cells := v.Cells()
+	paths := doc.Groups[0].Paths
+	if len(paths) != len(cells) {
+		t.Fatalf("got %d paths for %d cells", len(paths), len(cells))
+	}
+	ids := make(map[string]bool)
+	var area float64
+	for i, path := range paths {
+		if ids[path.ID] {
+			t.Fatalf("path ID %q is not unique", path.ID)
+		}
+		ids[path.ID] = true
+		cell := cells[i]
+		if !strings.HasPrefix(path.ID, "cell-"+strconv.FormatInt(cell.Site.ID, 10)) || !strings.HasSuffix(path.Class, " custom") {
+			t.Errorf("path %q of site %d has class %q", path.ID, cell.Site.ID, path.Class)
+		}
+		if path.Stroke != `"red"&` || !strings.HasPrefix(path.Fill, "#") {
+			t.Errorf("path %q has fill %q and stroke %q", path.ID, path.Fill, path.Stroke)
+		}
+		rings, err := svgRings(path.D)
+		if err != nil {
+			t.Fatalf("path %q: %v", path.ID, err)
+		}
+		want := append([][]Point{cell.Polygon}, cell.Holes...)
+		if len(rings) != len(want) {
+			t.Fatalf("path %q has %d rings, want %d", path.ID, len(rings), len(want))
+		}
+		for k := range rings {
+			if !sameVertices(rings[k], want[k]) {
+				t.Errorf("path %q has ring %v, want %v", path.ID, rings[k], want[k])
+			}
+			area += signedArea(rings[k])
+		}
+	}
+	// The clip polygon covers 34400 less the hole of 400.
+	if math.Abs(area-34000) > 1e-6 {
+		t.Errorf("paths cover %v, want 34000", area)
+	}
+	if n := len(doc.Groups[3].Circles); n != 30 {
+		t.Errorf("got %d sites, want 30", n)
+	}
+	if n := len(doc.Groups[4].Texts); n != 30 {
+		t.Errorf("got %d labels, want 30", n)
+	}
+	if len(doc.Groups[1].Lines) == 0 || len(doc.Groups[2].Circles) == 0 {
+		t.Error("edges or vertices missing")
+	}
+}
+
+// Pieces of a cell split by the clip polygon get IDs of their own.
+func TestSVGSplitCells(t *testing.T) {
+	v := New(SiteSlice{{X: 50, Y: 80, ID: 1}, {X: 150, Y: 20, ID: 2}}, image.Rect(0, 0, 200, 100))
+	v.Clip = NewClipPolygon([]Point{{0, 0}, {200, 0}, {200, 100}, {120, 100}, {120, 30}, {80, 30}, {80, 100}, {0, 100}})
+	v.Generate()
+	var buf bytes.Buffer
+	if err := v.WriteSVG(&buf, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var doc svgDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Groups) != 1 {
+		t.Fatalf("got %d groups, want the cells only", len(doc.Groups))
+	}
+	ids := make(map[string]bool)
+	for _, path := range doc.Groups[0].Paths {
+		ids[path.ID] = true
+	}
+	if len(ids) != len(v.Cells()) || !ids["cell-1"] || !ids["cell-1-1"] && !ids["cell-2-1"] {
+		t.Errorf("got paths %v for %d cells", ids, len(v.Cells()))
+	}
+}
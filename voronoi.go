@@ -18,6 +18,14 @@ type Voronoi struct {
 	ParabolaTree *Node
 	SweepLine    int // tracks the current position of the sweep line; updated when a new site is added.
 	DCEL         *dcel.DCEL
+	Metric       Metric       // distance function, Euclidean by default.
+	Farthest     bool         // generate the farthest-point diagram instead of the nearest-point one.
+	Clip         *ClipPolygon // optional polygon, to which the cells are clipped.
+	Wrap         Wrap         // axes along which the bounds wrap around, for periodic diagrams.
+	Segments     []Segment    // line segment sites, in addition to the point sites.
+	CurveSamples int          // samples per site for curved edges, DefaultCurveSamples if zero.
+
+	editor *editor // state of incremental updates, created by Insert and Delete.
 }
 
 // New creates a voronoi diagram generator for a list of sites and within the specified bounds.
@@ -29,6 +37,16 @@ func New(sites SiteSlice, bounds image.Rectangle) *Voronoi {
 	return voronoi
 }
 
+// NewWithMetric creates a voronoi diagram generator, which assigns points to
+// the nearest site according to the given metric.
+// Diagrams with Manhattan and Chebyshev metrics have polyline edges,
+// consisting of axis-aligned and diagonal segments.
+func NewWithMetric(sites SiteSlice, bounds image.Rectangle, metric Metric) *Voronoi {
+	voronoi := New(sites, bounds)
+	voronoi.Metric = metric
+	return voronoi
+}
+
 // NewFromPoints creates a voronoi diagram generator for a list of points within the specified bounds.
 func NewFromPoints(points []image.Point, bounds image.Rectangle) *Voronoi {
 	var sites SiteSlice
@@ -65,10 +83,13 @@ func (v *Voronoi) Reset() {
 	v.ParabolaTree = nil
 	v.SweepLine = 0
 	v.DCEL = dcel.NewDCEL()
+	v.editor = nil
 }
 
 // HandleNextEvent processes the next event from the internal event queue.
 // Used from the player application while developing the algorithm.
+// After the last event, the half-edges created by the sweep line are replaced
+// with ones linked in order around the exact cells, clipped to the bounds.
 func (v *Voronoi) HandleNextEvent() {
 	if v.EventQueue.Len() <= 0 {
 		return
@@ -80,27 +101,99 @@ func (v *Voronoi) HandleNextEvent() {
 	// Event with Y above the sweep line should be ignored.
 	if event.Y < v.SweepLine {
 		log.Printf("Ignoring event with Y %d as it's above the sweep line (%d)\r\n", event.Y, v.SweepLine)
-		return
+	} else {
+		v.SweepLine = event.Y
+		if event.EventType == EventSite {
+			v.handleSiteEvent(event)
+		} else {
+			v.handleCircleEvent(event)
+		}
 	}
 
-	v.SweepLine = event.Y
-	if event.EventType == EventSite {
-		v.handleSiteEvent(event)
-	} else {
-		v.handleCircleEvent(event)
+	if v.EventQueue.Len() == 0 {
+		v.linkCells()
 	}
 }
 
 // Generate runs the algorithm for the given sites and bounds, creating a voronoi diagram.
+// The half-edges of each face of the DCEL are linked in order around it.
 func (v *Voronoi) Generate() {
 	v.Reset()
 
+	if !v.usesSweepLine() {
+		v.generateCells()
+		return
+	}
+
 	// While queue is not empty
 	for v.EventQueue.Len() > 0 {
 		v.HandleNextEvent()
 	}
 }
 
+// usesSweepLine reports if the diagram is generated by Fortune's algorithm,
+// which only supports plain nearest-point diagrams with the euclidean metric.
+func (v *Voronoi) usesSweepLine() bool {
+	return !v.Farthest && v.Metric == Euclidean && v.Clip == nil && v.Wrap == WrapNone && len(v.Segments) == 0
+}
+
+// generateCells creates the diagram by computing the polygon of each cell
+// separately, instead of sweeping the plane. Used for farthest-point diagrams,
+// non-euclidean metrics, periodic domains, segment sites and for clipping of
+// cells to a polygon. Periodic diagrams are always nearest-point diagrams and
+// are not clipped. Diagrams with segments support none of the other options.
+func (v *Voronoi) generateCells() {
+	// Sites are not processed by the sweep line.
+	v.EventQueue = v.EventQueue[:0]
+
+	if len(v.Segments) > 0 {
+		v.generateSegmentCells()
+		return
+	}
+
+	for i := range v.Sites {
+		site := &v.Sites[i]
+		site.Face = nil
+		site.Outside = v.Clip != nil && v.Wrap == WrapNone && !v.Clip.Contains(pointOf(site))
+	}
+	sites, polygons, domain := v.cellPolygons()
+	addSiteFaces(v.DCEL, sites, polygons, domain)
+}
+
+// cellPolygons computes the polygons of the cells, which become the faces of
+// the DCEL, for all kinds of diagrams except the ones with segment sites.
+// A site is listed once for each polygon of its cell. Polygons of periodic
+// diagrams are not wrapped into the bounds. The diagram is not modified.
+func (v *Voronoi) cellPolygons() ([]*Site, [][]Point, torus) {
+	clip := v.Clip
+	if v.Wrap != WrapNone {
+		clip = nil
+	}
+
+	var sites []*Site
+	for i := range v.Sites {
+		sites = append(sites, &v.Sites[i])
+	}
+	if clip != nil {
+		sites = clip.filterSites(sites)
+	}
+
+	var polygons [][]Point
+	domain := newTorus(v.Bounds, v.Wrap)
+	if v.Wrap != WrapNone {
+		sites, polygons = v.periodicPolygons(sites, domain)
+	} else if v.Farthest {
+		sites, polygons = farthestPolygons(sites, v.Bounds)
+	} else {
+		sites, polygons = v.Metric.metricPolygons(sites, v.Bounds)
+	}
+
+	if clip != nil {
+		sites, polygons = clip.clipCells(sites, polygons, v.Farthest || v.Metric == Euclidean)
+	}
+	return sites, polygons, domain
+}
+
 // findNodeAbove finds the node for the parabola that is vertically above the specified site.
 func (v *Voronoi) findNodeAbove(site *Site) *Node {
 	node := v.ParabolaTree
@@ -0,0 +1,343 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WKB geometry types.
+const (
+	wkbPoint        = 1
+	wkbPolygon      = 3
+	wkbMultiPoint   = 4
+	wkbMultiPolygon = 6
+)
+
+// EncodeWKT encodes polygons as a WKT POLYGON, or a MULTIPOLYGON if there
+// are several of them. Each polygon is a list of rings: the outer boundary,
+// followed by the boundaries of its holes. Rings are closed by repeating
+// their first vertex.
+func EncodeWKT(polygons [][][]Point) string {
+	num := func(x float64) string { return strconv.FormatFloat(x, 'f', -1, 64) }
+	polygon := func(rings [][]Point) string {
+		if len(rings) == 0 || len(rings[0]) == 0 {
+			return "EMPTY"
+		}
+		parts := make([]string, 0, len(rings))
+		for _, ring := range rings {
+			points := make([]string, 0, len(ring)+1)
+			for _, p := range ring {
+				points = append(points, num(p.X)+" "+num(p.Y))
+			}
+			points = append(points, points[0])
+			parts = append(parts, "("+strings.Join(points, ", ")+")")
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+
+	switch len(polygons) {
+	case 0:
+		return "POLYGON EMPTY"
+	case 1:
+		return "POLYGON " + polygon(polygons[0])
+	}
+	parts := make([]string, len(polygons))
+	for i, rings := range polygons {
+		parts[i] = polygon(rings)
+	}
+	return "MULTIPOLYGON (" + strings.Join(parts, ", ") + ")"
+}
+
+// EncodeWKB encodes polygons, given as for EncodeWKT, as a little-endian WKB
+// polygon, or a multipolygon if there are several of them.
+func EncodeWKB(polygons [][][]Point) []byte {
+	var buf bytes.Buffer
+	polygon := func(rings [][]Point) {
+		writeWKBHeader(&buf, wkbPolygon)
+		if len(rings) == 0 || len(rings[0]) == 0 {
+			binary.Write(&buf, binary.LittleEndian, uint32(0))
+			return
+		}
+		binary.Write(&buf, binary.LittleEndian, uint32(len(rings)))
+		for _, ring := range rings {
+			binary.Write(&buf, binary.LittleEndian, uint32(len(ring)+1))
+			for k := 0; k <= len(ring); k++ {
+				p := ring[k%len(ring)]
+				binary.Write(&buf, binary.LittleEndian, [2]float64{p.X, p.Y})
+			}
+		}
+	}
+
+	switch len(polygons) {
+	case 0:
+		polygon(nil)
+	case 1:
+		polygon(polygons[0])
+	default:
+		writeWKBHeader(&buf, wkbMultiPolygon)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(polygons)))
+		for _, rings := range polygons {
+			polygon(rings)
+		}
+	}
+	return buf.Bytes()
+}
+
+// EncodeSitesWKT encodes the locations of sites as a WKT MULTIPOINT.
+func EncodeSitesWKT(sites SiteSlice) string {
+	if len(sites) == 0 {
+		return "MULTIPOINT EMPTY"
+	}
+	parts := make([]string, len(sites))
+	for i, site := range sites {
+		parts[i] = fmt.Sprintf("(%d %d)", site.X, site.Y)
+	}
+	return "MULTIPOINT (" + strings.Join(parts, ", ") + ")"
+}
+
+// EncodeSitesWKB encodes the locations of sites as a little-endian WKB
+// multipoint.
+func EncodeSitesWKB(sites SiteSlice) []byte {
+	var buf bytes.Buffer
+	writeWKBHeader(&buf, wkbMultiPoint)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(sites)))
+	for _, site := range sites {
+		writeWKBHeader(&buf, wkbPoint)
+		binary.Write(&buf, binary.LittleEndian, [2]float64{float64(site.X), float64(site.Y)})
+	}
+	return buf.Bytes()
+}
+
+func writeWKBHeader(buf *bytes.Buffer, geometry uint32) {
+	buf.WriteByte(1) // little-endian
+	binary.Write(buf, binary.LittleEndian, geometry)
+}
+
+// DecodeSitesWKT decodes sites from a WKT MULTIPOINT, in either the
+// "MULTIPOINT ((1 2), (3 4))" or the "MULTIPOINT (1 2, 3 4)" form. A single
+// POINT is accepted too. Coordinates are rounded to integers, Z and M values
+// are ignored, and sites are numbered with consecutive IDs starting from 0.
+func DecodeSitesWKT(s string) (SiteSlice, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ";"); i >= 0 && strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		s = strings.TrimSpace(s[i+1:])
+	}
+	upper := strings.ToUpper(s)
+	var body string
+	switch {
+	case strings.HasPrefix(upper, "MULTIPOINT"):
+		body = s[len("MULTIPOINT"):]
+	case strings.HasPrefix(upper, "POINT"):
+		body = s[len("POINT"):]
+	default:
+		return nil, fmt.Errorf("expected a MULTIPOINT, got %q", s)
+	}
+	body = strings.TrimSpace(body)
+	// Skip the dimension of the coordinates: Z, M or ZM.
+	body = strings.TrimSpace(strings.TrimLeft(body, "ZMzm"))
+	if strings.EqualFold(body, "EMPTY") {
+		return SiteSlice{}, nil
+	}
+	if !strings.HasPrefix(body, "(") || !strings.HasSuffix(body, ")") {
+		return nil, fmt.Errorf("malformed WKT %q", s)
+	}
+
+	var sites SiteSlice
+	body = body[1 : len(body)-1]
+	for _, part := range strings.Split(body, ",") {
+		part = strings.TrimSpace(part)
+		part = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(part, "("), ")"))
+		if strings.EqualFold(part, "EMPTY") {
+			continue
+		}
+		fields := strings.Fields(part)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed point %q", part)
+		}
+		x, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed point %q: %v", part, err)
+		}
+		y, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed point %q: %v", part, err)
+		}
+		sites = append(sites, Site{X: int(math.Round(x)), Y: int(math.Round(y)), ID: int64(len(sites))})
+	}
+	return sites, nil
+}
+
+// DecodeSitesWKB decodes sites from a WKB multipoint or point, in either byte
+// order. Extended WKB, as written by PostGIS, is accepted too. Coordinates are
+// rounded to integers, Z and M values are ignored, and sites are numbered
+// with consecutive IDs starting from 0.
+func DecodeSitesWKB(b []byte) (SiteSlice, error) {
+	r := &wkbReader{data: b}
+	geometry, dims := r.header()
+	var count uint32 = 1
+	if geometry == wkbMultiPoint {
+		count = r.uint32()
+	} else if geometry != wkbPoint {
+		if r.err == nil {
+			r.err = fmt.Errorf("expected a multipoint, got WKB geometry type %d", geometry)
+		}
+	}
+
+	sites := SiteSlice{}
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		pointDims := dims
+		if geometry == wkbMultiPoint {
+			var point uint32
+			point, pointDims = r.header()
+			if r.err == nil && point != wkbPoint {
+				r.err = fmt.Errorf("expected a point, got WKB geometry type %d", point)
+			}
+		}
+		coords := make([]float64, pointDims)
+		for k := range coords {
+			coords[k] = r.float64()
+		}
+		if r.err != nil {
+			break
+		}
+		if math.IsNaN(coords[0]) {
+			continue // empty point
+		}
+		sites = append(sites, Site{X: int(math.Round(coords[0])), Y: int(math.Round(coords[1])), ID: int64(len(sites))})
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return sites, nil
+}
+
+// DecodeSitesWKBHex decodes sites from hex encoded WKB, the way PostGIS
+// prints geometries, the same way as DecodeSitesWKB.
+func DecodeSitesWKBHex(s string) (SiteSlice, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("malformed hex WKB: %v", err)
+	}
+	return DecodeSitesWKB(b)
+}
+
+// wkbReader reads values of a WKB geometry, keeping the first error.
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *wkbReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *wkbReader) uint32() uint32 {
+	if b := r.read(4); b != nil {
+		return r.order.Uint32(b)
+	}
+	return 0
+}
+
+func (r *wkbReader) float64() float64 {
+	if b := r.read(8); b != nil {
+		return math.Float64frombits(r.order.Uint64(b))
+	}
+	return 0
+}
+
+// header reads the byte order and the type of a geometry, and returns the
+// base type and the number of coordinates of each point. The SRID of
+// extended WKB is skipped.
+func (r *wkbReader) header() (uint32, int) {
+	b := r.read(1)
+	if b == nil {
+		return 0, 0
+	}
+	switch b[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		r.err = errors.New("invalid WKB byte order")
+		return 0, 0
+	}
+
+	t := r.uint32()
+	dims := 2
+	if t&0x80000000 != 0 {
+		dims++ // Z of extended WKB
+	}
+	if t&0x40000000 != 0 {
+		dims++ // M of extended WKB
+	}
+	if t&0x20000000 != 0 {
+		r.uint32() // SRID
+	}
+	t &= 0x0fffffff
+	switch t / 1000 {
+	case 1, 2:
+		dims++ // ISO Z or M
+	case 3:
+		dims += 2 // ISO ZM
+	}
+	return t % 1000, dims
+}
+
+// WriteCellsWKT writes one line for each site with a cell, with the ID of
+// the site and the WKT of its cell separated by a tab, which is the text
+// format of the PostgreSQL COPY command. Holes left in cells by the clip
+// polygon are written as interior rings, and cells split into several pieces
+// by it as a MULTIPOLYGON. Cells of segments are left out, since their IDs
+// may coincide with the IDs of sites.
+func (v *Voronoi) WriteCellsWKT(w io.Writer) error {
+	return v.writeCells(w, EncodeWKT)
+}
+
+// WriteCellsWKB writes one line for each site with a cell, with the ID of
+// the site and the hex encoded WKB of its cell separated by a tab, which
+// PostGIS reads in COPY the same way as WKT. Like WriteCellsWKT, it leaves
+// out the cells of segments.
+func (v *Voronoi) WriteCellsWKB(w io.Writer) error {
+	return v.writeCells(w, func(polygons [][][]Point) string {
+		return strings.ToUpper(hex.EncodeToString(EncodeWKB(polygons)))
+	})
+}
+
+func (v *Voronoi) writeCells(w io.Writer, encode func([][][]Point) string) error {
+	var order []*Site
+	polygons := make(map[*Site][][][]Point)
+	for _, cell := range v.Cells() {
+		if cell.Site == nil {
+			continue
+		}
+		if _, ok := polygons[cell.Site]; !ok {
+			order = append(order, cell.Site)
+		}
+		rings := append([][]Point{cell.Polygon}, cell.Holes...)
+		polygons[cell.Site] = append(polygons[cell.Site], rings)
+	}
+	for _, site := range order {
+		if _, err := fmt.Fprintf(w, "%d\t%s\n", site.ID, encode(polygons[site])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package voronoi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// wktPolygons decodes a WKT POLYGON or MULTIPOLYGON, as written by EncodeWKT,
+// into polygons given as lists of rings without their closing vertex.
+func wktPolygons(s string) ([][][]Point, error) {
+	depth := 2
+	if strings.HasPrefix(s, "MULTIPOLYGON ") {
+		depth = 3
+	} else if !strings.HasPrefix(s, "POLYGON ") {
+		return nil, fmt.Errorf("not a polygon: %q", s)
+	}
+	var polygons [][][]Point
+	level, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			level++
+			if level == depth-1 {
+				polygons = append(polygons, nil)
+			} else if level == depth {
+				start = i + 1
+			}
+		case ')':
+			if level == depth {
+				var ring []Point
+				for _, part := range strings.Split(s[start:i], ",") {
+					fields := strings.Fields(part)
+					if len(fields) != 2 {
+						return nil, fmt.Errorf("malformed point %q", part)
+					}
+					x, err := strconv.ParseFloat(fields[0], 64)
+					if err != nil {
+						return nil, err
+					}
+					y, err := strconv.ParseFloat(fields[1], 64)
+					if err != nil {
+						return nil, err
+					}
+					ring = append(ring, Point{x, y})
+				}
+				if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+					return nil, fmt.Errorf("ring %v is not closed", ring)
+				}
+				polygons[len(polygons)-1] = append(polygons[len(polygons)-1], ring[:len(ring)-1])
+			}
+			level--
+		}
+	}
+	return polygons, nil
+}
+
+// wkbPolygons decodes a WKB polygon or multipolygon, like wktPolygons.
+func wkbPolygons(b []byte) ([][][]Point, error) {
+	r := &wkbReader{data: b}
+	geometry, _ := r.header()
+	count := uint32(1)
+	if geometry == wkbMultiPolygon {
+		count = r.uint32()
+	}
+	var polygons [][][]Point
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		polygon := geometry
+		if geometry == wkbMultiPolygon {
+			polygon, _ = r.header()
+		}
+		if polygon != wkbPolygon {
+			return nil, fmt.Errorf("got WKB geometry type %d", polygon)
+		}
+		var rings [][]Point
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			var ring []Point
+			for k := r.uint32(); k > 0 && r.err == nil; k-- {
+				ring = append(ring, Point{r.float64(), r.float64()})
+			}
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return nil, fmt.Errorf("ring %v is not closed", ring)
+			}
+			rings = append(rings, ring[:len(ring)-1])
+		}
+		polygons = append(polygons, rings)
+	}
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("%d bytes left", len(r.data))
+	}
+	return polygons, r.err
+}
+
+func TestEncodeWKT(t *testing.T) {
+	square := []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	hole := []Point{{1, 1}, {1, 2.5}, {2, 2.5}, {2, 1}}
+	tests := []struct {
+		polygons [][][]Point
+		want     string
+	}{
+		{nil, "POLYGON EMPTY"},
+		{[][][]Point{{square, hole}}, "POLYGON ((0 0, 4 0, 4 4, 0 4, 0 0), (1 1, 1 2.5, 2 2.5, 2 1, 1 1))"},
+		{[][][]Point{{square}, {hole}}, "MULTIPOLYGON (((0 0, 4 0, 4 4, 0 4, 0 0)), ((1 1, 1 2.5, 2 2.5, 2 1, 1 1)))"},
+	}
+	for _, test := range tests {
+		if got := EncodeWKT(test.polygons); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+		if test.polygons == nil {
+			continue
+		}
+		got, err := wkbPolygons(EncodeWKB(test.polygons))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.polygons) {
+			t.Errorf("WKB decodes to %v, want %v", got, test.polygons)
+		}
+	}
+	want := "0103000000010000000400000000000000000000000000000000000000000000000000f03f0000000000000000000000000000f03f000000000000f03f00000000000000000000000000000000"
+	if got := hex.EncodeToString(EncodeWKB([][][]Point{{{{0, 0}, {1, 0}, {1, 1}}}})); got != want {
+		t.Errorf("got WKB %s, want %s", got, want)
+	}
+}
+
+func TestSitesWKT(t *testing.T) {
+	sites := randomSites(20, 1, image.Rect(0, 0, 100, 100))
+	got, err := DecodeSitesWKT(EncodeSitesWKT(sites))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(sites) {
+		t.Fatalf("got %d sites, want %d", len(got), len(sites))
+	}
+	for i := range got {
+		if got[i].X != sites[i].X || got[i].Y != sites[i].Y || got[i].ID != int64(i) {
+			t.Errorf("got site %+v, want %+v with ID %d", got[i], sites[i], i)
+		}
+	}
+
+	tests := []struct {
+		wkt  string
+		want []image.Point
+	}{
+		{"MULTIPOINT (1 2, 3 4)", []image.Point{{1, 2}, {3, 4}}},
+		{"multipoint z ((1.4 2.6 7), (3 4 5))", []image.Point{{1, 3}, {3, 4}}},
+		{"SRID=4326;MULTIPOINT ((1 2), EMPTY)", []image.Point{{1, 2}}},
+		{"POINT (5 6)", []image.Point{{5, 6}}},
+		{"MULTIPOINT EMPTY", nil},
+	}
+	for _, test := range tests {
+		got, err := DecodeSitesWKT(test.wkt)
+		if err != nil {
+			t.Errorf("%s: %v", test.wkt, err)
+			continue
+		}
+		var points []image.Point
+		for _, site := range got {
+			points = append(points, image.Point{site.X, site.Y})
+		}
+		if fmt.Sprint(points) != fmt.Sprint(test.want) {
+			t.Errorf("%s: got %v, want %v", test.wkt, points, test.want)
+		}
+	}
+	for _, wkt := range []string{"LINESTRING (1 2, 3 4)", "MULTIPOINT (1 2", "MULTIPOINT (1)", "MULTIPOINT (1 a)"} {
+		if _, err := DecodeSitesWKT(wkt); err == nil {
+			t.Errorf("%s decoded", wkt)
+		}
+	}
+	if EncodeSitesWKT(nil) != "MULTIPOINT EMPTY" {
+		t.Errorf("no sites encode to %s", EncodeSitesWKT(nil))
+	}
+}
+
+func TestSitesWKB(t *testing.T) {
+	sites := randomSites(20, 2, image.Rect(0, 0, 100, 100))
+	b := EncodeSitesWKB(sites)
+	for _, decode := range []func() (SiteSlice, error){
+		func() (SiteSlice, error) { return DecodeSitesWKB(b) },
+		func() (SiteSlice, error) { return DecodeSitesWKBHex(hex.EncodeToString(b)) },
+	} {
+		got, err := decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(sites) {
+			t.Fatalf("got %d sites, want %d", len(got), len(sites))
+		}
+		for i := range got {
+			if got[i].X != sites[i].X || got[i].Y != sites[i].Y || got[i].ID != int64(i) {
+				t.Errorf("got site %+v, want %+v with ID %d", got[i], sites[i], i)
+			}
+		}
+	}
+
+	// A big-endian point with Z, and extended WKB of
+	// SRID=4326;MULTIPOINT(1 2,3 4), as PostGIS prints it.
+	var point bytes.Buffer
+	point.WriteByte(0)
+	binary.Write(&point, binary.BigEndian, uint32(1001))
+	binary.Write(&point, binary.BigEndian, [3]float64{7.6, -2, 9})
+	got, err := DecodeSitesWKB(point.Bytes())
+	if err != nil || len(got) != 1 || got[0].X != 8 || got[0].Y != -2 {
+		t.Errorf("got sites %v and error %v, want one at (8, -2)", got, err)
+	}
+	ewkb := "0104000020E6100000020000000101000000000000000000F03F0000000000000040010100000000000000000008400000000000001040"
+	got, err = DecodeSitesWKBHex(ewkb)
+	if err != nil || len(got) != 2 || got[1].X != 3 || got[1].Y != 4 {
+		t.Errorf("got sites %v and error %v, want (1, 2) and (3, 4)", got, err)
+	}
+
+	if _, err := DecodeSitesWKB(b[:len(b)-3]); err == nil {
+		t.Error("truncated WKB decoded")
+	}
+	if _, err := DecodeSitesWKB([]byte(ewkb)); err == nil {
+		t.Error("hex decoded as WKB")
+	}
+	if _, err := DecodeSitesWKBHex("zz"); err == nil {
+		t.Error("malformed hex decoded")
+	}
+	if _, err := DecodeSitesWKB(EncodeWKB([][][]Point{{{{0, 0}, {1, 0}, {1, 1}}}})); err == nil {
+		t.Error("polygon decoded as sites")
+	}
+}
+
+// Cells written as WKT and WKB round-trip to the polygons and holes of the
+// cells of each site.
+func TestWriteCells(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 200)
+	v := New(randomSites(30, 3, bounds), bounds)
+	v.Clip = NewClipPolygon(
+		[]Point{{0, 0}, {200, 0}, {200, 200}, {120, 200}, {120, 60}, {80, 60}, {80, 200}, {0, 200}},
+		[]Point{{30, 20}, {50, 20}, {50, 40}, {30, 40}},
+	)
+	v.Generate()
+	want := make(map[int64][][][]Point)
+	var ids []int64
+	for _, cell := range v.Cells() {
+		if _, ok := want[cell.Site.ID]; !ok {
+			ids = append(ids, cell.Site.ID)
+		}
+		want[cell.Site.ID] = append(want[cell.Site.ID], append([][]Point{cell.Polygon}, cell.Holes...))
+	}
+
+	decodeWKB := func(s string) ([][][]Point, error) {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return wkbPolygons(b)
+	}
+	for name, format := range map[string]struct {
+		write  func(*bytes.Buffer) error
+		decode func(string) ([][][]Point, error)
+	}{
+		"WKT": {func(buf *bytes.Buffer) error { return v.WriteCellsWKT(buf) }, wktPolygons},
+		"WKB": {func(buf *bytes.Buffer) error { return v.WriteCellsWKB(buf) }, decodeWKB},
+	} {
+		var buf bytes.Buffer
+		if err := format.write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != len(ids) {
+			t.Fatalf("%s: got %d lines for %d sites", name, len(lines), len(ids))
+		}
+		var area float64
+		for i, line := range lines {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 2 || fields[0] != strconv.FormatInt(ids[i], 10) {
+				t.Fatalf("%s: line %q, want site %d", name, line, ids[i])
+			}
+			polygons, err := format.decode(fields[1])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(polygons) != len(want[ids[i]]) {
+				t.Fatalf("%s: site %d has %d polygons, want %d", name, ids[i], len(polygons), len(want[ids[i]]))
+			}
+			for k, rings := range polygons {
+				if len(rings) != len(want[ids[i]][k]) {
+					t.Fatalf("%s: site %d has %d rings, want %d", name, ids[i], len(rings), len(want[ids[i]][k]))
+				}
+				for r, ring := range rings {
+					if !sameVertices(ring, want[ids[i]][k][r]) {
+						t.Errorf("%s: site %d has ring %v, want %v", name, ids[i], ring, want[ids[i]][k][r])
+					}
+					area += signedArea(ring)
+				}
+			}
+		}
+		// The clip polygon covers 34400 less the hole of 400.
+		if math.Abs(area-34000) > 1e-6 {
+			t.Errorf("%s: cells cover %v, want 34000", name, area)
+		}
+	}
+}